@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"go-backend/store"
+)
+
+// How long a session token stays valid after login
+const sessionTTL = 30 * 24 * time.Hour
+
+type contextKey string
+
+const userContextKey contextKey = "user"
+
+// hashToken returns the value stored in sessions.token_hash. Only the hash is
+// persisted so a leaked sessions table can't be used to impersonate anyone.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// createSession issues a new opaque session token for a user
+func createSession(ctx context.Context, sessions store.SessionStore, userID int) (string, time.Time, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	token := hex.EncodeToString(b)
+	expiresAt := time.Now().Add(sessionTTL)
+	if err := sessions.CreateSession(ctx, hashToken(token), userID, expiresAt); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	if len(h) > 7 && strings.EqualFold(h[:7], "Bearer ") {
+		return strings.TrimSpace(h[7:])
+	}
+	return ""
+}
+
+// Auth middleware: resolves the session token (if any) and stores the caller
+// in the request context. Routes that need a user are wrapped in requireAuth.
+func (s *server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" || r.Method == "OPTIONS" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, err := s.store.Sessions.SessionUser(r.Context(), hashToken(token), time.Now())
+		if err == store.ErrNotFound {
+			http.Error(w, "Invalid or expired session", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			fmt.Println("[DEBUG] Session lookup failed:", err)
+			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireAuth rejects requests without an authenticated user
+func requireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := currentUser(r); !ok {
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// currentUser returns the authenticated caller stored by authenticate
+func currentUser(r *http.Request) (store.User, bool) {
+	user, ok := r.Context().Value(userContextKey).(store.User)
+	return user, ok
+}
+
+// Handler for logging out: revokes the session token used for the request
+func (s *server) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := s.store.Sessions.DeleteSession(r.Context(), hashToken(bearerToken(r))); err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
+}
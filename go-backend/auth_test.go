@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"go-backend/store"
+)
+
+// newTestServer returns a server backed by an empty in-memory store
+func newTestServer() *server {
+	return &server{store: store.NewMemory(), rates: manualRates{}, defaultCurrency: "USD"}
+}
+
+// addTestUser creates a user with the password "s3cret" and returns its ID
+// and a session token for it
+func addTestUser(t *testing.T, s *server, username string) (int, string) {
+	t.Helper()
+	ctx := context.Background()
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := s.store.Users.CreateUser(ctx, username, string(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := createSession(ctx, s.store.Sessions, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id, token
+}
+
+// serve sends a request through the auth middleware to h, with token as the
+// bearer token unless empty. Wrap h in requireAuth as main does.
+func serve(s *server, h http.HandlerFunc, method, target, token, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.authenticate(h).ServeHTTP(w, r)
+	return w
+}
+
+func TestAuthenticate(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+	alice, token := addTestUser(t, s, "alice")
+	bob, _ := addTestUser(t, s, "bob")
+	if _, err := s.store.Groups.CreateGroup(ctx, store.Group{Name: "Bob's", Code: "BOB001", AdminID: bob}); err != nil {
+		t.Fatal(err)
+	}
+	aliceGroup, err := s.store.Groups.CreateGroup(ctx, store.Group{Name: "Alice's", Code: "ALICE1", AdminID: alice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.Sessions.CreateSession(ctx, hashToken("expired"), alice, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode int
+		wantBody string
+	}{
+		{name: "no token", wantCode: http.StatusUnauthorized, wantBody: "Authentication required"},
+		{name: "unknown token", token: "forged", wantCode: http.StatusUnauthorized, wantBody: "Invalid or expired session"},
+		{name: "expired token", token: "expired", wantCode: http.StatusUnauthorized, wantBody: "Invalid or expired session"},
+		{name: "valid token", token: token, wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		// user_id is ignored: the caller comes from the token
+		w := serve(s, requireAuth(s.myGroupsHandler), http.MethodGet, "/my-groups?user_id="+strconv.Itoa(bob), tt.token, "")
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: code %d, want %d (%s)", tt.name, w.Code, tt.wantCode, w.Body)
+			continue
+		}
+		if tt.wantCode != http.StatusOK {
+			if body := strings.TrimSpace(w.Body.String()); body != tt.wantBody {
+				t.Errorf("%s: body %q, want %q", tt.name, body, tt.wantBody)
+			}
+			continue
+		}
+		var groups []store.Group
+		if err := json.NewDecoder(w.Body).Decode(&groups); err != nil {
+			t.Fatal(err)
+		}
+		if len(groups) != 1 || groups[0].ID != aliceGroup {
+			t.Errorf("%s: groups %+v, want only alice's group %d", tt.name, groups, aliceGroup)
+		}
+	}
+}
+
+func TestLoginLogout(t *testing.T) {
+	s := newTestServer()
+	addTestUser(t, s, "alice")
+
+	w := serve(s, s.loginHandler, http.MethodPost, "/login", "", `{"username":"alice","password":"wrong"}`)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("login with a wrong password: code %d, want 401", w.Code)
+	}
+	w = serve(s, s.loginHandler, http.MethodPost, "/login", "", `{"username":"alice","password":"s3cret"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login: code %d (%s)", w.Code, w.Body)
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Token == "" {
+		t.Fatalf("login response has no token: %v", err)
+	}
+
+	if w := serve(s, requireAuth(s.myGroupsHandler), http.MethodGet, "/my-groups", resp.Token, ""); w.Code != http.StatusOK {
+		t.Errorf("my-groups with the new token: code %d (%s)", w.Code, w.Body)
+	}
+	if w := serve(s, requireAuth(s.logoutHandler), http.MethodPost, "/logout", resp.Token, ""); w.Code != http.StatusOK {
+		t.Errorf("logout: code %d (%s)", w.Code, w.Body)
+	}
+	if w := serve(s, requireAuth(s.myGroupsHandler), http.MethodGet, "/my-groups", resp.Token, ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("my-groups after logout: code %d, want 401", w.Code)
+	}
+}
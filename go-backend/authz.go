@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"go-backend/store"
+)
+
+// Role of a user within a group
+type groupRole int
+
+const (
+	roleNone groupRole = iota
+	roleMember
+	roleAdmin
+)
+
+// groupRoleOf looks up the user's role in a group from groups.admin_id and group_members
+func (s *server) groupRoleOf(ctx context.Context, groupID, userID int) (groupRole, error) {
+	group, err := s.store.Groups.GetGroup(ctx, groupID)
+	if err != nil {
+		return roleNone, err
+	}
+	if group.AdminID == userID {
+		return roleAdmin, nil
+	}
+	isMember, err := s.store.Groups.IsMember(ctx, groupID, userID)
+	if err != nil {
+		return roleNone, err
+	}
+	if isMember {
+		return roleMember, nil
+	}
+	return roleNone, nil
+}
+
+// requireGroupRole checks that the caller has at least the given role in the
+// group. On failure it writes the error response and returns false.
+func (s *server) requireGroupRole(w http.ResponseWriter, r *http.Request, groupID int, role groupRole) bool {
+	user, ok := currentUser(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return false
+	}
+	have, err := s.groupRoleOf(r.Context(), groupID, user.ID)
+	if err == store.ErrNotFound {
+		http.Error(w, "Group not found", http.StatusNotFound)
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if have < role {
+		if role == roleAdmin {
+			http.Error(w, "Only the group admin can do this", http.StatusForbidden)
+		} else {
+			http.Error(w, "You are not a member of this group", http.StatusForbidden)
+		}
+		return false
+	}
+	return true
+}
+
+// groupIDParam reads the group_id query parameter
+func groupIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	return idParam(w, r, "group_id")
+}
+
+// idParam reads a required numeric ID from the query string
+func idParam(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		http.Error(w, "Missing "+name, http.StatusBadRequest)
+		return 0, false
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil {
+		http.Error(w, "Invalid "+name, http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// lookupFailed writes the response for a failed row lookup
+func lookupFailed(w http.ResponseWriter, err error, notFound string) {
+	if err == store.ErrNotFound {
+		http.Error(w, notFound, http.StatusNotFound)
+		return
+	}
+	http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+}
+
+// authorizeTask loads a task and checks the caller's role in its group
+func (s *server) authorizeTask(w http.ResponseWriter, r *http.Request, taskID int, role groupRole) (store.Task, bool) {
+	task, err := s.store.Tasks.GetTask(r.Context(), taskID)
+	if err != nil {
+		lookupFailed(w, err, "Task not found")
+		return task, false
+	}
+	return task, s.requireGroupRole(w, r, task.GroupID, role)
+}
+
+// authorizeExpense loads an expense and checks the caller's role in its group
+func (s *server) authorizeExpense(w http.ResponseWriter, r *http.Request, expenseID int, role groupRole) (store.Expense, bool) {
+	expense, err := s.store.Expenses.GetExpense(r.Context(), expenseID)
+	if err != nil {
+		lookupFailed(w, err, "Expense not found")
+		return expense, false
+	}
+	return expense, s.requireGroupRole(w, r, expense.GroupID, role)
+}
+
+// authorizeRecurring loads a recurring expense and checks that the caller
+// belongs to its group
+func (s *server) authorizeRecurring(w http.ResponseWriter, r *http.Request, recurringID int) (store.RecurringExpense, bool) {
+	rec, err := s.store.Recurring.GetRecurring(r.Context(), recurringID)
+	if err != nil {
+		lookupFailed(w, err, "Recurring expense not found")
+		return rec, false
+	}
+	return rec, s.requireGroupRole(w, r, rec.GroupID, roleMember)
+}
+
+// authorizeDate loads a proposed date and checks the caller's role in its group
+func (s *server) authorizeDate(w http.ResponseWriter, r *http.Request, eventDateID int, role groupRole) (store.ProposedDate, bool) {
+	date, err := s.store.Dates.GetDate(r.Context(), eventDateID)
+	if err != nil {
+		lookupFailed(w, err, "Event date not found")
+		return date, false
+	}
+	return date, s.requireGroupRole(w, r, date.GroupID, role)
+}
+
+// authorizeEventChange loads an event and checks that the caller may change
+// it: its creator, or the admin of its group
+func (s *server) authorizeEventChange(w http.ResponseWriter, r *http.Request, eventID int) (store.Event, bool) {
+	event, err := s.store.Events.GetEvent(r.Context(), eventID)
+	if err != nil {
+		lookupFailed(w, err, "Event not found")
+		return event, false
+	}
+	user, _ := currentUser(r)
+	role := roleAdmin
+	if event.CreatedBy == user.ID {
+		role = roleMember
+	}
+	return event, s.requireGroupRole(w, r, event.GroupID, role)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go-backend/store"
+)
+
+func TestRequireGroupRole(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+	admin, adminToken := addTestUser(t, s, "alice")
+	member, memberToken := addTestUser(t, s, "bob")
+	_, outsiderToken := addTestUser(t, s, "mallory")
+	groupID, err := s.store.Groups.CreateGroup(ctx, store.Group{Name: "Trip", Code: "TRIP01", AdminID: admin, BaseCurrency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.Groups.AddMember(ctx, groupID, member); err != nil {
+		t.Fatal(err)
+	}
+	expenseID, err := s.store.Expenses.CreateExpense(ctx, store.Expense{GroupID: groupID, Amount: 1000, Currency: "USD",
+		Rate: 1, BaseAmount: 1000, PaidBy: admin, Date: "2026-10-01"}, []store.Split{{UserID: member, Amount: 1000, BaseAmount: 1000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const notMember, notAdmin = "You are not a member of this group", "Only the group admin can do this"
+	members := fmt.Sprintf("/group-members?group_id=%d", groupID)
+	group := fmt.Sprintf(`{"group_id":%d}`, groupID)
+	tests := []struct {
+		name     string
+		h        http.HandlerFunc
+		method   string
+		target   string
+		body     string
+		token    string
+		wantCode int
+		wantBody string // checked unless the request succeeds
+	}{
+		{name: "admin lists members", h: s.groupMembersHandler, method: http.MethodGet, target: members,
+			token: adminToken, wantCode: http.StatusOK},
+		{name: "member lists members", h: s.groupMembersHandler, method: http.MethodGet, target: members,
+			token: memberToken, wantCode: http.StatusOK},
+		{name: "outsider lists members", h: s.groupMembersHandler, method: http.MethodGet, target: members,
+			token: outsiderToken, wantCode: http.StatusForbidden, wantBody: notMember},
+		{name: "outsider lists expenses", h: s.groupExpensesHandler, method: http.MethodGet,
+			target: fmt.Sprintf("/group-expenses?group_id=%d", groupID), token: outsiderToken,
+			wantCode: http.StatusForbidden, wantBody: notMember},
+		{name: "missing group", h: s.groupMembersHandler, method: http.MethodGet,
+			target: fmt.Sprintf("/group-members?group_id=%d", groupID+1), token: memberToken,
+			wantCode: http.StatusNotFound, wantBody: "Group not found"},
+		{name: "outsider deletes an expense", h: s.deleteExpenseHandler, method: http.MethodPost, target: "/delete-expense",
+			body: fmt.Sprintf(`{"expense_id":%d}`, expenseID), token: outsiderToken,
+			wantCode: http.StatusForbidden, wantBody: notMember},
+		{name: "member removes a member", h: s.removeMemberHandler, method: http.MethodPost, target: "/remove-member",
+			body: fmt.Sprintf(`{"group_id":%d,"user_id":%d}`, groupID, admin), token: memberToken,
+			wantCode: http.StatusForbidden, wantBody: notAdmin},
+		{name: "member deletes the group", h: s.deleteGroupHandler, method: http.MethodPost, target: "/delete-group",
+			body: group, token: memberToken, wantCode: http.StatusForbidden, wantBody: notAdmin},
+		{name: "member regenerates the code", h: s.regenerateCodeHandler, method: http.MethodPost, target: "/regenerate-code",
+			body: group, token: memberToken, wantCode: http.StatusForbidden, wantBody: notAdmin},
+		{name: "admin regenerates the code", h: s.regenerateCodeHandler, method: http.MethodPost, target: "/regenerate-code",
+			body: group, token: adminToken, wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := serve(s, requireAuth(tt.h), tt.method, tt.target, tt.token, tt.body)
+		if w.Code != tt.wantCode {
+			t.Errorf("%s: code %d, want %d (%s)", tt.name, w.Code, tt.wantCode, w.Body)
+			continue
+		}
+		if tt.wantCode == http.StatusOK {
+			continue
+		}
+		if body := strings.TrimSpace(w.Body.String()); body != tt.wantBody {
+			t.Errorf("%s: body %q, want %q", tt.name, body, tt.wantBody)
+		}
+	}
+
+	// The refused writes left everything in place
+	if _, err := s.store.Expenses.GetExpense(ctx, expenseID); err != nil {
+		t.Errorf("expense after the refused delete: %v", err)
+	}
+	if ok, _ := s.store.Groups.IsMember(ctx, groupID, admin); !ok {
+		t.Error("admin was removed by a member")
+	}
+	if _, err := s.store.Groups.GetGroup(ctx, groupID); err != nil {
+		t.Errorf("group after the refused delete: %v", err)
+	}
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"go-backend/money"
+	"go-backend/store"
+)
+
+// ledger is the state of a group's money: net balances in the base currency
+type ledger struct {
+	group     store.Group
+	usernames map[int]string       // user_id -> username
+	balances  map[int]money.Amount // user_id -> net balance; positive is owed money
+}
+
+// userIDs returns the users with a balance, in ascending order
+func (l ledger) userIDs() []int {
+	ids := make([]int, 0, len(l.balances))
+	for id := range l.balances {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// loadLedger computes the net balance of every member who paid or shares an
+// expense, or took part in a settlement. The balances are summed by the
+// store in one query, however many expenses the group has.
+func (s *server) loadLedger(ctx context.Context, groupID int) (ledger, error) {
+	l := ledger{usernames: map[int]string{}}
+	group, err := s.store.Groups.GetGroup(ctx, groupID)
+	if err != nil {
+		return l, err
+	}
+	l.group = group
+	// Get all users in group
+	members, err := s.store.Groups.ListMembers(ctx, groupID)
+	if err != nil {
+		return l, err
+	}
+	for _, m := range members {
+		l.usernames[m.ID] = m.Username
+	}
+	l.balances, err = s.store.Expenses.GroupBalances(ctx, groupID)
+	return l, err
+}
+
+// Group balances: who owes whom
+func (s *server) groupBalancesHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := groupIDParam(w, r)
+	if !ok || !s.requireGroupRole(w, r, groupID, roleMember) {
+		return
+	}
+	l, err := s.loadLedger(r.Context(), groupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Prepare summary
+	summary := []map[string]interface{}{}
+	for _, uid := range l.userIDs() {
+		summary = append(summary, map[string]interface{}{
+			"user_id":  uid,
+			"username": l.usernames[uid],
+			"balance":  l.balances[uid],
+			"currency": l.group.BaseCurrency,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// transfer is one payment of a settlement plan
+type transfer struct {
+	From         int          `json:"from"`
+	FromUsername string       `json:"from_username"`
+	To           int          `json:"to"`
+	ToUsername   string       `json:"to_username"`
+	Amount       money.Amount `json:"amount"`
+}
+
+// Suggest the fewest payments that settle every balance of a group
+func (s *server) settlementPlanHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := groupIDParam(w, r)
+	if !ok || !s.requireGroupRole(w, r, groupID, roleMember) {
+		return
+	}
+	l, err := s.loadLedger(r.Context(), groupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	transfers := planSettlement(l.balances)
+	for i := range transfers {
+		transfers[i].FromUsername = l.usernames[transfers[i].From]
+		transfers[i].ToUsername = l.usernames[transfers[i].To]
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"currency": l.group.BaseCurrency, "transfers": transfers})
+}
+
+// exactPlanLimit caps the number of non-zero balances planSettlement solves
+// exactly; the search is exponential in it
+const exactPlanLimit = 16
+
+// planSettlement returns transfers that bring every balance to zero.
+// Balances must add up to zero. Amounts are exact integers, so nothing is lost
+// to rounding, and the same balances always give the same plan.
+//
+// Settling a set of people whose balances sum to zero takes one transfer
+// less than its size, so the fewest transfers come from splitting everyone
+// into as many zero-sum sets as possible. That split is found exactly for up
+// to exactPlanLimit people; larger groups settle as one set, which still
+// needs at most one transfer less than there are people.
+func planSettlement(balances map[int]money.Amount) []transfer {
+	var ids []int
+	for id, bal := range balances {
+		if bal != 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+	transfers := []transfer{}
+	if len(ids) > exactPlanLimit {
+		return settleSet(ids, balances, transfers)
+	}
+	for _, set := range zeroSumSets(ids, balances) {
+		transfers = settleSet(set, balances, transfers)
+	}
+	return transfers
+}
+
+// zeroSumSets splits ids into the largest number of sets whose balances sum
+// to zero, by dynamic programming over subsets
+func zeroSumSets(ids []int, balances map[int]money.Amount) [][]int {
+	n := len(ids)
+	full := 1<<n - 1
+	sums := make([]money.Amount, full+1)
+	best := make([]int, full+1) // most zero-sum sets the mask can be cut into
+	for mask := 1; mask <= full; mask++ {
+		low := 0
+		for mask&(1<<low) == 0 {
+			low++
+		}
+		sums[mask] = sums[mask&^(1<<low)] + balances[ids[low]]
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) != 0 && best[mask&^(1<<i)] > best[mask] {
+				best[mask] = best[mask&^(1<<i)]
+			}
+		}
+		if sums[mask] == 0 {
+			best[mask]++
+		}
+	}
+	// Peel people off the full set in an order that keeps the optimum; each
+	// time the remaining set sums to zero, the people peeled since the last
+	// cut form one set.
+	var sets [][]int
+	var current []int
+	for mask := full; mask != 0; {
+		want := best[mask]
+		if sums[mask] == 0 {
+			want--
+			if len(current) > 0 {
+				sets = append(sets, current)
+				current = nil
+			}
+		}
+		for i := 0; i < n; i++ {
+			if mask&(1<<i) != 0 && best[mask&^(1<<i)] == want {
+				current = append(current, ids[i])
+				mask &^= 1 << i
+				break
+			}
+		}
+	}
+	if len(current) > 0 {
+		sets = append(sets, current)
+	}
+	return sets
+}
+
+// settleSet appends the transfers settling a zero-sum set of people: the
+// largest debtor repeatedly pays the largest creditor, lowest user ID first
+// on ties
+func settleSet(ids []int, balances map[int]money.Amount, transfers []transfer) []transfer {
+	left := map[int]money.Amount{}
+	for _, id := range ids {
+		left[id] = balances[id]
+	}
+	sorted := append([]int(nil), ids...)
+	sort.Ints(sorted)
+	for {
+		debtor, creditor := 0, 0
+		for _, id := range sorted {
+			if left[id] < 0 && (debtor == 0 || left[id] < left[debtor]) {
+				debtor = id
+			}
+			if left[id] > 0 && (creditor == 0 || left[id] > left[creditor]) {
+				creditor = id
+			}
+		}
+		if debtor == 0 || creditor == 0 {
+			return transfers
+		}
+		amount := -left[debtor]
+		if left[creditor] < amount {
+			amount = left[creditor]
+		}
+		transfers = append(transfers, transfer{From: debtor, To: creditor, Amount: amount})
+		left[debtor] += amount
+		left[creditor] -= amount
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"go-backend/money"
+	"go-backend/store"
+)
+
+// perExpenseBalances is how balances used to be computed: one query for the
+// expenses, then one more per expense for its splits. It is kept as the
+// baseline for BenchmarkGroupBalances.
+func perExpenseBalances(ctx context.Context, st *store.Store, groupID int) (map[int]money.Amount, error) {
+	balances := map[int]money.Amount{}
+	expenses, err := st.Expenses.ListExpenses(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range expenses {
+		splits, err := st.Expenses.ListSplits(ctx, e.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, split := range splits {
+			balances[split.UserID] -= split.BaseAmount
+		}
+		balances[e.PaidBy] += e.BaseAmount
+	}
+	settlements, err := st.Settlements.ListSettlements(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range settlements {
+		balances[s.FromUserID] += s.BaseAmount
+		balances[s.ToUserID] -= s.BaseAmount
+	}
+	return balances, nil
+}
+
+// seedBenchGroup creates an in-memory store holding a group with the given
+// number of members and random expenses, some of them in a foreign currency
+func seedBenchGroup(b *testing.B, members, expenses int) (*store.Store, int) {
+	b.Helper()
+	ctx := context.Background()
+	st := store.NewMemory()
+	userIDs := make([]int, members)
+	for i := range userIDs {
+		id, err := st.Users.CreateUser(ctx, fmt.Sprintf("bench_%d", i), "")
+		if err != nil {
+			b.Fatal(err)
+		}
+		userIDs[i] = id
+	}
+	groupID, err := st.Groups.CreateGroup(ctx, store.Group{
+		Name: "Balances benchmark", AdminID: userIDs[0], Code: "BENCH", BaseCurrency: "USD",
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, id := range userIDs[1:] {
+		if err := st.Groups.AddMember(ctx, groupID, id); err != nil {
+			b.Fatal(err)
+		}
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < expenses; i++ {
+		e := store.Expense{
+			GroupID: groupID, Description: fmt.Sprintf("Expense %d", i),
+			Amount: money.Amount(10 * (100 + rng.Intn(50000))), Currency: "USD", Rate: 1,
+			PaidBy: userIDs[rng.Intn(members)], Date: "2024-01-01", SplitMode: store.SplitEqual,
+		}
+		if i%4 == 0 {
+			e.Currency, e.Rate = "EUR", 1.0837
+		}
+		e.BaseAmount = money.Convert(e.Amount, e.Rate, "USD")
+		var splitWith []int
+		for _, id := range userIDs {
+			if len(splitWith) == 0 || rng.Intn(2) == 0 {
+				splitWith = append(splitWith, id)
+			}
+		}
+		splits, err := computeSplits(store.SplitEqual, e.Amount, e.Currency, splitWith, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		setBaseAmounts(e, "USD", splits)
+		if _, err := st.Expenses.CreateExpense(ctx, e, splits); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	// Both ways of computing balances must agree before timing them
+	want, err := perExpenseBalances(ctx, st, groupID)
+	if err != nil {
+		b.Fatal(err)
+	}
+	got, err := st.Expenses.GroupBalances(ctx, groupID)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for id, bal := range want {
+		if got[id] != bal {
+			b.Fatalf("balances differ for user %d: %s per expense, %s aggregated", id, bal, got[id])
+		}
+	}
+	return st, groupID
+}
+
+func BenchmarkPerExpenseBalances(b *testing.B) {
+	st, groupID := seedBenchGroup(b, 8, 500)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := perExpenseBalances(ctx, st, groupID); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGroupBalances(b *testing.B) {
+	st, groupID := seedBenchGroup(b, 8, 500)
+	ctx := context.Background()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := st.Expenses.GroupBalances(ctx, groupID); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"go-backend/money"
+)
+
+func TestPlanSettlement(t *testing.T) {
+	tests := []struct {
+		name     string
+		balances map[int]money.Amount
+		// want is the exact plan when set; wantCount is the fewest transfers
+		want      []transfer
+		wantCount int
+	}{
+		{name: "nobody", balances: map[int]money.Amount{}, want: []transfer{}},
+		{name: "all settled", balances: map[int]money.Amount{1: 0, 2: 0}, want: []transfer{}},
+		{
+			name:     "one debt",
+			balances: map[int]money.Amount{1: 5000, 2: -5000},
+			want:     []transfer{{From: 2, To: 1, Amount: 5000}},
+		},
+		{
+			name:     "two debtors, one creditor",
+			balances: map[int]money.Amount{1: 3330, 2: -1110, 3: -2220},
+			want:     []transfer{{From: 3, To: 1, Amount: 2220}, {From: 2, To: 1, Amount: 1110}},
+		},
+		{
+			name:     "ties go to the lowest user ID",
+			balances: map[int]money.Amount{1: 1000, 2: 1000, 3: -1000, 4: -1000},
+			want:     []transfer{{From: 3, To: 1, Amount: 1000}, {From: 4, To: 2, Amount: 1000}},
+		},
+		{
+			// Largest debtor to largest creditor alone takes 4 transfers here;
+			// settling {2,5} and {1,3,4} separately takes 3
+			name:      "separate zero-sum sets",
+			balances:  map[int]money.Amount{1: 4000, 2: 3000, 3: -2000, 4: -2000, 5: -3000},
+			wantCount: 3,
+		},
+		{
+			name:      "pairs hidden among others",
+			balances:  map[int]money.Amount{1: 10, 2: -10, 3: 2500, 4: -2500, 5: 70, 6: -30, 7: -40},
+			wantCount: 4,
+		},
+		{
+			name:      "everyone in one set",
+			balances:  map[int]money.Amount{1: 6000, 2: 4000, 3: -5000, 4: -3000, 5: -2000},
+			wantCount: 4,
+		},
+		{
+			name:      "more people than the exact search handles",
+			balances:  spreadBalances(exactPlanLimit + 4),
+			wantCount: exactPlanLimit + 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := planSettlement(tt.balances)
+			if tt.want != nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("plan = %+v, want %+v", got, tt.want)
+			}
+			if tt.want == nil && len(got) != tt.wantCount {
+				t.Errorf("plan has %d transfers, want %d: %+v", len(got), tt.wantCount, got)
+			}
+			left := map[int]money.Amount{}
+			for id, bal := range tt.balances {
+				left[id] = bal
+			}
+			for _, tr := range got {
+				if tr.Amount <= 0 || tt.balances[tr.From] >= 0 || tt.balances[tr.To] <= 0 {
+					t.Errorf("transfer %+v doesn't go from a debtor to a creditor", tr)
+				}
+				left[tr.From] += tr.Amount
+				left[tr.To] -= tr.Amount
+			}
+			for id, bal := range left {
+				if bal != 0 {
+					t.Errorf("user %d is left with %s", id, bal)
+				}
+			}
+			// Map iteration order varies between runs; the plan must not
+			for i := 0; i < 20; i++ {
+				if again := planSettlement(shuffledCopy(tt.balances)); !reflect.DeepEqual(again, got) {
+					t.Fatalf("plan changed between runs: %+v, then %+v", got, again)
+				}
+			}
+		})
+	}
+}
+
+// spreadBalances returns n balances with no zero-sum subset smaller than the
+// whole group: n-1 creditors of distinct powers of two and one debtor
+func spreadBalances(n int) map[int]money.Amount {
+	balances := map[int]money.Amount{}
+	var total money.Amount
+	for id := 1; id < n; id++ {
+		balances[id] = money.Amount(10) << id
+		total += balances[id]
+	}
+	balances[n] = -total
+	return balances
+}
+
+// shuffledCopy copies balances, inserting them in a random order
+func shuffledCopy(balances map[int]money.Amount) map[int]money.Amount {
+	ids := make([]int, 0, len(balances))
+	for id := range balances {
+		ids = append(ids, id)
+	}
+	rand.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+	out := make(map[int]money.Amount, len(ids))
+	for _, id := range ids {
+		out[id] = balances[id]
+	}
+	return out
+}
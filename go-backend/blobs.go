@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// blobStore keeps uploaded files (expense receipts) outside the database.
+// Keys are slash-separated paths chosen by the server.
+type blobStore interface {
+	// Put stores the content of r under key, replacing any existing blob
+	Put(key string, r io.Reader) error
+	// Open returns the blob's content; errBlobNotFound if there is none
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes a blob; deleting a missing blob is not an error
+	Delete(key string) error
+}
+
+var errBlobNotFound = errors.New("blob not found")
+
+// localBlobs stores each blob as a file under dir
+type localBlobs struct {
+	dir string
+}
+
+func newLocalBlobs(dir string) (*localBlobs, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("creating blob directory: %w", err)
+	}
+	return &localBlobs{dir: dir}, nil
+}
+
+// path maps a key to its file, refusing keys that would escape dir
+func (l *localBlobs) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+// Put writes to a temporary file first, so a failed upload never leaves a
+// partial blob behind
+func (l *localBlobs) Put(key string, r io.Reader) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *localBlobs) Open(key string) (io.ReadCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errBlobNotFound
+	}
+	return f, err
+}
+
+func (l *localBlobs) Delete(key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// newBlobStore builds the storage selected in the config. The local
+// filesystem is the only provider so far; others (e.g. S3) go here.
+func newBlobStore(cfg BlobsConfig) (blobStore, error) {
+	return newLocalBlobs(cfg.Dir)
+}
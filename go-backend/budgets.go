@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"go-backend/money"
+	"go-backend/store"
+)
+
+// budgetStatus is a budget with what has been spent against it
+type budgetStatus struct {
+	store.Budget
+	Spent     money.Amount `json:"spent"`
+	Remaining money.Amount `json:"remaining"` // negative once over budget
+	Over      bool         `json:"over_budget"`
+}
+
+// spentOn is what a group's expenses in report count against budget b: all
+// of them for the whole-group budget, else those of its category
+func spentOn(b store.Budget, report store.ExpenseReport) money.Amount {
+	if b.Category == "" {
+		return report.Total
+	}
+	for _, c := range report.ByCategory {
+		if c.Category == b.Category {
+			return c.Total
+		}
+	}
+	return 0
+}
+
+// describeBudget names a budget in messages
+func describeBudget(b store.Budget) string {
+	if b.Category == "" {
+		return "Group spending"
+	}
+	return "Spending on " + b.Category
+}
+
+// checkBudgets records an alert, and notifies the group, for every budget
+// the new expenses pushed over its limit, naming the expense that crossed
+// it. The expenses are taken in order and must already be saved; budgets
+// that were over before them don't alert again. Failures are logged: the
+// expenses are saved.
+func (s *server) checkBudgets(ctx context.Context, group store.Group, expenses ...store.Expense) {
+	budgets, err := s.store.Budgets.ListBudgets(ctx, group.ID)
+	if err != nil {
+		fmt.Println("[DEBUG] Could not load budgets:", err)
+		return
+	}
+	if len(budgets) == 0 {
+		return
+	}
+	report, err := s.store.Expenses.Report(ctx, store.ReportFilter{GroupID: group.ID})
+	if err != nil {
+		fmt.Println("[DEBUG] Could not total expenses for budgets:", err)
+		return
+	}
+	for _, b := range budgets {
+		var counted []store.Expense
+		var added money.Amount
+		for _, e := range expenses {
+			if b.Category == "" || b.Category == e.Category {
+				counted = append(counted, e)
+				added += e.BaseAmount
+			}
+		}
+		spent := spentOn(b, report)
+		if len(counted) == 0 || spent <= b.Limit || spent-added > b.Limit {
+			continue
+		}
+		// Find the expense that went over the limit
+		crossed, running := counted[0], spent-added
+		for _, e := range counted {
+			if running += e.BaseAmount; running > b.Limit {
+				crossed = e
+				break
+			}
+		}
+		alert := store.BudgetAlert{BudgetID: b.ID, GroupID: group.ID, Category: b.Category, Limit: b.Limit, Spent: spent, ExpenseID: crossed.ID}
+		if _, err := s.store.Budgets.AddBudgetAlert(ctx, alert); err != nil {
+			fmt.Println("[DEBUG] Could not record budget alert:", err)
+			continue
+		}
+		s.notifyGroup(ctx, group.ID, "budget_exceeded", fmt.Sprintf("%s is %s %s, over its budget of %s %s",
+			describeBudget(b), money.Format(spent, group.BaseCurrency), group.BaseCurrency,
+			money.Format(b.Limit, group.BaseCurrency), group.BaseCurrency))
+	}
+}
+
+// Set the group's budget for a category, or for all expenses when category
+// is empty, in the base currency (admin only). Setting it again replaces
+// the limit.
+func (s *server) setBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		GroupID  int          `json:"group_id"`
+		Category string       `json:"category"`
+		Limit    money.Amount `json:"limit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Limit <= 0 {
+		http.Error(w, "Limit must be positive", http.StatusBadRequest)
+		return
+	}
+	if !s.requireGroupRole(w, r, req.GroupID, roleAdmin) {
+		return
+	}
+	category, ok := s.checkCategory(w, r, req.GroupID, req.Category)
+	if !ok {
+		return
+	}
+	group, err := s.store.Groups.GetGroup(r.Context(), req.GroupID)
+	if err != nil {
+		lookupFailed(w, err, "Group not found")
+		return
+	}
+	if err := money.Check(req.Limit, group.BaseCurrency); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	user, _ := currentUser(r)
+	b := store.Budget{GroupID: req.GroupID, Category: category, Limit: req.Limit, CreatedBy: user.ID}
+	id, err := s.store.Budgets.SetBudget(r.Context(), b)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": id})
+}
+
+// Budgets of a group with spent and remaining amounts, computed from its
+// expenses
+func (s *server) groupBudgetsHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := groupIDParam(w, r)
+	if !ok || !s.requireGroupRole(w, r, groupID, roleMember) {
+		return
+	}
+	group, err := s.store.Groups.GetGroup(r.Context(), groupID)
+	if err != nil {
+		lookupFailed(w, err, "Group not found")
+		return
+	}
+	budgets, err := s.store.Budgets.ListBudgets(r.Context(), groupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	report, err := s.store.Expenses.Report(r.Context(), store.ReportFilter{GroupID: groupID})
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	statuses := make([]budgetStatus, len(budgets))
+	for i, b := range budgets {
+		spent := spentOn(b, report)
+		statuses[i] = budgetStatus{Budget: b, Spent: spent, Remaining: b.Limit - spent, Over: spent > b.Limit}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"currency": group.BaseCurrency,
+		"spent":    report.Total,
+		"budgets":  statuses,
+	})
+}
+
+// Remove a budget and its alerts (admin only)
+func (s *server) deleteBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		BudgetID int `json:"budget_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	b, err := s.store.Budgets.GetBudget(r.Context(), req.BudgetID)
+	if err != nil {
+		lookupFailed(w, err, "Budget not found")
+		return
+	}
+	if !s.requireGroupRole(w, r, b.GroupID, roleAdmin) {
+		return
+	}
+	if err := s.store.Budgets.DeleteBudget(r.Context(), b.ID); err != nil {
+		lookupFailed(w, err, "Budget not found")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
+}
+
+// List the overspend alerts of a group, newest first
+func (s *server) budgetAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := groupIDParam(w, r)
+	if !ok || !s.requireGroupRole(w, r, groupID, roleMember) {
+		return
+	}
+	alerts, err := s.store.Budgets.ListBudgetAlerts(r.Context(), groupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if alerts == nil {
+		alerts = []store.BudgetAlert{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alerts)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go-backend/money"
+	"go-backend/store"
+)
+
+func TestCheckBudgets(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+	alice, _ := addTestUser(t, s, "alice")
+	groupID, err := s.store.Groups.CreateGroup(ctx, store.Group{Name: "Trip", Code: "TRIP01", AdminID: alice, BaseCurrency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	group, _ := s.store.Groups.GetGroup(ctx, groupID)
+	total, err := s.store.Budgets.SetBudget(ctx, store.Budget{GroupID: groupID, Limit: 10000, CreatedBy: alice})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.store.Budgets.SetBudget(ctx, store.Budget{GroupID: groupID, Category: "food", Limit: 2000, CreatedBy: alice}); err != nil {
+		t.Fatal(err)
+	}
+	// add saves expenses as one batch, like an import, and checks them
+	add := func(amounts ...money.Amount) []store.Expense {
+		var saved []store.Expense
+		for _, a := range amounts {
+			e := store.Expense{GroupID: groupID, Amount: a, Currency: "USD", Rate: 1, BaseAmount: a, PaidBy: alice,
+				Date: "2026-10-01", Category: "travel"}
+			if e.ID, err = s.store.Expenses.CreateExpense(ctx, e, nil); err != nil {
+				t.Fatal(err)
+			}
+			saved = append(saved, e)
+		}
+		s.checkBudgets(ctx, group, saved...)
+		return saved
+	}
+
+	add(4000)
+	// Reaching the limit is fine; the third expense goes over it
+	batch := add(5000, 1000, 3000, 2000)
+	alerts, err := s.store.Budgets.ListBudgetAlerts(ctx, groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("alerts = %+v, want one for the whole-group budget", alerts)
+	}
+	if a := alerts[0]; a.BudgetID != total || a.ExpenseID != batch[2].ID || a.Spent != 15000 {
+		t.Errorf("alert = %+v, want budget %d, expense %d and 15.00 spent", a, total, batch[2].ID)
+	}
+
+	// Already over: no second alert
+	add(1000)
+	if alerts, _ := s.store.Budgets.ListBudgetAlerts(ctx, groupID); len(alerts) != 1 {
+		t.Errorf("alerts after another expense = %+v, want still one", alerts)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go-backend/store"
+)
+
+// Every new group starts with these categories (migration 0013 seeds them
+// for groups created before the catalogue existed)
+var defaultCategories = []string{"food", "travel", "lodging", "transport", "entertainment", "other"}
+
+const maxCategoryLen = 64
+
+func normalizeCategory(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// seedCategories adds the default categories to a new group
+func seedCategories(r *http.Request, categories store.CategoryStore, groupID int) error {
+	for _, name := range defaultCategories {
+		if _, err := categories.AddCategory(r.Context(), groupID, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkCategory normalizes an expense's category and checks that it is in
+// the group's catalogue. An empty category leaves the expense uncategorized.
+func (s *server) checkCategory(w http.ResponseWriter, r *http.Request, groupID int, name string) (string, bool) {
+	name = normalizeCategory(name)
+	if name == "" {
+		return "", true
+	}
+	categories, err := s.store.Categories.ListCategories(r.Context(), groupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return "", false
+	}
+	for _, c := range categories {
+		if c.Name == name {
+			return name, true
+		}
+	}
+	http.Error(w, "Unknown category "+name+"; add it to the group first", http.StatusBadRequest)
+	return "", false
+}
+
+// List a group's expense categories
+func (s *server) groupCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := groupIDParam(w, r)
+	if !ok || !s.requireGroupRole(w, r, groupID, roleMember) {
+		return
+	}
+	categories, err := s.store.Categories.ListCategories(r.Context(), groupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if categories == nil {
+		categories = []store.Category{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(categories)
+}
+
+// Add a category to a group's catalogue (any member)
+func (s *server) addCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		GroupID int    `json:"group_id"`
+		Name    string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	name := normalizeCategory(req.Name)
+	if name == "" || len(name) > maxCategoryLen {
+		http.Error(w, fmt.Sprintf("Category name must be 1-%d characters", maxCategoryLen), http.StatusBadRequest)
+		return
+	}
+	if !s.requireGroupRole(w, r, req.GroupID, roleMember) {
+		return
+	}
+	id, err := s.store.Categories.AddCategory(r.Context(), req.GroupID, name)
+	if err == store.ErrConflict {
+		http.Error(w, "Category already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(store.Category{ID: id, GroupID: req.GroupID, Name: name})
+}
+
+// Remove a category from a group's catalogue (admin only). Categories still
+// used by an expense, recurring expense or budget can't be removed.
+func (s *server) deleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		CategoryID int `json:"category_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	category, err := s.store.Categories.GetCategory(r.Context(), req.CategoryID)
+	if err != nil {
+		lookupFailed(w, err, "Category not found")
+		return
+	}
+	if !s.requireGroupRole(w, r, category.GroupID, roleAdmin) {
+		return
+	}
+	err = s.store.Categories.DeleteCategory(r.Context(), category.ID)
+	if err == store.ErrConflict {
+		http.Error(w, "Category is still used by expenses or budgets", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		lookupFailed(w, err, "Category not found")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
+}
+
+// Totals per category, member and month for a group's expenses, in the
+// group's base currency, optionally limited to dates from..to (inclusive)
+func (s *server) expenseReportHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := groupIDParam(w, r)
+	if !ok {
+		return
+	}
+	filter := store.ReportFilter{GroupID: groupID, From: r.URL.Query().Get("from"), To: r.URL.Query().Get("to")}
+	if (filter.From != "" && !isISODate(filter.From)) || (filter.To != "" && !isISODate(filter.To)) {
+		http.Error(w, "Invalid from/to (want YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+	if filter.From != "" && filter.To != "" && filter.From > filter.To {
+		http.Error(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+	if !s.requireGroupRole(w, r, groupID, roleMember) {
+		return
+	}
+	group, err := s.store.Groups.GetGroup(r.Context(), groupID)
+	if err != nil {
+		lookupFailed(w, err, "Group not found")
+		return
+	}
+	report, err := s.store.Expenses.Report(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"currency":    group.BaseCurrency,
+		"from":        filter.From,
+		"to":          filter.To,
+		"total":       report.Total,
+		"count":       report.Count,
+		"by_category": report.ByCategory,
+		"by_member":   report.ByMember,
+		"by_month":    report.ByMonth,
+	})
+}
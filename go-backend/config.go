@@ -0,0 +1,214 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the server settings. Values come from the defaults below,
+// then an optional YAML file, then environment variables (highest priority).
+type Config struct {
+	ListenAddr string `yaml:"listen_addr"`
+	// Storage backend: "mysql" (default) or "memory" for local dev
+	Storage string   `yaml:"storage"`
+	DB      DBConfig `yaml:"db"`
+	// How often background jobs (closing expired polls) run
+	SchedulerInterval time.Duration `yaml:"scheduler_interval"`
+	// Base currency of new groups (ISO 4217)
+	DefaultCurrency string      `yaml:"default_currency"`
+	Rates           RatesConfig `yaml:"rates"`
+	Blobs           BlobsConfig `yaml:"blobs"`
+}
+
+// BlobsConfig selects where uploaded files such as receipts are kept
+type BlobsConfig struct {
+	// "local" (default): files under Dir on the server's filesystem
+	Provider string `yaml:"provider"`
+	Dir      string `yaml:"dir"`
+}
+
+// RatesConfig selects where exchange rates come from
+type RatesConfig struct {
+	// "manual" (default): clients send exchange_rate with foreign expenses.
+	// "file": rates are read from File, a JSON document (see rates.example.json).
+	Provider string `yaml:"provider"`
+	File     string `yaml:"file"`
+}
+
+// DBConfig describes the MySQL connection and pool
+type DBConfig struct {
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	Name     string `yaml:"name"`
+
+	// TLS mode: "false", "true", "skip-verify" or "preferred".
+	// When TLSCAFile is set the server certificate is verified against it.
+	TLS       string `yaml:"tls"`
+	TLSCAFile string `yaml:"tls_ca_file"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time"`
+}
+
+func defaultConfig() Config {
+	return Config{
+		ListenAddr: "127.0.0.1:8085",
+		Storage:    "mysql",
+		DB: DBConfig{
+			Host:            "127.0.0.1",
+			Port:            "3306",
+			User:            "root",
+			Name:            "letshangoutapp1",
+			TLS:             "false",
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		SchedulerInterval: time.Minute,
+		DefaultCurrency:   "USD",
+		Rates:             RatesConfig{Provider: "manual"},
+		Blobs:             BlobsConfig{Provider: "local", Dir: "data/blobs"},
+	}
+}
+
+// loadConfig builds the configuration from the defaults, the YAML file at
+// path (skipped when empty) and the environment.
+func loadConfig(path string) (Config, error) {
+	cfg := defaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return cfg, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return cfg, err
+	}
+	if cfg.Storage != "mysql" && cfg.Storage != "memory" {
+		return cfg, fmt.Errorf("unknown storage %q (want mysql or memory)", cfg.Storage)
+	}
+	if cfg.SchedulerInterval <= 0 {
+		return cfg, fmt.Errorf("scheduler_interval must be positive")
+	}
+	if !isCurrencyCode(cfg.DefaultCurrency) {
+		return cfg, fmt.Errorf("invalid default_currency %q (want an ISO 4217 code such as USD)", cfg.DefaultCurrency)
+	}
+	if cfg.Rates.Provider != "manual" && cfg.Rates.Provider != "file" {
+		return cfg, fmt.Errorf("unknown rates provider %q (want manual or file)", cfg.Rates.Provider)
+	}
+	if cfg.Rates.Provider == "file" && cfg.Rates.File == "" {
+		return cfg, fmt.Errorf("rates provider file needs rates.file (RATES_FILE)")
+	}
+	if cfg.Blobs.Provider != "local" {
+		return cfg, fmt.Errorf("unknown blobs provider %q (want local)", cfg.Blobs.Provider)
+	}
+	if cfg.Blobs.Dir == "" {
+		return cfg, fmt.Errorf("blobs provider local needs blobs.dir (BLOBS_DIR)")
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides settings with the environment variables used by
+// go-deployment.yaml and the docker setup
+func (c *Config) applyEnv() error {
+	if v := os.Getenv("LISTEN_ADDR"); v != "" {
+		c.ListenAddr = v
+	} else if v := os.Getenv("PORT"); v != "" {
+		// PORT only changes the port of the configured listen address
+		host, _, err := net.SplitHostPort(c.ListenAddr)
+		if err != nil {
+			return fmt.Errorf("invalid listen address %q: %w", c.ListenAddr, err)
+		}
+		c.ListenAddr = net.JoinHostPort(host, v)
+	}
+	stringVars := map[string]*string{
+		"STORAGE":        &c.Storage,
+		"DB_HOST":        &c.DB.Host,
+		"DB_PORT":        &c.DB.Port,
+		"DB_USER":        &c.DB.User,
+		"DB_PASSWORD":    &c.DB.Password,
+		"DB_NAME":        &c.DB.Name,
+		"DB_TLS":         &c.DB.TLS,
+		"DB_TLS_CA_FILE": &c.DB.TLSCAFile,
+
+		"DEFAULT_CURRENCY": &c.DefaultCurrency,
+		"RATES_PROVIDER":   &c.Rates.Provider,
+		"RATES_FILE":       &c.Rates.File,
+		"BLOBS_PROVIDER":   &c.Blobs.Provider,
+		"BLOBS_DIR":        &c.Blobs.Dir,
+	}
+	for name, dst := range stringVars {
+		if v, ok := os.LookupEnv(name); ok {
+			*dst = v
+		}
+	}
+	intVars := map[string]*int{
+		"DB_MAX_OPEN_CONNS": &c.DB.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS": &c.DB.MaxIdleConns,
+	}
+	for name, dst := range intVars {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*dst = n
+		}
+	}
+	durationVars := map[string]*time.Duration{
+		"DB_CONN_MAX_LIFETIME":  &c.DB.ConnMaxLifetime,
+		"DB_CONN_MAX_IDLE_TIME": &c.DB.ConnMaxIdleTime,
+		"SCHEDULER_INTERVAL":    &c.SchedulerInterval,
+	}
+	for name, dst := range durationVars {
+		if v := os.Getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s: %w", name, err)
+			}
+			*dst = d
+		}
+	}
+	return nil
+}
+
+// mysqlConfig converts the settings into a driver config
+func (c DBConfig) mysqlConfig() (*mysql.Config, error) {
+	mc := mysql.NewConfig()
+	mc.User = c.User
+	mc.Passwd = c.Password
+	mc.Net = "tcp"
+	mc.Addr = net.JoinHostPort(c.Host, c.Port)
+	mc.DBName = c.Name
+	mc.TLSConfig = c.TLS
+	// Report matched rather than changed rows, so an UPDATE that leaves a
+	// row as it was still counts as finding it
+	mc.ClientFoundRows = true
+	if c.TLSCAFile != "" {
+		pem, err := os.ReadFile(c.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading DB CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.TLSCAFile)
+		}
+		mc.TLS = &tls.Config{RootCAs: pool, ServerName: c.Host}
+	}
+	return mc, nil
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go-backend/money"
+	"go-backend/store"
+)
+
+// splitInput is one participant of an unequal split. Which field is read
+// depends on the split mode.
+type splitInput struct {
+	UserID  int          `json:"user_id"`
+	Amount  money.Amount `json:"amount"`  // exact
+	Percent float64      `json:"percent"` // percentage
+	Shares  float64      `json:"shares"`  // shares
+}
+
+// weightScale turns percentages and share counts into integer weights,
+// keeping four decimals (e.g. 33.3333%)
+const weightScale = 10000
+
+func scaledWeight(w float64) int64 {
+	return int64(math.Round(w * weightScale))
+}
+
+// computeSplits divides amount, in currency, between the participants
+// according to mode. Equal splits use splitWith; the other modes use inputs.
+// Percentage and share splits spread leftover minor units deterministically,
+// so the splits always add up to amount. The returned error is meant for the
+// client.
+func computeSplits(mode string, amount money.Amount, currency string, splitWith []int, inputs []splitInput) ([]store.Split, error) {
+	if err := money.Check(amount, currency); err != nil {
+		return nil, err
+	}
+	if mode == store.SplitEqual {
+		if len(inputs) > 0 && len(splitWith) == 0 {
+			// Allow {"splits":[{"user_id":1},...]} for equal splits too
+			for _, in := range inputs {
+				splitWith = append(splitWith, in.UserID)
+			}
+		}
+		if err := checkParticipants(splitWith); err != nil {
+			return nil, err
+		}
+		var splits []store.Split
+		for i, part := range money.SplitEvenly(amount, len(splitWith), currency) {
+			splits = append(splits, store.Split{UserID: splitWith[i], Amount: part})
+		}
+		return splits, nil
+	}
+
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("splits are required for %s splits", mode)
+	}
+	ids := make([]int, len(inputs))
+	for i, in := range inputs {
+		ids[i] = in.UserID
+	}
+	if err := checkParticipants(ids); err != nil {
+		return nil, err
+	}
+	splits := make([]store.Split, len(inputs))
+	weights := make([]int64, len(inputs))
+	switch mode {
+	case store.SplitExact:
+		var total money.Amount
+		for i, in := range inputs {
+			if in.Amount < 0 {
+				return nil, errors.New("split amounts cannot be negative")
+			}
+			if err := money.Check(in.Amount, currency); err != nil {
+				return nil, err
+			}
+			splits[i] = store.Split{UserID: in.UserID, Amount: in.Amount}
+			total += in.Amount
+		}
+		if total != amount {
+			return nil, fmt.Errorf("split amounts add up to %s, not the expense amount %s", total, amount)
+		}
+		return splits, nil
+	case store.SplitPercentage:
+		var total int64
+		for i, in := range inputs {
+			if in.Percent < 0 {
+				return nil, errors.New("split percentages cannot be negative")
+			}
+			splits[i] = store.Split{UserID: in.UserID, Weight: in.Percent}
+			weights[i] = scaledWeight(in.Percent)
+			total += weights[i]
+		}
+		if total != 100*weightScale {
+			return nil, fmt.Errorf("split percentages add up to %g, not 100", float64(total)/weightScale)
+		}
+	case store.SplitShares:
+		for i, in := range inputs {
+			weights[i] = scaledWeight(in.Shares)
+			if weights[i] <= 0 {
+				return nil, errors.New("every participant needs a positive number of shares")
+			}
+			splits[i] = store.Split{UserID: in.UserID, Weight: in.Shares}
+		}
+	default:
+		return nil, fmt.Errorf("unknown split mode %q (want equal, exact, percentage or shares)", mode)
+	}
+	for i, part := range money.Allocate(amount, weights, currency) {
+		splits[i].Amount = part
+	}
+	return splits, nil
+}
+
+// checkParticipants rejects missing and duplicate user IDs
+func checkParticipants(ids []int) error {
+	seen := map[int]bool{}
+	for _, id := range ids {
+		if id == 0 {
+			return errors.New("every split needs a user_id")
+		}
+		if seen[id] {
+			return fmt.Errorf("user %d appears twice in the split", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// requireMembers checks that every split participant belongs to the group.
+// On failure it writes the error response and returns false.
+func (s *server) requireMembers(w http.ResponseWriter, r *http.Request, groupID int, splits []store.Split) bool {
+	members, err := s.store.Groups.ListMembers(r.Context(), groupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	isMember := map[int]bool{}
+	for _, m := range members {
+		isMember[m.ID] = true
+	}
+	for _, split := range splits {
+		if !isMember[split.UserID] {
+			http.Error(w, fmt.Sprintf("User %d is not a member of this group", split.UserID), http.StatusBadRequest)
+			return false
+		}
+	}
+	return true
+}
+
+// setBaseAmounts fills in each split's share of the expense's base amount,
+// in the group's base currency. The parts are allocated in proportion to the
+// original splits, so they still add up exactly and balances can be summed
+// without converting.
+func setBaseAmounts(e store.Expense, base string, splits []store.Split) {
+	if e.BaseAmount == e.Amount {
+		for i := range splits {
+			splits[i].BaseAmount = splits[i].Amount
+		}
+		return
+	}
+	weights := make([]int64, len(splits))
+	for i, split := range splits {
+		weights[i] = int64(split.Amount)
+	}
+	for i, part := range money.Allocate(e.BaseAmount, weights, base) {
+		splits[i].BaseAmount = part
+	}
+}
+
+// resplitInputs rebuilds split inputs for mode from an expense's current
+// splits, to re-split a changed amount between the same participants
+func resplitInputs(mode string, splits []store.Split) ([]int, []splitInput) {
+	if mode == store.SplitEqual {
+		ids := make([]int, len(splits))
+		for i, split := range splits {
+			ids[i] = split.UserID
+		}
+		return ids, nil
+	}
+	inputs := make([]splitInput, len(splits))
+	for i, split := range splits {
+		inputs[i] = splitInput{UserID: split.UserID, Amount: split.Amount, Percent: split.Weight, Shares: split.Weight}
+	}
+	return nil, inputs
+}
+
+// formatSplits describes splits for the expense history, by user ID
+func formatSplits(splits []store.Split) string {
+	sorted := append([]store.Split(nil), splits...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].UserID < sorted[j].UserID })
+	parts := make([]string, len(sorted))
+	for i, split := range sorted {
+		parts[i] = fmt.Sprintf("user %d: %s", split.UserID, split.Amount)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// expenseChanges lists the fields that differ between two versions of an expense
+func expenseChanges(old store.Expense, oldSplits []store.Split, updated store.Expense, splits []store.Split) []store.ExpenseChange {
+	var changes []store.ExpenseChange
+	diff := func(field, before, after string) {
+		if before != after {
+			changes = append(changes, store.ExpenseChange{Field: field, Old: before, New: after})
+		}
+	}
+	diff("description", old.Description, updated.Description)
+	diff("amount", old.Amount.String(), updated.Amount.String())
+	diff("currency", old.Currency, updated.Currency)
+	diff("exchange_rate", strconv.FormatFloat(old.Rate, 'f', -1, 64), strconv.FormatFloat(updated.Rate, 'f', -1, 64))
+	diff("paid_by", strconv.Itoa(old.PaidBy), strconv.Itoa(updated.PaidBy))
+	diff("date", old.Date, updated.Date)
+	diff("category", old.Category, updated.Category)
+	diff("split_mode", old.SplitMode, updated.SplitMode)
+	diff("splits", formatSplits(oldSplits), formatSplits(splits))
+	return changes
+}
+
+// Edit an expense. Omitted fields keep their value; a new amount or split
+// mode without new splits re-splits between the same participants. The
+// expense and its splits change together, and the edit is recorded in the
+// expense's history.
+func (s *server) updateExpenseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ExpenseID    int          `json:"expense_id"`
+		Description  string       `json:"description"`
+		Amount       money.Amount `json:"amount"`
+		PaidBy       int          `json:"paid_by"`
+		Date         string       `json:"date"`
+		Category     string       `json:"category"`
+		Currency     string       `json:"currency"`
+		ExchangeRate float64      `json:"exchange_rate"`
+		SplitMode    string       `json:"split_mode"`
+		SplitWith    []int        `json:"split_with"`
+		Splits       []splitInput `json:"splits"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Amount < 0 {
+		http.Error(w, "Amount must be positive", http.StatusBadRequest)
+		return
+	}
+	if req.Date != "" && !isISODate(req.Date) {
+		http.Error(w, "Invalid date (want YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+	old, ok := s.authorizeExpense(w, r, req.ExpenseID, roleMember)
+	if !ok {
+		return
+	}
+	oldSplits, err := s.store.Expenses.ListSplits(r.Context(), old.ID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	updated := old
+	if req.Description != "" {
+		updated.Description = req.Description
+	}
+	if req.Amount != 0 {
+		updated.Amount = req.Amount
+	}
+	if req.Date != "" {
+		updated.Date = req.Date
+	}
+	if req.Category != "" {
+		if updated.Category, ok = s.checkCategory(w, r, old.GroupID, req.Category); !ok {
+			return
+		}
+	}
+	if req.SplitMode != "" {
+		updated.SplitMode = req.SplitMode
+	}
+	if req.Currency != "" {
+		updated.Currency = req.Currency
+	}
+	group, err := s.store.Groups.GetGroup(r.Context(), old.GroupID)
+	if err != nil {
+		lookupFailed(w, err, "Group not found")
+		return
+	}
+
+	splits := oldSplits
+	if updated.Amount != old.Amount || updated.SplitMode != old.SplitMode || updated.Currency != old.Currency ||
+		req.SplitWith != nil || req.Splits != nil {
+		splitWith, inputs := req.SplitWith, req.Splits
+		if splitWith == nil && inputs == nil && (updated.SplitMode == old.SplitMode || updated.SplitMode == store.SplitEqual) {
+			splitWith, inputs = resplitInputs(updated.SplitMode, oldSplits)
+		}
+		if splits, err = computeSplits(updated.SplitMode, updated.Amount, updated.Currency, splitWith, inputs); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !s.requireMembers(w, r, old.GroupID, splits) {
+			return
+		}
+	}
+	if req.PaidBy != 0 && req.PaidBy != old.PaidBy {
+		isMember, err := s.store.Groups.IsMember(r.Context(), old.GroupID, req.PaidBy)
+		if err != nil {
+			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !isMember {
+			http.Error(w, "paid_by must be a member of the group", http.StatusBadRequest)
+			return
+		}
+		updated.PaidBy = req.PaidBy
+	}
+	if req.Currency != "" || req.ExchangeRate != 0 {
+		if updated.Rate, ok = s.exchangeRate(w, updated.Currency, group.BaseCurrency, req.ExchangeRate); !ok {
+			return
+		}
+	}
+	updated.BaseAmount = money.Convert(updated.Amount, updated.Rate, group.BaseCurrency)
+	setBaseAmounts(updated, group.BaseCurrency, splits)
+
+	changes := expenseChanges(old, oldSplits, updated, splits)
+	if len(changes) == 0 {
+		http.Error(w, "No fields to update", http.StatusBadRequest)
+		return
+	}
+	user, _ := currentUser(r)
+	err = s.store.Expenses.UpdateExpense(r.Context(), updated, splits, store.ExpenseRevision{ChangedBy: user.ID, Changes: changes})
+	if err != nil {
+		lookupFailed(w, err, "Expense not found")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
+}
+
+// List the edits of an expense, newest first
+func (s *server) expenseHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	expenseID, ok := idParam(w, r, "expense_id")
+	if !ok {
+		return
+	}
+	if _, ok := s.authorizeExpense(w, r, expenseID, roleMember); !ok {
+		return
+	}
+	history, err := s.store.Expenses.ListExpenseHistory(r.Context(), expenseID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if history == nil {
+		history = []store.ExpenseRevision{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"go-backend/money"
+	"go-backend/store"
+)
+
+func TestComputeSplits(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		amount    money.Amount
+		currency  string
+		splitWith []int
+		inputs    []splitInput
+		want      []store.Split
+		wantErr   string
+	}{
+		{
+			name: "equal with leftover cent", mode: store.SplitEqual, amount: 10000, currency: "USD",
+			splitWith: []int{1, 2, 3},
+			want:      []store.Split{{UserID: 1, Amount: 3340}, {UserID: 2, Amount: 3330}, {UserID: 3, Amount: 3330}},
+		},
+		{
+			name: "equal from split inputs", mode: store.SplitEqual, amount: 1001000, currency: "JPY",
+			inputs: []splitInput{{UserID: 4}, {UserID: 2}},
+			want:   []store.Split{{UserID: 4, Amount: 501000}, {UserID: 2, Amount: 500000}},
+		},
+		{
+			name: "equal with a duplicate user", mode: store.SplitEqual, amount: 10000, currency: "USD",
+			splitWith: []int{1, 2, 1},
+			wantErr:   "user 1 appears twice in the split",
+		},
+		{
+			name: "equal with a missing user", mode: store.SplitEqual, amount: 10000, currency: "USD",
+			splitWith: []int{1, 0},
+			wantErr:   "every split needs a user_id",
+		},
+		{
+			name: "amount finer than the currency allows", mode: store.SplitEqual, amount: 10500, currency: "JPY",
+			splitWith: []int{1, 2},
+			wantErr:   "JPY amounts can't have decimals",
+		},
+		{
+			name: "exact", mode: store.SplitExact, amount: 10000, currency: "USD",
+			inputs: []splitInput{{UserID: 1, Amount: 6000}, {UserID: 2, Amount: 4000}},
+			want:   []store.Split{{UserID: 1, Amount: 6000}, {UserID: 2, Amount: 4000}},
+		},
+		{
+			name: "exact with a zero part", mode: store.SplitExact, amount: 10000, currency: "USD",
+			inputs: []splitInput{{UserID: 1, Amount: 10000}, {UserID: 2}},
+			want:   []store.Split{{UserID: 1, Amount: 10000}, {UserID: 2}},
+		},
+		{
+			name: "exact total below the amount", mode: store.SplitExact, amount: 10000, currency: "USD",
+			inputs:  []splitInput{{UserID: 1, Amount: 6000}, {UserID: 2, Amount: 3000}},
+			wantErr: "split amounts add up to 9.00, not the expense amount 10.00",
+		},
+		{
+			name: "exact total above the amount", mode: store.SplitExact, amount: 10000, currency: "USD",
+			inputs:  []splitInput{{UserID: 1, Amount: 6000}, {UserID: 2, Amount: 4010}},
+			wantErr: "split amounts add up to 10.01, not the expense amount 10.00",
+		},
+		{
+			name: "exact negative part", mode: store.SplitExact, amount: 10000, currency: "USD",
+			inputs:  []splitInput{{UserID: 1, Amount: 12000}, {UserID: 2, Amount: -2000}},
+			wantErr: "split amounts cannot be negative",
+		},
+		{
+			name: "exact part finer than the currency allows", mode: store.SplitExact, amount: 1000000, currency: "JPY",
+			inputs:  []splitInput{{UserID: 1, Amount: 499500}, {UserID: 2, Amount: 500500}},
+			wantErr: "JPY amounts can't have decimals",
+		},
+		{
+			name: "exact without splits", mode: store.SplitExact, amount: 10000, currency: "USD",
+			splitWith: []int{1, 2},
+			wantErr:   "splits are required for exact splits",
+		},
+		{
+			name: "percentage thirds", mode: store.SplitPercentage, amount: 10000, currency: "USD",
+			inputs: []splitInput{{UserID: 1, Percent: 33.3333}, {UserID: 2, Percent: 33.3333}, {UserID: 3, Percent: 33.3334}},
+			want: []store.Split{
+				{UserID: 1, Amount: 3330, Weight: 33.3333},
+				{UserID: 2, Amount: 3330, Weight: 33.3333},
+				{UserID: 3, Amount: 3340, Weight: 33.3334},
+			},
+		},
+		{
+			name: "percentage with a zero part", mode: store.SplitPercentage, amount: 10000, currency: "USD",
+			inputs: []splitInput{{UserID: 1, Percent: 100}, {UserID: 2}},
+			want:   []store.Split{{UserID: 1, Amount: 10000, Weight: 100}, {UserID: 2}},
+		},
+		{
+			name: "percentages below 100", mode: store.SplitPercentage, amount: 10000, currency: "USD",
+			inputs:  []splitInput{{UserID: 1, Percent: 50}, {UserID: 2, Percent: 40}},
+			wantErr: "split percentages add up to 90, not 100",
+		},
+		{
+			name: "percentages above 100", mode: store.SplitPercentage, amount: 10000, currency: "USD",
+			inputs:  []splitInput{{UserID: 1, Percent: 60}, {UserID: 2, Percent: 40.5}},
+			wantErr: "split percentages add up to 100.5, not 100",
+		},
+		{
+			name: "negative percentage", mode: store.SplitPercentage, amount: 10000, currency: "USD",
+			inputs:  []splitInput{{UserID: 1, Percent: 110}, {UserID: 2, Percent: -10}},
+			wantErr: "split percentages cannot be negative",
+		},
+		{
+			name: "shares with leftover cent", mode: store.SplitShares, amount: 100, currency: "USD",
+			inputs: []splitInput{{UserID: 1, Shares: 1}, {UserID: 2, Shares: 2}},
+			want:   []store.Split{{UserID: 1, Amount: 30, Weight: 1}, {UserID: 2, Amount: 70, Weight: 2}},
+		},
+		{
+			name: "fractional shares in yen", mode: store.SplitShares, amount: 1000000, currency: "JPY",
+			inputs: []splitInput{{UserID: 1, Shares: 0.5}, {UserID: 2, Shares: 1}},
+			want:   []store.Split{{UserID: 1, Amount: 333000, Weight: 0.5}, {UserID: 2, Amount: 667000, Weight: 1}},
+		},
+		{
+			name: "zero shares", mode: store.SplitShares, amount: 10000, currency: "USD",
+			inputs:  []splitInput{{UserID: 1, Shares: 1}, {UserID: 2, Shares: 0}},
+			wantErr: "every participant needs a positive number of shares",
+		},
+		{
+			name: "negative shares", mode: store.SplitShares, amount: 10000, currency: "USD",
+			inputs:  []splitInput{{UserID: 1, Shares: 2}, {UserID: 2, Shares: -1}},
+			wantErr: "every participant needs a positive number of shares",
+		},
+		{
+			name: "unknown mode", mode: "ratio", amount: 10000, currency: "USD",
+			inputs:  []splitInput{{UserID: 1}},
+			wantErr: `unknown split mode "ratio" (want equal, exact, percentage or shares)`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := computeSplits(tt.mode, tt.amount, tt.currency, tt.splitWith, tt.inputs)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splits = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -5,6 +5,9 @@ go 1.21.0
 toolchain go1.23.9
 
 require (
-	filippo.io/edwards25519 v1.1.0 // indirect
-	github.com/go-sql-driver/mysql v1.9.3 // indirect
+	github.com/go-sql-driver/mysql v1.9.3
+	golang.org/x/crypto v0.31.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require filippo.io/edwards25519 v1.1.0 // indirect
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-backend/money"
+	"go-backend/store"
+)
+
+// The CSV ledger has one row per expense: these columns, then one
+// "split:<username>" column per participant. A split cell holds what the
+// split mode reads: the amount for equal and exact splits, the percentage or
+// number of shares otherwise. For equal splits any non-empty cell counts.
+var ledgerColumns = []string{"id", "date", "description", "category", "amount", "currency", "exchange_rate", "base_amount", "paid_by", "split_mode"}
+
+const splitColumnPrefix = "split:"
+
+// maxImportBytes caps the size of an uploaded CSV ledger
+const maxImportBytes = 1 << 20
+
+// ledgerExpense is an expense with its splits, as exported in JSON
+type ledgerExpense struct {
+	store.Expense
+	Splits []store.Split `json:"splits"`
+}
+
+// importError is a problem with one row of an imported CSV; row 1 is the header
+type importError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ledgerUsernames maps the group's members, and anyone else who appears in
+// its expenses (e.g. a removed member), to their usernames
+func (s *server) ledgerUsernames(ctx context.Context, members []store.User, expenses []ledgerExpense) (map[int]string, error) {
+	usernames := map[int]string{}
+	for _, m := range members {
+		usernames[m.ID] = m.Username
+	}
+	for _, e := range expenses {
+		ids := []int{e.PaidBy}
+		for _, split := range e.Splits {
+			ids = append(ids, split.UserID)
+		}
+		for _, id := range ids {
+			if _, ok := usernames[id]; ok {
+				continue
+			}
+			user, err := s.store.Users.GetUser(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			usernames[id] = user.Username
+		}
+	}
+	return usernames, nil
+}
+
+// groupLedger loads what an export holds: the group's members, its expenses
+// with their splits (oldest first) and the usernames of everyone in them
+func (s *server) groupLedger(ctx context.Context, groupID int) ([]store.User, []ledgerExpense, map[int]string, error) {
+	members, err := s.store.Groups.ListMembers(ctx, groupID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	list, err := s.store.Expenses.ListExpenses(ctx, groupID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	splits, err := s.store.Expenses.GroupSplits(ctx, groupID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// Oldest first, the order a ledger is read in
+	expenses := make([]ledgerExpense, len(list))
+	for i, e := range list {
+		expenseSplits := splits[e.ID]
+		sort.Slice(expenseSplits, func(a, b int) bool { return expenseSplits[a].UserID < expenseSplits[b].UserID })
+		if expenseSplits == nil {
+			expenseSplits = []store.Split{}
+		}
+		expenses[len(list)-1-i] = ledgerExpense{Expense: e, Splits: expenseSplits}
+	}
+	usernames, err := s.ledgerUsernames(ctx, members, expenses)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return members, expenses, usernames, nil
+}
+
+// Spreadsheets run a cell starting with one of these as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// textCell escapes a free-text CSV cell so a spreadsheet shows it as text:
+// a leading formula character gets a ' in front, which the import strips.
+// Text that already looks escaped is escaped again so it survives that.
+func textCell(s string) string {
+	if formulaAfterQuotes(s) {
+		return "'" + s
+	}
+	return s
+}
+
+// unescapeCell undoes textCell
+func unescapeCell(s string) string {
+	if strings.HasPrefix(s, "'") && formulaAfterQuotes(s) {
+		return s[1:]
+	}
+	return s
+}
+
+// formulaAfterQuotes reports whether s starts with a formula character once
+// its leading 's are skipped: those are the cells textCell escapes
+func formulaAfterQuotes(s string) bool {
+	rest := strings.TrimLeft(s, "'")
+	return rest != "" && strings.ContainsRune(formulaPrefixes, rune(rest[0]))
+}
+
+// splitCell formats a split of an expense in currency the way the CSV
+// import reads it back
+func splitCell(mode, currency string, split store.Split) string {
+	if mode == store.SplitPercentage || mode == store.SplitShares {
+		return strconv.FormatFloat(split.Weight, 'f', -1, 64)
+	}
+	return money.Format(split.Amount, currency)
+}
+
+// Export a group's expenses with their splits: format=csv (default) for
+// spreadsheets, or format=json for the full ledger including settlements
+func (s *server) exportExpensesHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := groupIDParam(w, r)
+	if !ok {
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		http.Error(w, "Invalid format (want csv or json)", http.StatusBadRequest)
+		return
+	}
+	if !s.requireGroupRole(w, r, groupID, roleMember) {
+		return
+	}
+	ctx := r.Context()
+	group, err := s.store.Groups.GetGroup(ctx, groupID)
+	if err != nil {
+		lookupFailed(w, err, "Group not found")
+		return
+	}
+	members, expenses, usernames, err := s.groupLedger(ctx, groupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	filename := fmt.Sprintf("group-%d-expenses-%s.%s", groupID, time.Now().Format("2006-01-02"), format)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`"`)
+
+	if format == "json" {
+		settlements, err := s.store.Settlements.ListSettlements(ctx, groupID)
+		if err != nil {
+			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if settlements == nil {
+			settlements = []store.Settlement{}
+		}
+		if members == nil {
+			members = []store.User{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"group":       map[string]interface{}{"id": group.ID, "name": group.Name, "base_currency": group.BaseCurrency},
+			"members":     members,
+			"usernames":   usernames,
+			"expenses":    expenses,
+			"settlements": settlements,
+			"exported_at": time.Now().UTC().Format(time.RFC3339),
+		})
+		return
+	}
+
+	// One split column per user who has a split, in user ID order
+	var splitUsers []int
+	seen := map[int]bool{}
+	for _, e := range expenses {
+		for _, split := range e.Splits {
+			if !seen[split.UserID] {
+				seen[split.UserID] = true
+				splitUsers = append(splitUsers, split.UserID)
+			}
+		}
+	}
+	sort.Ints(splitUsers)
+	header := append([]string(nil), ledgerColumns...)
+	column := map[int]int{} // user ID -> column index
+	for _, id := range splitUsers {
+		column[id] = len(header)
+		header = append(header, splitColumnPrefix+usernames[id])
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	out := csv.NewWriter(w)
+	out.Write(header)
+	for _, e := range expenses {
+		row := make([]string, len(header))
+		copy(row, []string{
+			strconv.Itoa(e.ID), e.Date, textCell(e.Description), textCell(e.Category), money.Format(e.Amount, e.Currency), e.Currency,
+			strconv.FormatFloat(e.Rate, 'f', -1, 64), money.Format(e.BaseAmount, group.BaseCurrency), textCell(usernames[e.PaidBy]), e.SplitMode,
+		})
+		for _, split := range e.Splits {
+			row[column[split.UserID]] = splitCell(e.SplitMode, e.Currency, split)
+		}
+		out.Write(row)
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		fmt.Println("[DEBUG] exportExpensesHandler: writing CSV:", err)
+	}
+}
+
+// importRow turns one CSV row into an expense and its splits. col maps the
+// header's column names to indexes; splitCols maps split columns to user
+// IDs. The returned error is meant for the client.
+func (s *server) importRow(row []string, col map[string]int, splitCols map[int]int, group store.Group,
+	userIDs map[string]int, categories map[string]bool, callerID int) (store.Expense, []store.Split, error) {
+	if len(row) != len(col)+len(splitCols) {
+		return store.Expense{}, nil, fmt.Errorf("has %d fields, the header has %d", len(row), len(col)+len(splitCols))
+	}
+	get := func(name string) string {
+		if i, ok := col[name]; ok {
+			return unescapeCell(strings.TrimSpace(row[i]))
+		}
+		return ""
+	}
+	e := store.Expense{
+		GroupID: group.ID, Description: get("description"), Date: get("date"),
+		Currency: strings.ToUpper(get("currency")), SplitMode: get("split_mode"), PaidBy: callerID,
+	}
+	if !isISODate(e.Date) {
+		return e, nil, fmt.Errorf("invalid date %q (want YYYY-MM-DD)", e.Date)
+	}
+	amount, err := money.Parse(get("amount"))
+	if err != nil {
+		return e, nil, fmt.Errorf("invalid amount %q", get("amount"))
+	}
+	if amount <= 0 {
+		return e, nil, fmt.Errorf("amount must be positive")
+	}
+	e.Amount = amount
+	if e.Category = normalizeCategory(get("category")); e.Category != "" && !categories[e.Category] {
+		return e, nil, fmt.Errorf("unknown category %s", e.Category)
+	}
+	if paidBy := get("paid_by"); paidBy != "" {
+		id, ok := userIDs[paidBy]
+		if !ok {
+			return e, nil, fmt.Errorf("paid_by %s is not in this group", paidBy)
+		}
+		e.PaidBy = id
+	}
+	if e.Currency == "" {
+		e.Currency = group.BaseCurrency
+	}
+	var clientRate float64
+	if raw := get("exchange_rate"); raw != "" {
+		if clientRate, err = strconv.ParseFloat(raw, 64); err != nil {
+			return e, nil, fmt.Errorf("invalid exchange_rate %q", raw)
+		}
+	}
+	if e.Rate, err = s.resolveRate(e.Currency, group.BaseCurrency, clientRate); err != nil {
+		if _, ok := err.(clientError); !ok {
+			err = fmt.Errorf("exchange rate error: %w", err)
+		}
+		return e, nil, err
+	}
+	e.BaseAmount = money.Convert(e.Amount, e.Rate, group.BaseCurrency)
+	if e.SplitMode == "" {
+		e.SplitMode = store.SplitEqual
+	}
+
+	var splitWith []int
+	var inputs []splitInput
+	columns := make([]int, 0, len(splitCols))
+	for i := range splitCols {
+		columns = append(columns, i)
+	}
+	sort.Ints(columns)
+	for _, i := range columns {
+		cell := strings.TrimSpace(row[i])
+		if cell == "" {
+			continue
+		}
+		in := splitInput{UserID: splitCols[i]}
+		switch e.SplitMode {
+		case store.SplitEqual:
+			splitWith = append(splitWith, in.UserID)
+			continue
+		case store.SplitExact:
+			if in.Amount, err = money.Parse(cell); err != nil {
+				return e, nil, fmt.Errorf("invalid split amount %q", cell)
+			}
+		default:
+			weight, err := strconv.ParseFloat(cell, 64)
+			if err != nil {
+				return e, nil, fmt.Errorf("invalid split %q", cell)
+			}
+			in.Percent, in.Shares = weight, weight
+		}
+		inputs = append(inputs, in)
+	}
+	if len(splitWith) == 0 && len(inputs) == 0 {
+		return e, nil, fmt.Errorf("no split columns are filled in")
+	}
+	splits, err := computeSplits(e.SplitMode, e.Amount, e.Currency, splitWith, inputs)
+	if err != nil {
+		return e, nil, err
+	}
+	setBaseAmounts(e, group.BaseCurrency, splits)
+	return e, splits, nil
+}
+
+// Import expenses from a CSV ledger (the export's format; the id and
+// base_amount columns are ignored). Payers and split columns name current
+// members, or former ones who are already in the group's expenses. Every
+// row is checked first: if any row is invalid nothing is imported and every
+// problem is reported, otherwise all rows are inserted in one transaction.
+func (s *server) importExpensesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	groupID, ok := groupIDParam(w, r)
+	if !ok || !s.requireGroupRole(w, r, groupID, roleMember) {
+		return
+	}
+	ctx := r.Context()
+	reader := csv.NewReader(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1 // ragged rows are reported per row
+	rows, err := reader.ReadAll()
+	if err != nil {
+		http.Error(w, "Invalid CSV: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(rows) < 2 {
+		http.Error(w, "No expenses to import", http.StatusBadRequest)
+		return
+	}
+	group, err := s.store.Groups.GetGroup(ctx, groupID)
+	if err != nil {
+		lookupFailed(w, err, "Group not found")
+		return
+	}
+	// An export names former members too, so they are accepted back
+	_, _, usernames, err := s.groupLedger(ctx, groupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	userIDs := map[string]int{}
+	for id, username := range usernames {
+		userIDs[username] = id
+	}
+	catalogue, err := s.store.Categories.ListCategories(ctx, groupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	categories := map[string]bool{}
+	for _, c := range catalogue {
+		categories[c.Name] = true
+	}
+
+	// Header problems make every row meaningless, so they're reported alone
+	known := map[string]bool{}
+	for _, name := range ledgerColumns {
+		known[name] = true
+	}
+	col := map[string]int{}
+	splitCols := map[int]int{} // column index -> user ID
+	seen := map[string]bool{}
+	var errs []importError
+	for i, name := range rows[0] {
+		name = unescapeCell(strings.TrimSpace(name))
+		if seen[name] {
+			errs = append(errs, importError{Row: 1, Error: "duplicate column " + name})
+			continue
+		}
+		seen[name] = true
+		if username, ok := strings.CutPrefix(name, splitColumnPrefix); ok {
+			id, inGroup := userIDs[username]
+			if !inGroup {
+				errs = append(errs, importError{Row: 1, Error: fmt.Sprintf("column %s: %s is not in this group", name, username)})
+			}
+			splitCols[i] = id
+			continue
+		}
+		if !known[name] {
+			errs = append(errs, importError{Row: 1, Error: "unknown column " + name})
+			continue
+		}
+		col[name] = i
+	}
+	for _, name := range []string{"date", "amount"} {
+		if _, ok := col[name]; !ok {
+			errs = append(errs, importError{Row: 1, Error: "missing column " + name})
+		}
+	}
+	if len(splitCols) == 0 {
+		errs = append(errs, importError{Row: 1, Error: "no split:<username> columns"})
+	}
+
+	user, _ := currentUser(r)
+	type imported struct {
+		expense store.Expense
+		splits  []store.Split
+	}
+	var expenses []imported
+	if len(errs) == 0 {
+		for i, row := range rows[1:] {
+			e, splits, err := s.importRow(row, col, splitCols, group, userIDs, categories, user.ID)
+			if err != nil {
+				errs = append(errs, importError{Row: i + 2, Error: err.Error()})
+				continue
+			}
+			expenses = append(expenses, imported{e, splits})
+		}
+	}
+	if len(errs) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"imported": 0, "errors": errs})
+		return
+	}
+
+	ids := make([]int, 0, len(expenses))
+	err = s.store.WithTx(ctx, func(tx *store.Store) error {
+		for i, e := range expenses {
+			id, err := tx.Expenses.CreateExpense(ctx, e.expense, e.splits)
+			if err != nil {
+				return err
+			}
+			expenses[i].expense.ID = id
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	saved := make([]store.Expense, len(expenses))
+	for i, e := range expenses {
+		saved[i] = e.expense
+	}
+	s.checkBudgets(ctx, group, saved...)
+	fmt.Println("[DEBUG] Imported", len(ids), "expenses into group", groupID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"imported": len(ids), "ids": ids, "errors": []importError{}})
+}
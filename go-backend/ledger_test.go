@@ -0,0 +1,221 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go-backend/money"
+	"go-backend/store"
+)
+
+func TestImportRow(t *testing.T) {
+	// The export's header followed by split:alice and split:bob
+	col := map[string]int{}
+	for i, name := range ledgerColumns {
+		col[name] = i
+	}
+	splitCols := map[int]int{len(ledgerColumns): 1, len(ledgerColumns) + 1: 2}
+	group := store.Group{ID: 7, BaseCurrency: "USD"}
+	userIDs := map[string]int{"alice": 1, "bob": 2, "@home": 3}
+	categories := map[string]bool{"food": true}
+	const callerID = 2
+	s := &server{rates: manualRates{}}
+
+	// row fills in the columns a test cares about; the rest stay empty
+	row := func(cells map[string]string, alice, bob string) []string {
+		r := make([]string, len(ledgerColumns)+2)
+		for name, v := range cells {
+			r[col[name]] = v
+		}
+		r[len(ledgerColumns)], r[len(ledgerColumns)+1] = alice, bob
+		return r
+	}
+
+	tests := []struct {
+		name       string
+		row        []string
+		want       store.Expense
+		wantSplits []store.Split
+		wantErr    string
+	}{
+		{
+			name: "equal split in the base currency",
+			row: row(map[string]string{"id": "12", "date": "2026-10-01", "description": "Dinner", "category": " Food ",
+				"amount": "30.01", "base_amount": "99", "paid_by": "alice"}, "15.01", "15.00"),
+			want: store.Expense{GroupID: 7, Description: "Dinner", Date: "2026-10-01", Category: "food",
+				Amount: 30010, Currency: "USD", Rate: 1, BaseAmount: 30010, PaidBy: 1, SplitMode: store.SplitEqual},
+			wantSplits: []store.Split{{UserID: 1, Amount: 15010, BaseAmount: 15010}, {UserID: 2, Amount: 15000, BaseAmount: 15000}},
+		},
+		{
+			name: "payer defaults to the caller and only filled columns split",
+			row:  row(map[string]string{"date": "2026-10-01", "amount": "8", "split_mode": "equal"}, "", "x"),
+			want: store.Expense{GroupID: 7, Date: "2026-10-01", Amount: 8000, Currency: "USD", Rate: 1, BaseAmount: 8000,
+				PaidBy: callerID, SplitMode: store.SplitEqual},
+			wantSplits: []store.Split{{UserID: 2, Amount: 8000, BaseAmount: 8000}},
+		},
+		{
+			name: "exact split in yen converted to the base currency",
+			row: row(map[string]string{"date": "2026-10-01", "amount": "1001", "currency": "jpy", "exchange_rate": "0.0067",
+				"paid_by": "bob", "split_mode": "exact"}, "501", "500"),
+			want: store.Expense{GroupID: 7, Date: "2026-10-01", Amount: 1001000, Currency: "JPY", Rate: 0.0067, BaseAmount: 6710,
+				PaidBy: 2, SplitMode: store.SplitExact},
+			wantSplits: []store.Split{{UserID: 1, Amount: 501000, BaseAmount: 3360}, {UserID: 2, Amount: 500000, BaseAmount: 3350}},
+		},
+		{
+			name: "percentage split",
+			row:  row(map[string]string{"date": "2026-10-01", "amount": "10", "split_mode": "percentage"}, "62.5", "37.5"),
+			want: store.Expense{GroupID: 7, Date: "2026-10-01", Amount: 10000, Currency: "USD", Rate: 1, BaseAmount: 10000,
+				PaidBy: callerID, SplitMode: store.SplitPercentage},
+			wantSplits: []store.Split{{UserID: 1, Amount: 6250, BaseAmount: 6250, Weight: 62.5}, {UserID: 2, Amount: 3750, BaseAmount: 3750, Weight: 37.5}},
+		},
+		{
+			name: "escaped formula cells",
+			row: row(map[string]string{"date": "2026-10-01", "description": "'=SUM(A1)", "amount": "5",
+				"paid_by": "'@home"}, "5.00", ""),
+			want: store.Expense{GroupID: 7, Description: "=SUM(A1)", Date: "2026-10-01", Amount: 5000, Currency: "USD", Rate: 1,
+				BaseAmount: 5000, PaidBy: 3, SplitMode: store.SplitEqual},
+			wantSplits: []store.Split{{UserID: 1, Amount: 5000, BaseAmount: 5000}},
+		},
+		{
+			name: "a leading quote on plain text is kept",
+			row:  row(map[string]string{"date": "2026-10-01", "description": "'90s night", "amount": "5"}, "x", ""),
+			want: store.Expense{GroupID: 7, Description: "'90s night", Date: "2026-10-01", Amount: 5000, Currency: "USD", Rate: 1,
+				BaseAmount: 5000, PaidBy: callerID, SplitMode: store.SplitEqual},
+			wantSplits: []store.Split{{UserID: 1, Amount: 5000, BaseAmount: 5000}},
+		},
+		{
+			name:    "quoted name that export wouldn't quote",
+			row:     row(map[string]string{"date": "2026-10-01", "amount": "5", "paid_by": "'alice"}, "x", ""),
+			wantErr: "paid_by 'alice is not in this group",
+		},
+		{name: "short row", row: []string{"1", "2026-10-01", "x"}, wantErr: "has 3 fields, the header has 12"},
+		{
+			name:    "invalid date",
+			row:     row(map[string]string{"date": "2026-02-30", "amount": "5"}, "x", ""),
+			wantErr: `invalid date "2026-02-30" (want YYYY-MM-DD)`,
+		},
+		{
+			name:    "invalid amount",
+			row:     row(map[string]string{"date": "2026-10-01", "amount": "5,00"}, "x", ""),
+			wantErr: `invalid amount "5,00"`,
+		},
+		{
+			name:    "negative amount",
+			row:     row(map[string]string{"date": "2026-10-01", "amount": "-5"}, "x", ""),
+			wantErr: "amount must be positive",
+		},
+		{
+			name:    "unknown category",
+			row:     row(map[string]string{"date": "2026-10-01", "amount": "5", "category": "Rent"}, "x", ""),
+			wantErr: "unknown category rent",
+		},
+		{
+			name:    "payer not in the group",
+			row:     row(map[string]string{"date": "2026-10-01", "amount": "5", "paid_by": "zed"}, "x", ""),
+			wantErr: "paid_by zed is not in this group",
+		},
+		{
+			name:    "invalid currency",
+			row:     row(map[string]string{"date": "2026-10-01", "amount": "5", "currency": "EURO"}, "x", ""),
+			wantErr: "Invalid currency (want an ISO 4217 code such as USD)",
+		},
+		{
+			name:    "foreign currency without a rate",
+			row:     row(map[string]string{"date": "2026-10-01", "amount": "5", "currency": "EUR"}, "x", ""),
+			wantErr: "exchange_rate is required: no EUR to USD rate is available",
+		},
+		{
+			name:    "invalid rate",
+			row:     row(map[string]string{"date": "2026-10-01", "amount": "5", "currency": "EUR", "exchange_rate": "1,1"}, "x", ""),
+			wantErr: `invalid exchange_rate "1,1"`,
+		},
+		{
+			name:    "rate other than 1 for the base currency",
+			row:     row(map[string]string{"date": "2026-10-01", "amount": "5", "exchange_rate": "1.1"}, "x", ""),
+			wantErr: "exchange_rate must be 1 for the group's base currency",
+		},
+		{
+			name:    "decimals in yen",
+			row:     row(map[string]string{"date": "2026-10-01", "amount": "10.5", "currency": "JPY", "exchange_rate": "0.0067"}, "x", ""),
+			wantErr: "JPY amounts can't have decimals",
+		},
+		{
+			name:    "no split columns",
+			row:     row(map[string]string{"date": "2026-10-01", "amount": "5"}, "", " "),
+			wantErr: "no split columns are filled in",
+		},
+		{
+			name:    "invalid exact split",
+			row:     row(map[string]string{"date": "2026-10-01", "amount": "5", "split_mode": "exact"}, "2.5", "half"),
+			wantErr: `invalid split amount "half"`,
+		},
+		{
+			name:    "exact splits off the total",
+			row:     row(map[string]string{"date": "2026-10-01", "amount": "5", "split_mode": "exact"}, "2.50", "2.49"),
+			wantErr: "split amounts add up to 4.99, not the expense amount 5.00",
+		},
+		{
+			name:    "percentages off 100",
+			row:     row(map[string]string{"date": "2026-10-01", "amount": "5", "split_mode": "percentage"}, "50", "49"),
+			wantErr: "split percentages add up to 99, not 100",
+		},
+		{
+			name:    "invalid share count",
+			row:     row(map[string]string{"date": "2026-10-01", "amount": "5", "split_mode": "shares"}, "1", "two"),
+			wantErr: `invalid split "two"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, splits, err := s.importRow(tt.row, col, splitCols, group, userIDs, categories, callerID)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(e, tt.want) {
+				t.Errorf("expense = %+v, want %+v", e, tt.want)
+			}
+			if !reflect.DeepEqual(splits, tt.wantSplits) {
+				t.Errorf("splits = %+v, want %+v", splits, tt.wantSplits)
+			}
+			var base money.Amount
+			for _, split := range splits {
+				base += split.BaseAmount
+			}
+			if base != e.BaseAmount {
+				t.Errorf("split base amounts add up to %s, want %s", base, e.BaseAmount)
+			}
+		})
+	}
+}
+
+func TestTextCell(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "", want: ""},
+		{in: "Dinner", want: "Dinner"},
+		{in: "=SUM(A1)", want: "'=SUM(A1)"},
+		{in: "@home", want: "'@home"},
+		{in: "-5", want: "'-5"},
+		{in: "'90s night", want: "'90s night"},
+		{in: "'=SUM(A1)", want: "''=SUM(A1)"},
+		{in: "''", want: "''"},
+		{in: strings.Repeat("'", 100000) + "=1", want: strings.Repeat("'", 100001) + "=1"},
+	}
+	for _, tt := range tests {
+		got := textCell(tt.in)
+		if got != tt.want {
+			t.Errorf("textCell(%.20q) = %.20q, want %.20q", tt.in, got, tt.want)
+		}
+		if back := unescapeCell(got); back != tt.in {
+			t.Errorf("unescapeCell(%.20q) = %.20q, want %.20q", got, back, tt.in)
+		}
+	}
+}
@@ -3,14 +3,18 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"math/rand"
 	"net/http"
 	"os"
-	"strings"
+	"sort"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+
+	"go-backend/money"
+	"go-backend/store"
 )
 
 // Enable CORS middleware
@@ -18,7 +22,7 @@ func enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -41,29 +45,18 @@ func rootHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Welcome to Letshangout API! Use /api endpoint for data.")
 }
 
-// User and Group data structures
-type User struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-}
-
-type Group struct {
-	ID      int    `json:"id"`
-	Name    string `json:"name"`
-	Code    string `json:"code"`
-	Members []int  `json:"members"` // User IDs
-	AdminID int    `json:"admin_id"`
+// server carries the dependencies shared by the HTTP handlers
+type server struct {
+	store *store.Store
+	rates rateProvider
+	// Base currency of groups created without one
+	defaultCurrency string
+	// Uploaded receipts
+	blobs blobStore
 }
 
-var users = make(map[int]User)
-var groups = make(map[int]Group)
-var userIDCounter = 1
-var groupIDCounter = 1
-var db *sql.DB // Global DB connection
-
 // Handler for user registration
-func registerHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) registerHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -79,43 +72,57 @@ func registerHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	fmt.Println("[DEBUG] Registering user:", req.Username)
 	// Check if username already exists
-	var exists int
-	var existingID int
-	err := db.QueryRow("SELECT id FROM users WHERE username = ?", req.Username).Scan(&existingID)
+	existing, err := s.store.Users.GetUserByUsername(r.Context(), req.Username)
 	if err == nil {
 		// User exists, return their ID
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{"id": existingID, "username": req.Username})
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": existing.ID, "username": req.Username})
 		return
 	}
-	err = db.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", req.Username).Scan(&exists)
-	if err != nil {
+	if err != store.ErrNotFound {
 		fmt.Println("[DEBUG] DB error on SELECT:", err)
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if exists > 0 {
-		fmt.Println("[DEBUG] Username already exists:", req.Username)
-		http.Error(w, "Username already exists", http.StatusConflict)
+	if req.Password == "" {
+		http.Error(w, "Missing password", http.StatusBadRequest)
+		return
+	}
+	if len(req.Password) > maxPasswordBytes {
+		http.Error(w, fmt.Sprintf("Password too long (max %d bytes)", maxPasswordBytes), http.StatusBadRequest)
 		return
 	}
-	// Insert user into MySQL
-	result, err := db.Exec("INSERT INTO users (username, password) VALUES (?, ?)", req.Username, req.Password)
+	hash, err := hashPassword(req.Password)
 	if err != nil {
-		fmt.Println("[DEBUG] DB error on INSERT:", err)
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		fmt.Println("[DEBUG] Password hashing failed:", err)
+		http.Error(w, "Could not register user", http.StatusInternalServerError)
+		return
+	}
+	// The user and their first session are created together
+	var id int
+	var token string
+	var expiresAt time.Time
+	err = s.store.WithTx(r.Context(), func(tx *store.Store) error {
+		var err error
+		if id, err = tx.Users.CreateUser(r.Context(), req.Username, hash); err != nil {
+			return err
+		}
+		token, expiresAt, err = createSession(r.Context(), tx.Sessions, id)
+		return err
+	})
+	if err == store.ErrConflict {
+		fmt.Println("[DEBUG] Username already exists:", req.Username)
+		http.Error(w, "Username already exists", http.StatusConflict)
 		return
 	}
-	id, err := result.LastInsertId()
 	if err != nil {
-		fmt.Println("[DEBUG] DB error on LastInsertId:", err)
+		fmt.Println("[DEBUG] DB error on INSERT:", err)
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 	fmt.Println("[DEBUG] User registered with ID:", id)
-	user := User{ID: int(id), Username: req.Username, Password: req.Password}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "username": req.Username, "token": token, "expires_at": expiresAt})
 }
 
 func generateGroupCode(n int) string {
@@ -128,177 +135,354 @@ func generateGroupCode(n int) string {
 	return string(b)
 }
 
-func createGroupHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) createGroupHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	var req struct {
-		Name     string      `json:"name"`
-		UserID   interface{} `json:"user_id"`
-		Username string      `json:"username"`
+		Name     string `json:"name"`
+		Username string `json:"username"`
+		Currency string `json:"currency"` // base currency, defaults to the server's
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
+	if req.Currency == "" {
+		req.Currency = s.defaultCurrency
+	}
+	if !isCurrencyCode(req.Currency) {
+		http.Error(w, "Invalid currency (want an ISO 4217 code such as USD)", http.StatusBadRequest)
+		return
+	}
 	code := generateGroupCode(6)
-	var userID int
-	// Check if user exists
-	switch v := req.UserID.(type) {
-	case float64:
-		// Try to find user by ID
-		err := db.QueryRow("SELECT id FROM users WHERE id = ?", int(v)).Scan(&userID)
-		if err != nil {
-			// Not found, treat as guest
-			if req.Username == "" {
-				http.Error(w, "Missing username for guest", http.StatusBadRequest)
-				return
-			}
-			result, err := db.Exec("INSERT INTO users (username, password) VALUES (?, '')", req.Username)
+	user, loggedIn := currentUser(r)
+	if !loggedIn && req.Username == "" {
+		http.Error(w, "Missing username for guest", http.StatusBadRequest)
+		return
+	}
+	userID := user.ID
+	var groupID int
+	var guestToken string
+	usernameTaken := false
+	// Not logged in: a guest user and their session are created together
+	// with the group, so a failure leaves no orphaned guest behind
+	err := s.store.WithTx(r.Context(), func(tx *store.Store) error {
+		if !loggedIn {
+			id, err := tx.Users.CreateUser(r.Context(), req.Username, "")
 			if err != nil {
-				http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-				return
+				usernameTaken = err == store.ErrConflict
+				return err
+			}
+			userID = id
+			if guestToken, _, err = createSession(r.Context(), tx.Sessions, userID); err != nil {
+				return err
 			}
-			id, _ := result.LastInsertId()
-			userID = int(id)
-		}
-	case string:
-		// Guest user, create in DB
-		if req.Username == "" {
-			http.Error(w, "Missing username for guest", http.StatusBadRequest)
-			return
 		}
-		result, err := db.Exec("INSERT INTO users (username, password) VALUES (?, '')", req.Username)
+		var err error
+		groupID, err = tx.Groups.CreateGroup(r.Context(), store.Group{Name: req.Name, Code: code, AdminID: userID, BaseCurrency: req.Currency})
 		if err != nil {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-			return
+			return err
 		}
-		id, _ := result.LastInsertId()
-		userID = int(id)
-	default:
-		http.Error(w, "Invalid user_id", http.StatusBadRequest)
-		return
-	}
-	result, err := db.Exec("INSERT INTO `groups` (name, code, admin_id) VALUES (?, ?, ?)", req.Name, code, userID)
-	if err != nil {
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return seedCategories(r, tx.Categories, groupID)
+	})
+	if usernameTaken {
+		http.Error(w, "Username already exists", http.StatusConflict)
 		return
 	}
-	groupID, err := result.LastInsertId()
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	// Add creator to group_members
-	if userID > 0 {
-		_, err = db.Exec("INSERT INTO group_members (group_id, user_id) VALUES (?, ?)", groupID, userID)
-		if err != nil {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
+	resp := map[string]interface{}{"id": groupID, "name": req.Name, "code": code, "admin_id": userID, "base_currency": req.Currency}
+	if guestToken != "" {
+		resp["token"] = guestToken
 	}
-	resp := map[string]interface{}{"id": groupID, "name": req.Name, "code": code, "admin_id": userID}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
 
-func joinGroupHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) joinGroupHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	var req struct {
-		Code   string `json:"code"`
-		UserID int    `json:"user_id"`
+		Code string `json:"code"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	var groupID int
-	err := db.QueryRow("SELECT id FROM `groups` WHERE code = ?", req.Code).Scan(&groupID)
+	user, _ := currentUser(r)
+	userID := user.ID
+	group, err := s.store.Groups.GetGroupByCode(r.Context(), req.Code)
 	if err != nil {
 		http.Error(w, "Invalid group code", http.StatusNotFound)
 		return
 	}
-	// Check if user is already a member
-	var exists int
-	err = db.QueryRow("SELECT COUNT(*) FROM group_members WHERE group_id = ? AND user_id = ?", groupID, req.UserID).Scan(&exists)
+	groupID := group.ID
+	// Add user to group_members; a conflict means they already belong to it
+	err = s.store.Groups.AddMember(r.Context(), groupID, userID)
+	if err == store.ErrConflict {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"group_id": groupID, "user_id": userID, "already_member": true})
+		return
+	}
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if exists > 0 {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]interface{}{"group_id": groupID, "user_id": req.UserID, "already_member": true})
+	resp := map[string]interface{}{"group_id": groupID, "user_id": userID}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Remove a member from a group (admin only)
+func (s *server) removeMemberHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		GroupID int `json:"group_id"`
+		UserID  int `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if !s.requireGroupRole(w, r, req.GroupID, roleAdmin) {
+		return
+	}
+	user, _ := currentUser(r)
+	if req.UserID == user.ID {
+		http.Error(w, "The admin can't remove themselves from the group", http.StatusBadRequest)
+		return
+	}
+	err := s.store.Groups.RemoveMember(r.Context(), req.GroupID, req.UserID)
+	if err == store.ErrNotFound {
+		http.Error(w, "User is not a member of this group", http.StatusNotFound)
 		return
 	}
-	// Add user to group_members
-	_, err = db.Exec("INSERT INTO group_members (group_id, user_id) VALUES (?, ?)", groupID, req.UserID)
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	resp := map[string]interface{}{"group_id": groupID, "user_id": req.UserID}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
 }
 
-// Event data structure
-type Event struct {
-	ID          int    `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Date        string `json:"date"`       // ISO format (YYYY-MM-DD)
-	CreatedBy   int    `json:"created_by"` // User ID
+// Delete a group and everything in it (admin only)
+func (s *server) deleteGroupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		GroupID int `json:"group_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if !s.requireGroupRole(w, r, req.GroupID, roleAdmin) {
+		return
+	}
+	receipts, err := s.store.Receipts.ListGroupReceipts(r.Context(), req.GroupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.store.Groups.DeleteGroup(r.Context(), req.GroupID); err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.deleteBlobs(receipts)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
 }
 
-var events = make(map[int]Event)
-var eventIDCounter = 1
+// Replace a group's join code, invalidating the old one (admin only)
+func (s *server) regenerateCodeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		GroupID int `json:"group_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if !s.requireGroupRole(w, r, req.GroupID, roleAdmin) {
+		return
+	}
+	code := generateGroupCode(6)
+	if err := s.store.Groups.UpdateCode(r.Context(), req.GroupID, code); err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"group_id": req.GroupID, "code": code})
+}
 
-// Handler for creating an event
-func createEventHandler(w http.ResponseWriter, r *http.Request) {
+// Handler for creating an event in a group
+func (s *server) createEventHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	var req struct {
+		GroupID     int    `json:"group_id"`
 		Title       string `json:"title"`
 		Description string `json:"description"`
 		Date        string `json:"date"`
-		CreatedBy   int    `json:"created_by"`
+		EndDate     string `json:"end_date"`
+		Time        string `json:"time"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	event := Event{ID: eventIDCounter, Title: req.Title, Description: req.Description, Date: req.Date, CreatedBy: req.CreatedBy}
-	events[eventIDCounter] = event
-	eventIDCounter++
+	if req.Title == "" || !isISODate(req.Date) || (req.EndDate != "" && !isISODate(req.EndDate)) {
+		http.Error(w, "Missing title or invalid date (want YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+	if !s.requireGroupRole(w, r, req.GroupID, roleMember) {
+		return
+	}
+	user, _ := currentUser(r)
+	event := store.Event{
+		GroupID: req.GroupID, Title: req.Title, Description: req.Description,
+		Date: req.Date, EndDate: req.EndDate, Time: req.Time, CreatedBy: user.ID,
+	}
+	id, err := s.store.Events.CreateEvent(r.Context(), event)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	event.ID = id
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(event)
 }
 
-// Handler for listing events
-func listEventsHandler(w http.ResponseWriter, r *http.Request) {
+// Handler for listing a group's events, optionally between from and to (inclusive)
+func (s *server) listEventsHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	eventList := make([]Event, 0, len(events))
-	for _, event := range events {
-		eventList = append(eventList, event)
+	groupID, ok := groupIDParam(w, r)
+	if !ok {
+		return
+	}
+	filter := store.EventFilter{GroupID: groupID, From: r.URL.Query().Get("from"), To: r.URL.Query().Get("to")}
+	if (filter.From != "" && !isISODate(filter.From)) || (filter.To != "" && !isISODate(filter.To)) {
+		http.Error(w, "Invalid from/to date (want YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+	if !s.requireGroupRole(w, r, groupID, roleMember) {
+		return
+	}
+	events, err := s.store.Events.ListEvents(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []store.Event{}
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(eventList)
+	json.NewEncoder(w).Encode(events)
+}
+
+// Update an event (creator or group admin)
+func (s *server) updateEventHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		EventID     int    `json:"event_id"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		Date        string `json:"date"`
+		EndDate     string `json:"end_date"`
+		Time        string `json:"time"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if (req.Date != "" && !isISODate(req.Date)) || (req.EndDate != "" && !isISODate(req.EndDate)) {
+		http.Error(w, "Invalid date (want YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+	event, ok := s.authorizeEventChange(w, r, req.EventID)
+	if !ok {
+		return
+	}
+	if req.Title != "" {
+		event.Title = req.Title
+	}
+	if req.Description != "" {
+		event.Description = req.Description
+	}
+	if req.Date != "" {
+		event.Date = req.Date
+	}
+	if req.EndDate != "" {
+		event.EndDate = req.EndDate
+	}
+	if req.Time != "" {
+		event.Time = req.Time
+	}
+	if err := s.store.Events.UpdateEvent(r.Context(), event); err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+// Delete an event (creator or group admin)
+func (s *server) deleteEventHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		EventID int `json:"event_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if _, ok := s.authorizeEventChange(w, r, req.EventID); !ok {
+		return
+	}
+	if err := s.store.Events.DeleteEvent(r.Context(), req.EventID); err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
+}
+
+// isISODate reports whether s is a valid YYYY-MM-DD date
+func isISODate(s string) bool {
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
 }
 
-func loginHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) loginHandler(w http.ResponseWriter, r *http.Request) {
 	// Set CORS headers for direct requests
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
@@ -315,26 +499,47 @@ func loginHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	var user User
-	err := db.QueryRow("SELECT id, username, password FROM users WHERE username = ?", req.Username).Scan(&user.ID, &user.Username, &user.Password)
+	user, err := s.store.Users.GetUserByUsername(r.Context(), req.Username)
 	if err != nil {
 		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 		return
 	}
-	if user.Password != req.Password {
+	ok, needsUpgrade := checkPassword(user.Password, req.Password)
+	if !ok {
 		http.Error(w, "Invalid username or password", http.StatusUnauthorized)
 		return
 	}
+	// Transparently replace legacy plaintext passwords with a hash
+	if needsUpgrade {
+		if hash, err := hashPassword(req.Password); err != nil {
+			fmt.Println("[DEBUG] Password hashing failed:", err)
+		} else if err := s.store.Users.UpdatePassword(r.Context(), user.ID, hash); err != nil {
+			fmt.Println("[DEBUG] DB error on password upgrade:", err)
+		}
+	}
+	token, expiresAt, err := createSession(r.Context(), s.store.Sessions, user.ID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(user)
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": user.ID, "username": user.Username, "token": token, "expires_at": expiresAt})
 }
 
-func connectToDB() (*sql.DB, error) {
-	dsn := "root:root@tcp(195.85.19.115:3306)/letshangoutapp1"
-	db, err := sql.Open("mysql", dsn)
+func connectToDB(cfg DBConfig) (*sql.DB, error) {
+	mc, err := cfg.mysqlConfig()
 	if err != nil {
 		return nil, err
 	}
+	connector, err := mysql.NewConnector(mc)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(connector)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 	// Test the connection
 	if err := db.Ping(); err != nil {
 		return nil, err
@@ -342,55 +547,48 @@ func connectToDB() (*sql.DB, error) {
 	return db, nil
 }
 
-func myGroupsHandler(w http.ResponseWriter, r *http.Request) {
-	userID := r.URL.Query().Get("user_id")
-	if userID == "" {
-		http.Error(w, "Missing user_id", http.StatusBadRequest)
-		return
-	}
-	rows, err := db.Query(
-		"SELECT g.id, g.name, g.code, g.admin_id FROM group_members gm JOIN `groups` g ON gm.group_id = g.id WHERE gm.user_id = ?",
-		userID,
-	)
+func (s *server) myGroupsHandler(w http.ResponseWriter, r *http.Request) {
+	user, _ := currentUser(r)
+	groups, err := s.store.Groups.ListGroupsForUser(r.Context(), user.ID)
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-	var groups []Group
-	for rows.Next() {
-		var g Group
-		if err := rows.Scan(&g.ID, &g.Name, &g.Code, &g.AdminID); err != nil {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		groups = append(groups, g)
-	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(groups)
 }
 
 // Propose a new date for a group
-func proposeDateHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) proposeDateHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	var req struct {
-		GroupID    int    `json:"group_id"`
-		Date       string `json:"date"`
-		EndDate    string `json:"end_date"`
-		Time       string `json:"time"`
-		ProposedBy int    `json:"proposed_by"`
+		GroupID int    `json:"group_id"`
+		Date    string `json:"date"`
+		EndDate string `json:"end_date"`
+		Time    string `json:"time"`
+		// Optional voting window, RFC 3339 (e.g. 2024-06-01T18:00:00Z)
+		Deadline string `json:"deadline"`
+		Quorum   int    `json:"quorum"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	_, err := db.Exec(
-		"INSERT INTO event_dates (group_id, date, end_date, time, proposed_by) VALUES (?, ?, ?, ?, ?)",
-		req.GroupID, req.Date, req.EndDate, req.Time, req.ProposedBy,
-	)
+	deadline, ok := parseDeadline(w, req.Deadline, req.Quorum)
+	if !ok {
+		return
+	}
+	if !s.requireGroupRole(w, r, req.GroupID, roleMember) {
+		return
+	}
+	user, _ := currentUser(r)
+	_, err := s.store.Dates.ProposeDate(r.Context(), store.ProposedDate{
+		GroupID: req.GroupID, Date: req.Date, EndDate: req.EndDate, Time: req.Time, ProposedBy: user.ID,
+		Deadline: deadline, Quorum: req.Quorum,
+	})
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -400,22 +598,35 @@ func proposeDateHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Vote for a proposed date
-func voteDateHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) voteDateHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	var req struct {
-		EventDateID int  `json:"event_date_id"`
-		UserID      int  `json:"user_id"`
-		Available   bool `json:"available"`
+		EventDateID int    `json:"event_date_id"`
+		Response    string `json:"response"` // yes, maybe or no
+		Preferred   bool   `json:"preferred"`
+		// Older clients send a boolean instead of response
+		Available *bool `json:"available"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	// Upsert: if vote exists, update; else insert
-	_, err := db.Exec(`REPLACE INTO date_votes (event_date_id, user_id, available) VALUES (?, ?, ?)`, req.EventDateID, req.UserID, req.Available)
+	ballot, ok := parseBallot(w, req.Response, req.Available, req.Preferred)
+	if !ok {
+		return
+	}
+	date, ok := s.authorizeDate(w, r, req.EventDateID, roleMember)
+	if !ok {
+		return
+	}
+	if !votingOpen(w, date, time.Now()) {
+		return
+	}
+	user, _ := currentUser(r)
+	err := s.store.Dates.Vote(r.Context(), req.EventDateID, user.ID, ballot)
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -424,86 +635,60 @@ func voteDateHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("{\"success\":true}"))
 }
 
-// Get all proposed dates and votes for a group
-func groupDatesHandler(w http.ResponseWriter, r *http.Request) {
-	groupID := r.URL.Query().Get("group_id")
-	if groupID == "" {
-		http.Error(w, "Missing group_id", http.StatusBadRequest)
-		return
-	}
-	rows, err := db.Query(`
-		SELECT ed.id, ed.date, ed.end_date, ed.time, ed.proposed_by, u.username,
-			   COALESCE(SUM(CASE WHEN dv.available = 1 THEN 1 ELSE 0 END), 0) as available_votes,
-			   COALESCE(SUM(CASE WHEN dv.available = 0 THEN 1 ELSE 0 END), 0) as not_available_votes
-		FROM event_dates ed
-		LEFT JOIN date_votes dv ON ed.id = dv.event_date_id
-		LEFT JOIN users u ON ed.proposed_by = u.id
-		WHERE ed.group_id = ?
-		GROUP BY ed.id, ed.date, ed.end_date, ed.time, ed.proposed_by, u.username
-		ORDER BY ed.date ASC, ed.time ASC`, groupID)
+// Get all proposed dates and votes for a group, with their score, rank and
+// the members who have not voted yet. ?order=rank sorts best first.
+func (s *server) groupDatesHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := groupIDParam(w, r)
+	if !ok || !s.requireGroupRole(w, r, groupID, roleMember) {
+		return
+	}
+	dates, err := s.store.Dates.ListDates(r.Context(), groupID)
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-	var dates []map[string]interface{}
-	for rows.Next() {
-		var id, proposedBy, availableVotes, notAvailableVotes int
-		var date, endDate, username string
-		var timeNull sql.NullString
-		if err := rows.Scan(&id, &date, &endDate, &timeNull, &proposedBy, &username, &availableVotes, &notAvailableVotes); err != nil {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		var timeStr string
-		if timeNull.Valid {
-			timeStr = timeNull.String
-		} else {
-			timeStr = ""
-		}
-		dates = append(dates, map[string]interface{}{
-			"id": id, "date": date, "end_date": endDate, "time": timeStr, "proposed_by": proposedBy, "proposed_by_username": username,
-			"available_votes": availableVotes, "not_available_votes": notAvailableVotes,
-		})
+	votes, err := s.store.Dates.ListVotes(r.Context(), groupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	members, err := s.store.Groups.ListMembers(r.Context(), groupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ranked := rankDates(dates, votes, members)
+	if r.URL.Query().Get("order") == "rank" {
+		sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Rank < ranked[j].Rank })
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(dates)
+	json.NewEncoder(w).Encode(ranked)
 }
 
 // Delete a proposed date (only by proposer)
-func deleteProposedDateHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) deleteProposedDateHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	var req struct {
 		EventDateID int `json:"event_date_id"`
-		UserID      int `json:"user_id"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	// Check if the user is the proposer
-	var proposerID int
-	err := db.QueryRow("SELECT proposed_by FROM event_dates WHERE id = ?", req.EventDateID).Scan(&proposerID)
-	if err != nil {
-		http.Error(w, "Event date not found", http.StatusNotFound)
+	date, ok := s.authorizeDate(w, r, req.EventDateID, roleMember)
+	if !ok {
 		return
 	}
-	if proposerID != req.UserID {
+	user, _ := currentUser(r)
+	// Check if the user is the proposer
+	if date.ProposedBy != user.ID {
 		http.Error(w, "You can only delete your own proposed dates", http.StatusForbidden)
 		return
 	}
-	// Delete related votes first
-	_, err = db.Exec("DELETE FROM date_votes WHERE event_date_id = ?", req.EventDateID)
-	if err != nil {
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	// Delete the event date
-	_, err = db.Exec("DELETE FROM event_dates WHERE id = ?", req.EventDateID)
-	if err != nil {
+	if err := s.store.Dates.DeleteDate(r.Context(), req.EventDateID); err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -511,19 +696,8 @@ func deleteProposedDateHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("{\"success\":true}"))
 }
 
-// Task struct
-type Task struct {
-	ID          int    `json:"id"`
-	GroupID     int    `json:"group_id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	DueDate     string `json:"due_date"`
-	AssigneeID  int    `json:"assignee_id"`
-	Status      string `json:"status"`
-}
-
 // Add a new task
-func addTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) addTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -539,44 +713,50 @@ func addTaskHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	result, err := db.Exec("INSERT INTO tasks (group_id, title, description, due_date, assignee_id, status) VALUES (?, ?, ?, ?, ?, ?)", req.GroupID, req.Title, req.Description, req.DueDate, req.AssigneeID, "todo")
+	if !s.requireGroupRole(w, r, req.GroupID, roleMember) {
+		return
+	}
+	if req.AssigneeID != 0 && !s.checkAssignee(w, r, req.GroupID, req.AssigneeID) {
+		return
+	}
+	id, err := s.store.Tasks.CreateTask(r.Context(), store.Task{
+		GroupID: req.GroupID, Title: req.Title, Description: req.Description,
+		DueDate: req.DueDate, AssigneeID: req.AssigneeID, Status: store.TaskTodo,
+	})
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	id, _ := result.LastInsertId()
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{"id": id})
 }
 
-// List all tasks for a group
-func groupTasksHandler(w http.ResponseWriter, r *http.Request) {
-	groupID := r.URL.Query().Get("group_id")
-	if groupID == "" {
-		http.Error(w, "Missing group_id", http.StatusBadRequest)
+// List the tasks of a group with their assignees and checklists, optionally
+// only those with ?status= and/or assigned (among others) to ?assignee_id=
+func (s *server) groupTasksHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := groupIDParam(w, r)
+	if !ok {
+		return
+	}
+	filter, ok := taskFilter(w, r, groupID)
+	if !ok || !s.requireGroupRole(w, r, groupID, roleMember) {
 		return
 	}
-	rows, err := db.Query("SELECT id, group_id, title, description, due_date, assignee_id, status FROM tasks WHERE group_id = ?", groupID)
+	tasks, err := s.store.Tasks.ListTasks(r.Context(), filter)
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-	var tasks []Task
-	for rows.Next() {
-		var t Task
-		if err := rows.Scan(&t.ID, &t.GroupID, &t.Title, &t.Description, &t.DueDate, &t.AssigneeID, &t.Status); err != nil {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		tasks = append(tasks, t)
+	if tasks == nil {
+		tasks = []store.Task{}
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(tasks)
 }
 
-// Assign a user to a task
-func assignTaskHandler(w http.ResponseWriter, r *http.Request) {
+// Make a user the only assignee of a task (assignee_id 0 unassigns everyone);
+// /add-task-assignee adds one instead
+func (s *server) assignTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -589,7 +769,11 @@ func assignTaskHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	_, err := db.Exec("UPDATE tasks SET assignee_id = ? WHERE id = ?", req.AssigneeID, req.TaskID)
+	task, ok := s.authorizeTask(w, r, req.TaskID, roleMember)
+	if !ok || (req.AssigneeID != 0 && !s.checkAssignee(w, r, task.GroupID, req.AssigneeID)) {
+		return
+	}
+	err := s.store.Tasks.AssignTask(r.Context(), req.TaskID, req.AssigneeID)
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -599,7 +783,7 @@ func assignTaskHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Mark a task as completed
-func completeTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) completeTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -611,9 +795,12 @@ func completeTaskHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	_, err := db.Exec("UPDATE tasks SET status = 'done' WHERE id = ?", req.TaskID)
-	if err != nil {
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+	task, ok := s.authorizeTask(w, r, req.TaskID, roleMember)
+	if !ok || !checkTaskTransition(w, task, store.TaskDone) {
+		return
+	}
+	if err := setTaskStatus(r, s.store, task, store.TaskDone); err != nil {
+		statusChangeFailed(w, err)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -621,7 +808,7 @@ func completeTaskHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // Delete a task
-func deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -633,7 +820,10 @@ func deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	_, err := db.Exec("DELETE FROM tasks WHERE id = ?", req.TaskID)
+	if _, ok := s.authorizeTask(w, r, req.TaskID, roleMember); !ok {
+		return
+	}
+	err := s.store.Tasks.DeleteTask(r.Context(), req.TaskID)
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -643,187 +833,131 @@ func deleteTaskHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // List all members of a group
-func groupMembersHandler(w http.ResponseWriter, r *http.Request) {
-	groupID := r.URL.Query().Get("group_id")
-	if groupID == "" {
-		http.Error(w, "Missing group_id", http.StatusBadRequest)
+func (s *server) groupMembersHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := groupIDParam(w, r)
+	if !ok || !s.requireGroupRole(w, r, groupID, roleMember) {
 		return
 	}
-	rows, err := db.Query(`SELECT u.id, u.username FROM group_members gm JOIN users u ON gm.user_id = u.id WHERE gm.group_id = ?`, groupID)
+	members, err := s.store.Groups.ListMembers(r.Context(), groupID)
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer rows.Close()
-	var members []map[string]interface{}
-	for rows.Next() {
-		var id int
-		var username string
-		if err := rows.Scan(&id, &username); err != nil {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		members = append(members, map[string]interface{}{"id": id, "username": username})
-	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(members)
 }
 
-// Expense struct
-type Expense struct {
-	ID          int     `json:"id"`
-	GroupID     int     `json:"group_id"`
-	Description string  `json:"description"`
-	Amount      float64 `json:"amount"`
-	PaidBy      int     `json:"paid_by"`
-	Date        string  `json:"date"`
-	Category    string  `json:"category"`
-}
-
 // Add a new expense
-func addExpenseHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) addExpenseHandler(w http.ResponseWriter, r *http.Request) {
 	fmt.Println("[DEBUG] addExpenseHandler called")
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 	var req struct {
-		GroupID     int     `json:"group_id"`
-		Description string  `json:"description"`
-		Amount      float64 `json:"amount"`
-		PaidBy      int     `json:"paid_by"`
-		Date        string  `json:"date"`
-		Category    string  `json:"category"`
-		SplitWith   []int   `json:"split_with"`
+		GroupID     int          `json:"group_id"`
+		Description string       `json:"description"`
+		Amount      money.Amount `json:"amount"`
+		PaidBy      int          `json:"paid_by"`
+		Date        string       `json:"date"`
+		Category    string       `json:"category"`
+		SplitWith   []int        `json:"split_with"`
+		// Defaults to the group's base currency. Foreign expenses need
+		// exchange_rate unless the configured rates provider knows it.
+		Currency     string  `json:"currency"`
+		ExchangeRate float64 `json:"exchange_rate"`
+		// equal (default), exact, percentage or shares; the last three read splits
+		SplitMode string       `json:"split_mode"`
+		Splits    []splitInput `json:"splits"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	result, err := db.Exec("INSERT INTO expenses (group_id, description, amount, paid_by, date, category) VALUES (?, ?, ?, ?, ?, ?)", req.GroupID, req.Description, req.Amount, req.PaidBy, req.Date, req.Category)
-	if err != nil {
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+	if req.Amount <= 0 {
+		http.Error(w, "Amount must be positive", http.StatusBadRequest)
 		return
 	}
-	expenseID, _ := result.LastInsertId()
-	// Split equally if split_with provided
-	if len(req.SplitWith) > 0 {
-		splitAmount := req.Amount / float64(len(req.SplitWith))
-		for _, uid := range req.SplitWith {
-			_, err := db.Exec("INSERT INTO expense_splits (expense_id, user_id, amount) VALUES (?, ?, ?)", expenseID, uid, splitAmount)
-			if err != nil {
-				http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-		}
+	if req.SplitMode == "" {
+		req.SplitMode = store.SplitEqual
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{"id": expenseID})
-}
-
-// List all expenses for a group
-func groupExpensesHandler(w http.ResponseWriter, r *http.Request) {
-	groupID := r.URL.Query().Get("group_id")
-	if groupID == "" {
-		http.Error(w, "Missing group_id", http.StatusBadRequest)
+	if !s.requireGroupRole(w, r, req.GroupID, roleMember) {
 		return
 	}
-	rows, err := db.Query("SELECT id, group_id, description, amount, paid_by, date, category FROM expenses WHERE group_id = ? ORDER BY date DESC, id DESC", groupID)
-	if err != nil {
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+	category, ok := s.checkCategory(w, r, req.GroupID, req.Category)
+	if !ok {
 		return
 	}
-	defer rows.Close()
-	var expenses []Expense
-	for rows.Next() {
-		var e Expense
-		if err := rows.Scan(&e.ID, &e.GroupID, &e.Description, &e.Amount, &e.PaidBy, &e.Date, &e.Category); err != nil {
+	// The payer defaults to the caller; recording a payment made by another
+	// member (e.g. an external member without an account) is allowed too
+	user, _ := currentUser(r)
+	if req.PaidBy == 0 {
+		req.PaidBy = user.ID
+	} else if req.PaidBy != user.ID {
+		isMember, err := s.store.Groups.IsMember(r.Context(), req.GroupID, req.PaidBy)
+		if err != nil {
 			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		expenses = append(expenses, e)
+		if !isMember {
+			http.Error(w, "paid_by must be a member of the group", http.StatusBadRequest)
+			return
+		}
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(expenses)
-}
-
-// Group balances: who owes whom
-func groupBalancesHandler(w http.ResponseWriter, r *http.Request) {
-	groupID := r.URL.Query().Get("group_id")
-	if groupID == "" {
-		http.Error(w, "Missing group_id", http.StatusBadRequest)
+	group, err := s.store.Groups.GetGroup(r.Context(), req.GroupID)
+	if err != nil {
+		lookupFailed(w, err, "Group not found")
 		return
 	}
-	// Get all users in group
-	userRows, err := db.Query("SELECT u.id, u.username FROM group_members gm JOIN users u ON gm.user_id = u.id WHERE gm.group_id = ?", groupID)
+	if req.Currency == "" {
+		req.Currency = group.BaseCurrency
+	}
+	splits, err := computeSplits(req.SplitMode, req.Amount, req.Currency, req.SplitWith, req.Splits)
 	if err != nil {
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	defer userRows.Close()
-	users := map[int]string{}
-	for userRows.Next() {
-		var id int
-		var username string
-		if err := userRows.Scan(&id, &username); err != nil {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		users[id] = username
+	if !s.requireMembers(w, r, req.GroupID, splits) {
+		return
+	}
+	rate, ok := s.exchangeRate(w, req.Currency, group.BaseCurrency, req.ExchangeRate)
+	if !ok {
+		return
 	}
-	// Calculate balances
-	balances := map[int]float64{} // user_id -> net balance
-	// Each expense: paid_by gets +amount, split_with gets -split
-	expRows, err := db.Query("SELECT id, amount, paid_by FROM expenses WHERE group_id = ?", groupID)
+	expense := store.Expense{
+		GroupID: req.GroupID, Description: req.Description, Amount: req.Amount,
+		Currency: req.Currency, Rate: rate, BaseAmount: money.Convert(req.Amount, rate, group.BaseCurrency),
+		PaidBy: req.PaidBy, Date: req.Date, Category: category, SplitMode: req.SplitMode,
+	}
+	setBaseAmounts(expense, group.BaseCurrency, splits)
+	expenseID, err := s.store.Expenses.CreateExpense(r.Context(), expense, splits)
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer expRows.Close()
-	for expRows.Next() {
-		var eid, paidBy int
-		var amount float64
-		if err := expRows.Scan(&eid, &amount, &paidBy); err != nil {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		// Get splits
-		splitRows, err := db.Query("SELECT user_id, amount FROM expense_splits WHERE expense_id = ?", eid)
-		if err != nil {
-			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		splitCount := 0
-		for splitRows.Next() {
-			var uid int
-			var splitAmt float64
-			if err := splitRows.Scan(&uid, &splitAmt); err != nil {
-				http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-				splitRows.Close()
-				return
-			}
-			balances[uid] -= splitAmt
-			splitCount++
-		}
-		splitRows.Close()
-		// Paid by gets full amount back
-		balances[paidBy] += amount
-	}
-	// Prepare summary
-	summary := []map[string]interface{}{}
-	for uid, bal := range balances {
-		summary = append(summary, map[string]interface{}{
-			"user_id":  uid,
-			"username": users[uid],
-			"balance":  bal,
-		})
+	expense.ID = expenseID
+	s.checkBudgets(r.Context(), group, expense)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": expenseID})
+}
+
+// List all expenses for a group
+func (s *server) groupExpensesHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := groupIDParam(w, r)
+	if !ok || !s.requireGroupRole(w, r, groupID, roleMember) {
+		return
+	}
+	expenses, err := s.store.Expenses.ListExpenses(r.Context(), groupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(summary)
+	json.NewEncoder(w).Encode(expenses)
 }
 
 // Update a task
-func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -840,35 +974,46 @@ func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	set := []string{}
-	args := []interface{}{}
-	if req.Title != "" {
-		set = append(set, "title = ?")
-		args = append(args, req.Title)
+	update := store.TaskUpdate{
+		Title: req.Title, Description: req.Description, DueDate: req.DueDate,
+		AssigneeID: req.AssigneeID,
 	}
-	if req.Description != "" {
-		set = append(set, "description = ?")
-		args = append(args, req.Description)
+	if update == (store.TaskUpdate{}) && req.Status == "" {
+		http.Error(w, "No fields to update", http.StatusBadRequest)
+		return
 	}
-	if req.DueDate != "" {
-		set = append(set, "due_date = ?")
-		args = append(args, req.DueDate)
+	task, ok := s.authorizeTask(w, r, req.TaskID, roleMember)
+	if !ok {
+		return
 	}
-	if req.AssigneeID != 0 {
-		set = append(set, "assignee_id = ?")
-		args = append(args, req.AssigneeID)
+	if req.Status != "" && !checkTaskTransition(w, task, req.Status) {
+		return
 	}
-	if req.Status != "" {
-		set = append(set, "status = ?")
-		args = append(args, req.Status)
+	// Edit forms send the assignee back unchanged; only a different one
+	// replaces the task's assignees (co-assignees are kept otherwise)
+	if update.AssigneeID == task.AssigneeID {
+		update.AssigneeID = 0
 	}
-	if len(set) == 0 {
-		http.Error(w, "No fields to update", http.StatusBadRequest)
+	if update.AssigneeID != 0 && !s.checkAssignee(w, r, task.GroupID, update.AssigneeID) {
+		return
+	}
+	var statusErr error
+	err := s.store.WithTx(r.Context(), func(tx *store.Store) error {
+		if update != (store.TaskUpdate{}) {
+			if err := tx.Tasks.UpdateTask(r.Context(), task.ID, update); err != nil {
+				return err
+			}
+		}
+		if req.Status != "" {
+			statusErr = setTaskStatus(r, tx, task, req.Status)
+			return statusErr
+		}
+		return nil
+	})
+	if statusErr != nil {
+		statusChangeFailed(w, statusErr)
 		return
 	}
-	query := "UPDATE tasks SET " + strings.Join(set, ", ") + " WHERE id = ?"
-	args = append(args, req.TaskID)
-	_, err := db.Exec(query, args...)
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -878,7 +1023,7 @@ func updateTaskHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 // After addExpenseHandler
-func deleteExpenseHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) deleteExpenseHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -890,24 +1035,26 @@ func deleteExpenseHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
-	// Delete splits for this expense first
-	_, err := db.Exec("DELETE FROM expense_splits WHERE expense_id = ?", req.ExpenseID)
+	if _, ok := s.authorizeExpense(w, r, req.ExpenseID, roleMember); !ok {
+		return
+	}
+	receipts, err := s.store.Receipts.ListReceipts(r.Context(), req.ExpenseID)
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-	// Then delete from expenses table
-	_, err = db.Exec("DELETE FROM expenses WHERE id = ?", req.ExpenseID)
+	err = s.store.Expenses.DeleteExpense(r.Context(), req.ExpenseID)
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	s.deleteBlobs(receipts)
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("{\"success\":true}"))
 }
 
 // Handler to add an external member to a group
-func addExternalMemberHandler(w http.ResponseWriter, r *http.Request) {
+func (s *server) addExternalMemberHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -924,35 +1071,33 @@ func addExternalMemberHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Missing name or group_id", http.StatusBadRequest)
 		return
 	}
+	if !s.requireGroupRole(w, r, req.GroupID, roleMember) {
+		return
+	}
 	// Generate a unique username for the external member
 	extUsername := req.Name
 	// Ensure uniqueness by appending a random number if needed
-	var exists int
 	baseUsername := extUsername
 	for i := 0; i < 10; i++ {
-		err := db.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", extUsername).Scan(&exists)
+		_, err := s.store.Users.GetUserByUsername(r.Context(), extUsername)
+		if err == store.ErrNotFound {
+			break
+		}
 		if err != nil {
 			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		if exists == 0 {
-			break
-		}
 		extUsername = fmt.Sprintf("%s_%d", baseUsername, rand.Intn(10000))
 	}
-	// Insert into users table (no password)
-	result, err := db.Exec("INSERT INTO users (username, password) VALUES (?, '')", extUsername)
-	if err != nil {
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	userID, err := result.LastInsertId()
-	if err != nil {
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	// Add to group_members
-	_, err = db.Exec("INSERT INTO group_members (group_id, user_id) VALUES (?, ?)", req.GroupID, userID)
+	// Insert into users table (no password) and group_members together
+	var userID int
+	err := s.store.WithTx(r.Context(), func(tx *store.Store) error {
+		var err error
+		if userID, err = tx.Users.CreateUser(r.Context(), extUsername, ""); err != nil {
+			return err
+		}
+		return tx.Groups.AddMember(r.Context(), req.GroupID, userID)
+	})
 	if err != nil {
 		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -965,63 +1110,148 @@ func addExternalMemberHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
+// openStore connects the configured storage backend
+func openStore(cfg Config) (*store.Store, func(), error) {
+	if cfg.Storage == "memory" {
+		fmt.Println("Using in-memory storage; data is lost on restart")
+		return store.NewMemory(), func() {}, nil
+	}
+	db, err := connectToDB(cfg.DB)
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Println("Successfully connected to MySQL database!")
+	return store.NewMySQL(db), func() { db.Close() }, nil
+}
+
 func main() {
-	var err error
-	db, err = connectToDB()
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file")
+	flag.Parse()
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Printf("Invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
+	// "migrate up|down|status" manages the schema instead of serving
+	if flag.Arg(0) == "migrate" {
+		db, err := connectToDB(cfg.DB)
+		if err != nil {
+			fmt.Printf("Failed to connect to MySQL: %v\n", err)
+			os.Exit(1)
+		}
+		err = runMigrateCommand(db, flag.Args()[1:])
+		db.Close()
+		if err != nil {
+			fmt.Println("Migration failed:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	st, closeStore, err := openStore(cfg)
 	if err != nil {
 		fmt.Printf("Failed to connect to MySQL: %v\n", err)
-	} else {
-		fmt.Println("Successfully connected to MySQL database!")
-		defer db.Close()
+		os.Exit(1)
 	}
+	defer closeStore()
+	rates, err := newRateProvider(cfg.Rates)
+	if err != nil {
+		fmt.Println("Failed to load exchange rates:", err)
+		os.Exit(1)
+	}
+	blobs, err := newBlobStore(cfg.Blobs)
+	if err != nil {
+		fmt.Println("Failed to open blob storage:", err)
+		os.Exit(1)
+	}
+	s := &server{store: st, rates: rates, defaultCurrency: cfg.DefaultCurrency, blobs: blobs}
+	go s.runScheduler(cfg.SchedulerInterval)
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/my-groups", myGroupsHandler)
+	mux.HandleFunc("/my-groups", requireAuth(s.myGroupsHandler))
 	mux.HandleFunc("/", rootHandler)
 	mux.HandleFunc("/api", apiHandler)
-	mux.HandleFunc("/register", registerHandler)
-	mux.HandleFunc("/groups", createGroupHandler)
-	mux.HandleFunc("/join-group", joinGroupHandler)
-	mux.HandleFunc("/events", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/register", s.registerHandler)
+	mux.HandleFunc("/groups", s.createGroupHandler)
+	mux.HandleFunc("/join-group", requireAuth(s.joinGroupHandler))
+	mux.HandleFunc("/remove-member", requireAuth(s.removeMemberHandler))
+	mux.HandleFunc("/delete-group", requireAuth(s.deleteGroupHandler))
+	mux.HandleFunc("/regenerate-code", requireAuth(s.regenerateCodeHandler))
+	mux.HandleFunc("/set-base-currency", requireAuth(s.setBaseCurrencyHandler))
+	mux.HandleFunc("/events", requireAuth(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
-			createEventHandler(w, r)
+			s.createEventHandler(w, r)
 		} else if r.Method == http.MethodGet {
-			listEventsHandler(w, r)
+			s.listEventsHandler(w, r)
 		} else {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		}
-	})
-	mux.HandleFunc("/login", loginHandler)
-	mux.HandleFunc("/propose-date", proposeDateHandler)
-	mux.HandleFunc("/vote-date", voteDateHandler)
-	mux.HandleFunc("/group-dates", groupDatesHandler)
-	mux.HandleFunc("/delete-proposed-date", deleteProposedDateHandler)
-	mux.HandleFunc("/add-task", addTaskHandler)
-	mux.HandleFunc("/group-tasks", groupTasksHandler)
-	mux.HandleFunc("/assign-task", assignTaskHandler)
-	mux.HandleFunc("/complete-task", completeTaskHandler)
-	mux.HandleFunc("/delete-task", deleteTaskHandler)
-	mux.HandleFunc("/group-members", groupMembersHandler)
-	mux.HandleFunc("/add-expense", addExpenseHandler)
-	mux.HandleFunc("/group-expenses", groupExpensesHandler)
-	mux.HandleFunc("/group-balances", groupBalancesHandler)
-	mux.HandleFunc("/update-task", updateTaskHandler)
-	mux.HandleFunc("/api/delete-expense", deleteExpenseHandler)
-	mux.HandleFunc("/delete-expense", deleteExpenseHandler)
-	mux.HandleFunc("/api/group-members", groupMembersHandler)
-	mux.HandleFunc("/api/add-expense", addExpenseHandler)
-	mux.HandleFunc("/api/add-external-member", addExternalMemberHandler)
-	mux.HandleFunc("/add-external-member", addExternalMemberHandler)
+	}))
+	mux.HandleFunc("/update-event", requireAuth(s.updateEventHandler))
+	mux.HandleFunc("/delete-event", requireAuth(s.deleteEventHandler))
+	mux.HandleFunc("/login", s.loginHandler)
+	mux.HandleFunc("/logout", requireAuth(s.logoutHandler))
+	mux.HandleFunc("/propose-date", requireAuth(s.proposeDateHandler))
+	mux.HandleFunc("/vote-date", requireAuth(s.voteDateHandler))
+	mux.HandleFunc("/group-dates", requireAuth(s.groupDatesHandler))
+	mux.HandleFunc("/delete-proposed-date", requireAuth(s.deleteProposedDateHandler))
+	mux.HandleFunc("/finalize-date", requireAuth(s.finalizeDateHandler))
+	mux.HandleFunc("/set-date-deadline", requireAuth(s.setDateDeadlineHandler))
+	mux.HandleFunc("/notifications", requireAuth(s.notificationsHandler))
+	mux.HandleFunc("/read-notifications", requireAuth(s.readNotificationsHandler))
+	mux.HandleFunc("/add-task", requireAuth(s.addTaskHandler))
+	mux.HandleFunc("/group-tasks", requireAuth(s.groupTasksHandler))
+	mux.HandleFunc("/assign-task", requireAuth(s.assignTaskHandler))
+	mux.HandleFunc("/complete-task", requireAuth(s.completeTaskHandler))
+	mux.HandleFunc("/delete-task", requireAuth(s.deleteTaskHandler))
+	mux.HandleFunc("/group-members", requireAuth(s.groupMembersHandler))
+	mux.HandleFunc("/add-expense", requireAuth(s.addExpenseHandler))
+	mux.HandleFunc("/group-expenses", requireAuth(s.groupExpensesHandler))
+	mux.HandleFunc("/group-balances", requireAuth(s.groupBalancesHandler))
+	mux.HandleFunc("/settlement-plan", requireAuth(s.settlementPlanHandler))
+	mux.HandleFunc("/record-settlement", requireAuth(s.recordSettlementHandler))
+	mux.HandleFunc("/group-settlements", requireAuth(s.groupSettlementsHandler))
+	mux.HandleFunc("/undo-settlement", requireAuth(s.undoSettlementHandler))
+	mux.HandleFunc("/update-task", requireAuth(s.updateTaskHandler))
+	mux.HandleFunc("/task-status-history", requireAuth(s.taskStatusHistoryHandler))
+	mux.HandleFunc("/add-task-assignee", requireAuth(s.addTaskAssigneeHandler))
+	mux.HandleFunc("/remove-task-assignee", requireAuth(s.removeTaskAssigneeHandler))
+	mux.HandleFunc("/add-checklist-item", requireAuth(s.addChecklistItemHandler))
+	mux.HandleFunc("/update-checklist-item", requireAuth(s.updateChecklistItemHandler))
+	mux.HandleFunc("/delete-checklist-item", requireAuth(s.deleteChecklistItemHandler))
+	mux.HandleFunc("/api/delete-expense", requireAuth(s.deleteExpenseHandler))
+	mux.HandleFunc("/delete-expense", requireAuth(s.deleteExpenseHandler))
+	mux.HandleFunc("/update-expense", requireAuth(s.updateExpenseHandler))
+	mux.HandleFunc("/expense-history", requireAuth(s.expenseHistoryHandler))
+	mux.HandleFunc("/expense-report", requireAuth(s.expenseReportHandler))
+	mux.HandleFunc("/export-expenses", requireAuth(s.exportExpensesHandler))
+	mux.HandleFunc("/import-expenses", requireAuth(s.importExpensesHandler))
+	mux.HandleFunc("/upload-receipt", requireAuth(s.uploadReceiptHandler))
+	mux.HandleFunc("/expense-receipts", requireAuth(s.expenseReceiptsHandler))
+	mux.HandleFunc("/receipt", requireAuth(s.downloadReceiptHandler))
+	mux.HandleFunc("/delete-receipt", requireAuth(s.deleteReceiptHandler))
+	mux.HandleFunc("/set-budget", requireAuth(s.setBudgetHandler))
+	mux.HandleFunc("/group-budgets", requireAuth(s.groupBudgetsHandler))
+	mux.HandleFunc("/delete-budget", requireAuth(s.deleteBudgetHandler))
+	mux.HandleFunc("/budget-alerts", requireAuth(s.budgetAlertsHandler))
+	mux.HandleFunc("/group-categories", requireAuth(s.groupCategoriesHandler))
+	mux.HandleFunc("/add-category", requireAuth(s.addCategoryHandler))
+	mux.HandleFunc("/delete-category", requireAuth(s.deleteCategoryHandler))
+	mux.HandleFunc("/add-recurring-expense", requireAuth(s.addRecurringExpenseHandler))
+	mux.HandleFunc("/group-recurring-expenses", requireAuth(s.groupRecurringExpensesHandler))
+	mux.HandleFunc("/update-recurring-expense", requireAuth(s.updateRecurringExpenseHandler))
+	mux.HandleFunc("/pause-recurring-expense", requireAuth(s.recurringStatusHandler(store.RecurPaused, store.RecurActive)))
+	mux.HandleFunc("/resume-recurring-expense", requireAuth(s.recurringStatusHandler(store.RecurActive, store.RecurPaused)))
+	mux.HandleFunc("/cancel-recurring-expense", requireAuth(s.recurringStatusHandler(store.RecurCancelled, store.RecurActive, store.RecurPaused)))
+	mux.HandleFunc("/api/group-members", requireAuth(s.groupMembersHandler))
+	mux.HandleFunc("/api/add-expense", requireAuth(s.addExpenseHandler))
+	mux.HandleFunc("/api/add-external-member", requireAuth(s.addExternalMemberHandler))
+	mux.HandleFunc("/add-external-member", requireAuth(s.addExternalMemberHandler))
 
-	// Apply CORS middleware
-	handler := enableCORS(mux)
-
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8085"
-	}
+	// Apply CORS and auth middleware
+	handler := enableCORS(s.authenticate(mux))
 
-	fmt.Printf("Starting server on port %s\n", port)
-	if err := http.ListenAndServe("127.0.0.1:"+port, handler); err != nil {
+	fmt.Printf("Starting server on %s\n", cfg.ListenAddr)
+	if err := http.ListenAndServe(cfg.ListenAddr, handler); err != nil {
 		fmt.Printf("Error starting server: %s\n", err)
 	}
 }
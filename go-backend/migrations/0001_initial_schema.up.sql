@@ -0,0 +1,104 @@
+-- Baseline schema. IF NOT EXISTS lets databases created before migrations
+-- existed adopt this version without losing data.
+
+CREATE TABLE IF NOT EXISTS users (
+    id INT NOT NULL AUTO_INCREMENT,
+    username VARCHAR(255) NOT NULL,
+    password VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_users_username (username)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS `groups` (
+    id INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(255) NOT NULL,
+    code VARCHAR(16) NOT NULL,
+    admin_id INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_groups_code (code),
+    CONSTRAINT fk_groups_admin FOREIGN KEY (admin_id) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS group_members (
+    group_id INT NOT NULL,
+    user_id INT NOT NULL,
+    joined_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (group_id, user_id),
+    KEY idx_group_members_user (user_id),
+    CONSTRAINT fk_group_members_group FOREIGN KEY (group_id) REFERENCES `groups` (id) ON DELETE CASCADE,
+    CONSTRAINT fk_group_members_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS event_dates (
+    id INT NOT NULL AUTO_INCREMENT,
+    group_id INT NOT NULL,
+    date DATE NOT NULL,
+    end_date DATE NULL,
+    time TIME NULL,
+    proposed_by INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY idx_event_dates_group (group_id, date),
+    CONSTRAINT fk_event_dates_group FOREIGN KEY (group_id) REFERENCES `groups` (id) ON DELETE CASCADE,
+    CONSTRAINT fk_event_dates_proposer FOREIGN KEY (proposed_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS date_votes (
+    event_date_id INT NOT NULL,
+    user_id INT NOT NULL,
+    available TINYINT(1) NOT NULL,
+    PRIMARY KEY (event_date_id, user_id),
+    CONSTRAINT fk_date_votes_date FOREIGN KEY (event_date_id) REFERENCES event_dates (id) ON DELETE CASCADE,
+    CONSTRAINT fk_date_votes_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS tasks (
+    id INT NOT NULL AUTO_INCREMENT,
+    group_id INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    description VARCHAR(2000) NOT NULL DEFAULT '',
+    due_date DATE NULL,
+    assignee_id INT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'todo',
+    PRIMARY KEY (id),
+    KEY idx_tasks_group (group_id),
+    CONSTRAINT fk_tasks_group FOREIGN KEY (group_id) REFERENCES `groups` (id) ON DELETE CASCADE,
+    CONSTRAINT fk_tasks_assignee FOREIGN KEY (assignee_id) REFERENCES users (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS expenses (
+    id INT NOT NULL AUTO_INCREMENT,
+    group_id INT NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    amount DOUBLE NOT NULL,
+    paid_by INT NOT NULL,
+    date DATE NOT NULL,
+    category VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY idx_expenses_group_date (group_id, date),
+    CONSTRAINT fk_expenses_group FOREIGN KEY (group_id) REFERENCES `groups` (id) ON DELETE CASCADE,
+    CONSTRAINT fk_expenses_payer FOREIGN KEY (paid_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS expense_splits (
+    expense_id INT NOT NULL,
+    user_id INT NOT NULL,
+    amount DOUBLE NOT NULL,
+    PRIMARY KEY (expense_id, user_id),
+    KEY idx_expense_splits_user (user_id),
+    CONSTRAINT fk_expense_splits_expense FOREIGN KEY (expense_id) REFERENCES expenses (id) ON DELETE CASCADE,
+    CONSTRAINT fk_expense_splits_user FOREIGN KEY (user_id) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS sessions (
+    token_hash CHAR(64) NOT NULL,
+    user_id INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    PRIMARY KEY (token_hash),
+    KEY idx_sessions_user (user_id),
+    CONSTRAINT fk_sessions_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
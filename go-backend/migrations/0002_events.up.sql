@@ -0,0 +1,13 @@
+CREATE TABLE events (
+    id INT NOT NULL AUTO_INCREMENT,
+    group_id INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    description VARCHAR(2000) NOT NULL DEFAULT '',
+    date DATE NOT NULL,
+    created_by INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY idx_events_group_date (group_id, date),
+    CONSTRAINT fk_events_group FOREIGN KEY (group_id) REFERENCES `groups` (id) ON DELETE CASCADE,
+    CONSTRAINT fk_events_creator FOREIGN KEY (created_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS notifications;
+
+ALTER TABLE event_dates
+    DROP FOREIGN KEY fk_event_dates_event,
+    DROP COLUMN event_id,
+    DROP COLUMN closed_at;
+
+ALTER TABLE events
+    DROP COLUMN time,
+    DROP COLUMN end_date;
@@ -0,0 +1,24 @@
+-- A group's open proposed dates form its current poll. Finalizing the poll
+-- closes those dates and links the winner to the scheduled event.
+ALTER TABLE events
+    ADD COLUMN end_date DATE NULL AFTER date,
+    ADD COLUMN time TIME NULL AFTER end_date;
+
+ALTER TABLE event_dates
+    ADD COLUMN closed_at DATETIME NULL,
+    ADD COLUMN event_id INT NULL,
+    ADD CONSTRAINT fk_event_dates_event FOREIGN KEY (event_id) REFERENCES events (id) ON DELETE SET NULL;
+
+CREATE TABLE notifications (
+    id INT NOT NULL AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    group_id INT NULL,
+    kind VARCHAR(32) NOT NULL,
+    message VARCHAR(1000) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    read_at DATETIME NULL,
+    PRIMARY KEY (id),
+    KEY idx_notifications_user (user_id, read_at),
+    CONSTRAINT fk_notifications_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT fk_notifications_group FOREIGN KEY (group_id) REFERENCES `groups` (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
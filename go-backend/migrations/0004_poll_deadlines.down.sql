@@ -0,0 +1,6 @@
+ALTER TABLE event_dates
+    DROP KEY idx_event_dates_deadline,
+    DROP COLUMN final_not_available,
+    DROP COLUMN final_available,
+    DROP COLUMN quorum,
+    DROP COLUMN deadline;
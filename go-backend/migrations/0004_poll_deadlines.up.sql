@@ -0,0 +1,8 @@
+-- Optional voting window per proposed date. When a date closes, its vote
+-- counts at that moment are kept in final_available/final_not_available.
+ALTER TABLE event_dates
+    ADD COLUMN deadline DATETIME NULL,
+    ADD COLUMN quorum INT NOT NULL DEFAULT 0,
+    ADD COLUMN final_available INT NULL,
+    ADD COLUMN final_not_available INT NULL,
+    ADD KEY idx_event_dates_deadline (closed_at, deadline);
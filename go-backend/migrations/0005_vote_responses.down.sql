@@ -0,0 +1,10 @@
+-- "maybe" votes become not available.
+ALTER TABLE event_dates DROP COLUMN final_maybe;
+
+ALTER TABLE date_votes ADD COLUMN available TINYINT(1) NOT NULL DEFAULT 0;
+
+UPDATE date_votes SET available = IF(response = 'yes', 1, 0);
+
+ALTER TABLE date_votes
+    DROP COLUMN preferred,
+    DROP COLUMN response;
@@ -0,0 +1,10 @@
+-- Votes become yes / maybe / no with an optional "preferred" flag.
+ALTER TABLE date_votes
+    ADD COLUMN response VARCHAR(8) NOT NULL DEFAULT 'yes',
+    ADD COLUMN preferred TINYINT(1) NOT NULL DEFAULT 0;
+
+UPDATE date_votes SET response = IF(available = 1, 'yes', 'no');
+
+ALTER TABLE date_votes DROP COLUMN available;
+
+ALTER TABLE event_dates ADD COLUMN final_maybe INT NULL AFTER final_available;
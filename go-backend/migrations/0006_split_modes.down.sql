@@ -0,0 +1,3 @@
+ALTER TABLE expense_splits DROP COLUMN weight;
+
+ALTER TABLE expenses DROP COLUMN split_mode;
@@ -0,0 +1,5 @@
+-- How an expense was divided, and the percentage or share count each
+-- participant was given (NULL for equal and exact splits).
+ALTER TABLE expenses ADD COLUMN split_mode VARCHAR(16) NOT NULL DEFAULT 'equal';
+
+ALTER TABLE expense_splits ADD COLUMN weight DOUBLE NULL;
@@ -0,0 +1,7 @@
+ALTER TABLE expense_splits ADD COLUMN amount DOUBLE NOT NULL DEFAULT 0 AFTER amount_mills;
+UPDATE expense_splits SET amount = amount_mills / 1000;
+ALTER TABLE expense_splits DROP COLUMN amount_mills;
+
+ALTER TABLE expenses ADD COLUMN amount DOUBLE NOT NULL DEFAULT 0 AFTER amount_mills;
+UPDATE expenses SET amount = amount_mills / 1000;
+ALTER TABLE expenses DROP COLUMN amount_mills;
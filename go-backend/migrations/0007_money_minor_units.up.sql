@@ -0,0 +1,20 @@
+-- Amounts move from DOUBLE to integer thousandths (mills). Old amounts are
+-- rounded to whole cents first; equal splits such as 33.333... then no
+-- longer add up to the expense, so the difference is put on the split of
+-- the lowest user ID.
+ALTER TABLE expenses ADD COLUMN amount_mills BIGINT NOT NULL DEFAULT 0 AFTER amount;
+UPDATE expenses SET amount_mills = ROUND(amount * 100) * 10;
+ALTER TABLE expenses DROP COLUMN amount;
+
+ALTER TABLE expense_splits ADD COLUMN amount_mills BIGINT NOT NULL DEFAULT 0 AFTER amount;
+UPDATE expense_splits SET amount_mills = ROUND(amount * 100) * 10;
+ALTER TABLE expense_splits DROP COLUMN amount;
+
+UPDATE expense_splits es
+JOIN (
+    SELECT e.id AS expense_id, e.amount_mills - SUM(s.amount_mills) AS diff, MIN(s.user_id) AS first_user
+    FROM expenses e JOIN expense_splits s ON s.expense_id = e.id
+    GROUP BY e.id, e.amount_mills
+) d ON es.expense_id = d.expense_id AND es.user_id = d.first_user
+SET es.amount_mills = es.amount_mills + d.diff
+WHERE d.diff <> 0;
@@ -0,0 +1,6 @@
+ALTER TABLE expenses
+    DROP COLUMN base_amount_mills,
+    DROP COLUMN exchange_rate,
+    DROP COLUMN currency;
+
+ALTER TABLE `groups` DROP COLUMN base_currency;
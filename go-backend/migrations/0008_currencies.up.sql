@@ -0,0 +1,11 @@
+-- Groups keep balances in a base currency; each expense records its own
+-- currency, the rate to the base currency and the converted amount.
+ALTER TABLE `groups` ADD COLUMN base_currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE expenses
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'USD' AFTER amount_mills,
+    ADD COLUMN exchange_rate DECIMAL(18,8) NOT NULL DEFAULT 1 AFTER currency,
+    ADD COLUMN base_amount_mills BIGINT NOT NULL DEFAULT 0 AFTER exchange_rate;
+
+UPDATE expenses e JOIN `groups` g ON e.group_id = g.id
+SET e.currency = g.base_currency, e.exchange_rate = 1, e.base_amount_mills = e.amount_mills;
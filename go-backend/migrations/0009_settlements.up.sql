@@ -0,0 +1,21 @@
+-- Payments between members that pay back what they owe.
+CREATE TABLE settlements (
+    id INT NOT NULL AUTO_INCREMENT,
+    group_id INT NOT NULL,
+    from_user_id INT NOT NULL,
+    to_user_id INT NOT NULL,
+    amount_mills BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    exchange_rate DECIMAL(18,8) NOT NULL DEFAULT 1,
+    base_amount_mills BIGINT NOT NULL,
+    date DATE NOT NULL,
+    note VARCHAR(255) NOT NULL DEFAULT '',
+    created_by INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY idx_settlements_group_date (group_id, date),
+    CONSTRAINT fk_settlements_group FOREIGN KEY (group_id) REFERENCES `groups` (id) ON DELETE CASCADE,
+    CONSTRAINT fk_settlements_from FOREIGN KEY (from_user_id) REFERENCES users (id),
+    CONSTRAINT fk_settlements_to FOREIGN KEY (to_user_id) REFERENCES users (id),
+    CONSTRAINT fk_settlements_creator FOREIGN KEY (created_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
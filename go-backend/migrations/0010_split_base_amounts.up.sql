@@ -0,0 +1,19 @@
+-- Splits keep their share in the group's base currency so balances can be
+-- summed in SQL. Foreign splits are converted at the expense's rate, each
+-- rounded to the cent on its own, so like 0007 the difference to the
+-- expense's base_amount_mills is put on the split of the lowest user ID:
+-- every expense's split base amounts add up exactly and balances can reach
+-- zero.
+ALTER TABLE expense_splits ADD COLUMN base_amount_mills BIGINT NOT NULL DEFAULT 0 AFTER amount_mills;
+
+UPDATE expense_splits s JOIN expenses e ON s.expense_id = e.id
+SET s.base_amount_mills = IF(e.base_amount_mills = e.amount_mills, s.amount_mills, ROUND(s.amount_mills * e.exchange_rate, -1));
+
+UPDATE expense_splits es
+JOIN (
+    SELECT e.id AS expense_id, e.base_amount_mills - SUM(s.base_amount_mills) AS diff, MIN(s.user_id) AS first_user
+    FROM expenses e JOIN expense_splits s ON s.expense_id = e.id
+    GROUP BY e.id, e.base_amount_mills
+) d ON es.expense_id = d.expense_id AND es.user_id = d.first_user
+SET es.base_amount_mills = es.base_amount_mills + d.diff
+WHERE d.diff <> 0;
@@ -0,0 +1,13 @@
+-- One row per edit of an expense. changes is a JSON array of
+-- {"field", "old", "new"} objects.
+CREATE TABLE expense_history (
+    id INT NOT NULL AUTO_INCREMENT,
+    expense_id INT NOT NULL,
+    changed_by INT NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    changes TEXT NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_expense_history_expense (expense_id),
+    CONSTRAINT fk_expense_history_expense FOREIGN KEY (expense_id) REFERENCES expenses (id) ON DELETE CASCADE,
+    CONSTRAINT fk_expense_history_user FOREIGN KEY (changed_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
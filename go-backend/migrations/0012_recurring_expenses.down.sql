@@ -0,0 +1,4 @@
+ALTER TABLE expenses DROP FOREIGN KEY fk_expenses_recurring;
+ALTER TABLE expenses DROP COLUMN recurring_id;
+DROP TABLE IF EXISTS recurring_expense_splits;
+DROP TABLE IF EXISTS recurring_expenses;
@@ -0,0 +1,42 @@
+-- Expense templates posted on a schedule, and a link from each posted
+-- expense back to its template.
+CREATE TABLE recurring_expenses (
+    id INT NOT NULL AUTO_INCREMENT,
+    group_id INT NOT NULL,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    amount_mills BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,
+    exchange_rate DECIMAL(18,8) NOT NULL DEFAULT 0,
+    paid_by INT NOT NULL,
+    category VARCHAR(64) NOT NULL DEFAULT '',
+    split_mode VARCHAR(16) NOT NULL DEFAULT 'equal',
+    frequency VARCHAR(16) NOT NULL,
+    `interval` INT NOT NULL DEFAULT 1,
+    start_date DATE NOT NULL,
+    end_date DATE NULL,
+    next_date DATE NOT NULL,
+    occurrence INT NOT NULL DEFAULT 0,
+    status VARCHAR(16) NOT NULL DEFAULT 'active',
+    created_by INT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY idx_recurring_expenses_group (group_id),
+    KEY idx_recurring_expenses_due (status, next_date),
+    CONSTRAINT fk_recurring_expenses_group FOREIGN KEY (group_id) REFERENCES `groups` (id) ON DELETE CASCADE,
+    CONSTRAINT fk_recurring_expenses_payer FOREIGN KEY (paid_by) REFERENCES users (id),
+    CONSTRAINT fk_recurring_expenses_creator FOREIGN KEY (created_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE recurring_expense_splits (
+    recurring_id INT NOT NULL,
+    user_id INT NOT NULL,
+    amount_mills BIGINT NOT NULL,
+    weight DOUBLE NULL,
+    PRIMARY KEY (recurring_id, user_id),
+    CONSTRAINT fk_recurring_splits_recurring FOREIGN KEY (recurring_id) REFERENCES recurring_expenses (id) ON DELETE CASCADE,
+    CONSTRAINT fk_recurring_splits_user FOREIGN KEY (user_id) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE expenses
+    ADD COLUMN recurring_id INT NULL AFTER split_mode,
+    ADD CONSTRAINT fk_expenses_recurring FOREIGN KEY (recurring_id) REFERENCES recurring_expenses (id) ON DELETE SET NULL;
@@ -0,0 +1,26 @@
+-- Each group has its own catalogue of expense categories. Existing groups
+-- get the default set plus every category their expenses already use;
+-- category names are stored in lower case.
+CREATE TABLE expense_categories (
+    id INT NOT NULL AUTO_INCREMENT,
+    group_id INT NOT NULL,
+    name VARCHAR(64) NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_expense_categories_group_name (group_id, name),
+    CONSTRAINT fk_expense_categories_group FOREIGN KEY (group_id) REFERENCES `groups` (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT IGNORE INTO expense_categories (group_id, name)
+SELECT g.id, d.name FROM `groups` g CROSS JOIN (
+    SELECT 'food' AS name UNION ALL SELECT 'travel' UNION ALL SELECT 'lodging'
+    UNION ALL SELECT 'transport' UNION ALL SELECT 'entertainment' UNION ALL SELECT 'other'
+) d;
+
+UPDATE expenses SET category = LOWER(TRIM(category));
+UPDATE recurring_expenses SET category = LOWER(TRIM(category));
+
+INSERT IGNORE INTO expense_categories (group_id, name)
+SELECT DISTINCT group_id, category FROM expenses WHERE category <> '';
+
+INSERT IGNORE INTO expense_categories (group_id, name)
+SELECT DISTINCT group_id, category FROM recurring_expenses WHERE category <> '';
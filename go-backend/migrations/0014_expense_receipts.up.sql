@@ -0,0 +1,18 @@
+-- Receipts attached to expenses. The file itself lives in blob storage
+-- under blob_key; deleting an expense removes its rows here and the server
+-- removes the blobs.
+CREATE TABLE expense_receipts (
+    id INT NOT NULL AUTO_INCREMENT,
+    expense_id INT NOT NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    blob_key VARCHAR(255) NOT NULL,
+    uploaded_by INT NOT NULL,
+    uploaded_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_expense_receipts_blob (blob_key),
+    KEY idx_expense_receipts_expense (expense_id),
+    CONSTRAINT fk_expense_receipts_expense FOREIGN KEY (expense_id) REFERENCES expenses (id) ON DELETE CASCADE,
+    CONSTRAINT fk_expense_receipts_user FOREIGN KEY (uploaded_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
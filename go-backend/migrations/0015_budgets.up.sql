@@ -0,0 +1,29 @@
+-- Spending limits in the group's base currency, on one category or (with
+-- category '') on all of the group's expenses, and the alerts recorded when
+-- an expense pushes spending over a limit.
+CREATE TABLE budgets (
+    id INT NOT NULL AUTO_INCREMENT,
+    group_id INT NOT NULL,
+    category VARCHAR(64) NOT NULL DEFAULT '',
+    limit_mills BIGINT NOT NULL,
+    created_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_budgets_group_category (group_id, category),
+    CONSTRAINT fk_budgets_group FOREIGN KEY (group_id) REFERENCES `groups` (id) ON DELETE CASCADE,
+    CONSTRAINT fk_budgets_user FOREIGN KEY (created_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE budget_alerts (
+    id INT NOT NULL AUTO_INCREMENT,
+    budget_id INT NOT NULL,
+    group_id INT NOT NULL,
+    category VARCHAR(64) NOT NULL DEFAULT '',
+    limit_mills BIGINT NOT NULL,
+    spent_mills BIGINT NOT NULL,
+    expense_id INT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY idx_budget_alerts_group (group_id, id),
+    CONSTRAINT fk_budget_alerts_budget FOREIGN KEY (budget_id) REFERENCES budgets (id) ON DELETE CASCADE,
+    CONSTRAINT fk_budget_alerts_expense FOREIGN KEY (expense_id) REFERENCES expenses (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS task_status_history;
+ALTER TABLE tasks
+    DROP FOREIGN KEY fk_tasks_status_changed_by,
+    DROP KEY idx_tasks_group_status,
+    DROP COLUMN status_changed_at,
+    DROP COLUMN status_changed_by;
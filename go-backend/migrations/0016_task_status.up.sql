@@ -0,0 +1,25 @@
+-- Task status follows a workflow (todo, in_progress, done, blocked,
+-- cancelled). The last change is kept on the task, every change in
+-- task_status_history. Statuses written before the workflow existed are
+-- mapped onto it.
+UPDATE tasks SET status = 'in_progress' WHERE status IN ('in-progress', 'in progress', 'inprogress');
+UPDATE tasks SET status = 'todo' WHERE status NOT IN ('todo', 'in_progress', 'done', 'blocked', 'cancelled');
+
+ALTER TABLE tasks
+    ADD COLUMN status_changed_by INT NULL,
+    ADD COLUMN status_changed_at TIMESTAMP NULL,
+    ADD KEY idx_tasks_group_status (group_id, status),
+    ADD CONSTRAINT fk_tasks_status_changed_by FOREIGN KEY (status_changed_by) REFERENCES users (id) ON DELETE SET NULL;
+
+CREATE TABLE task_status_history (
+    id INT NOT NULL AUTO_INCREMENT,
+    task_id INT NOT NULL,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    changed_by INT NOT NULL,
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    KEY idx_task_status_history_task (task_id),
+    CONSTRAINT fk_task_status_history_task FOREIGN KEY (task_id) REFERENCES tasks (id) ON DELETE CASCADE,
+    CONSTRAINT fk_task_status_history_user FOREIGN KEY (changed_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,11 @@
+DROP TABLE IF EXISTS task_checklist_items;
+
+ALTER TABLE tasks
+    ADD COLUMN assignee_id INT NULL AFTER due_date,
+    ADD CONSTRAINT fk_tasks_assignee FOREIGN KEY (assignee_id) REFERENCES users (id) ON DELETE SET NULL;
+
+UPDATE tasks t SET assignee_id = (
+    SELECT ta.user_id FROM task_assignees ta WHERE ta.task_id = t.id ORDER BY ta.id LIMIT 1
+);
+
+DROP TABLE IF EXISTS task_assignees;
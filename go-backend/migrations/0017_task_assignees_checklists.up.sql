@@ -0,0 +1,34 @@
+-- A task can have several assignees, kept in task_assignees in the order
+-- they were assigned, and a checklist of items ticked off one by one.
+-- tasks.assignee_id is replaced by the first assignee.
+CREATE TABLE task_assignees (
+    id INT NOT NULL AUTO_INCREMENT,
+    task_id INT NOT NULL,
+    user_id INT NOT NULL,
+    assigned_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_task_assignees (task_id, user_id),
+    KEY idx_task_assignees_user (user_id),
+    CONSTRAINT fk_task_assignees_task FOREIGN KEY (task_id) REFERENCES tasks (id) ON DELETE CASCADE,
+    CONSTRAINT fk_task_assignees_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT INTO task_assignees (task_id, user_id)
+SELECT id, assignee_id FROM tasks WHERE assignee_id IS NOT NULL ORDER BY id;
+
+ALTER TABLE tasks
+    DROP FOREIGN KEY fk_tasks_assignee,
+    DROP COLUMN assignee_id;
+
+CREATE TABLE task_checklist_items (
+    id INT NOT NULL AUTO_INCREMENT,
+    task_id INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    done TINYINT(1) NOT NULL DEFAULT 0,
+    done_by INT NULL,
+    done_at TIMESTAMP NULL,
+    PRIMARY KEY (id),
+    KEY idx_task_checklist_items_task (task_id),
+    CONSTRAINT fk_task_checklist_items_task FOREIGN KEY (task_id) REFERENCES tasks (id) ON DELETE CASCADE,
+    CONSTRAINT fk_task_checklist_items_done_by FOREIGN KEY (done_by) REFERENCES users (id) ON DELETE SET NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,250 @@
+// Package money does exact arithmetic on amounts of money. Amounts are kept
+// as integer thousandths of the currency unit (mills), fine enough for every
+// currency's minor unit, so splitting and summing never drifts the way
+// float64 does. Results are rounded to the minor unit of their currency
+// (a cent, a yen, a fils), never to a finer step.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// Amount is a sum of money in thousandths of the currency unit
+type Amount int64
+
+// scaleDecimals is the number of decimals an Amount keeps
+const scaleDecimals = 3
+
+// ErrInvalid is returned for amounts that aren't plain decimals with at most
+// three fractional digits
+var ErrInvalid = errors.New("invalid amount (want a number with at most 3 decimals)")
+
+// minorUnits lists the ISO 4217 currencies whose minor unit isn't a
+// hundredth, by number of decimals. Every other currency has 2.
+var minorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// Decimals returns the number of decimals of a currency's minor unit
+func Decimals(currency string) int {
+	if d, ok := minorUnits[currency]; ok {
+		return d
+	}
+	return 2
+}
+
+// Unit returns a currency's minor unit, e.g. 10 for a US cent or 1000 for a yen
+func Unit(currency string) Amount {
+	unit := Amount(1)
+	for i := Decimals(currency); i < scaleDecimals; i++ {
+		unit *= 10
+	}
+	return unit
+}
+
+// Round rounds the amount half away from zero to the currency's minor unit
+func Round(a Amount, currency string) Amount {
+	unit := Unit(currency)
+	half := unit / 2
+	if a < 0 {
+		half = -half
+	}
+	return (a + half) / unit * unit
+}
+
+// Check returns an error for amounts finer than the currency's minor unit,
+// such as 10.50 JPY. The message is meant for the client.
+func Check(a Amount, currency string) error {
+	if a%Unit(currency) == 0 {
+		return nil
+	}
+	if Decimals(currency) == 0 {
+		return fmt.Errorf("%s amounts can't have decimals", currency)
+	}
+	return fmt.Errorf("%s amounts have at most %d decimals", currency, Decimals(currency))
+}
+
+// Parse reads a decimal such as "12", "-3.5", "0.07" or "1.125"
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	neg := false
+	if strings.HasPrefix(s, "-") {
+		neg, s = true, s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" && (!hasFrac || frac == "") {
+		return 0, ErrInvalid
+	}
+	if len(frac) > scaleDecimals || (hasFrac && frac == "") || !isDigits(whole) || !isDigits(frac) {
+		return 0, ErrInvalid
+	}
+	frac += strings.Repeat("0", scaleDecimals-len(frac))
+	if whole == "" {
+		whole = "0"
+	}
+	n, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return 0, ErrInvalid
+	}
+	if neg {
+		n = -n
+	}
+	return Amount(n), nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the amount with two decimals, e.g. "-3.50", or three when
+// it needs them, e.g. "1.125"
+func (a Amount) String() string {
+	if a%10 != 0 {
+		return a.format(3)
+	}
+	return a.format(2)
+}
+
+// Format formats the amount with the decimals of the currency's minor unit,
+// e.g. "1500" for JPY or "3.250" for BHD
+func Format(a Amount, currency string) string {
+	if a%Unit(currency) != 0 {
+		return a.String()
+	}
+	return a.format(Decimals(currency))
+}
+
+func (a Amount) format(decimals int) string {
+	sign := ""
+	n := int64(a)
+	if n < 0 {
+		sign, n = "-", -n
+	}
+	whole, frac := n/1000, n%1000
+	if decimals == 0 {
+		return fmt.Sprintf("%s%d", sign, whole)
+	}
+	for i := decimals; i < scaleDecimals; i++ {
+		frac /= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, whole, decimals, frac)
+}
+
+// MarshalJSON writes the amount as a JSON number such as 12.30
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	v, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = v
+	return nil
+}
+
+// Allocate divides total in proportion to weights, in whole minor units of
+// the currency. The parts always add up to total: every part is rounded down
+// and the units left over go one at a time to the parts with the largest
+// remainders, earlier parts first on ties. A total finer than the minor unit
+// (see Check) puts that excess on the first weighted part. Weights must be
+// non-negative and not all zero.
+func Allocate(total Amount, weights []int64, currency string) []Amount {
+	var sum int64
+	for _, w := range weights {
+		sum += w
+	}
+	parts := make([]Amount, len(weights))
+	if sum == 0 {
+		return parts
+	}
+	sign := Amount(1)
+	if total < 0 {
+		sign, total = -1, -total
+	}
+	unit := Unit(currency)
+	excess := total % unit
+	total /= unit
+	remainders := make([]int64, len(weights))
+	var allocated Amount
+	for i, w := range weights {
+		q, r := mulDiv(int64(total), w, sum)
+		parts[i] = Amount(q)
+		remainders[i] = r
+		allocated += parts[i]
+	}
+	for left := total - allocated; left > 0; left-- {
+		best := -1
+		for i, r := range remainders {
+			if weights[i] > 0 && (best == -1 || r > remainders[best]) {
+				best = i
+			}
+		}
+		parts[best]++
+		remainders[best] = -1
+	}
+	for i := range parts {
+		parts[i] *= unit
+	}
+	for i, w := range weights {
+		if w > 0 {
+			parts[i] += excess
+			break
+		}
+	}
+	for i := range parts {
+		parts[i] *= sign
+	}
+	return parts
+}
+
+// mulDiv returns a*b/c and its remainder without overflowing the product.
+// The quotient fits because b <= c.
+func mulDiv(a, b, c int64) (int64, int64) {
+	hi, lo := bits.Mul64(uint64(a), uint64(b))
+	q, r := bits.Div64(hi, lo, uint64(c))
+	return int64(q), int64(r)
+}
+
+// SplitEvenly divides total into n parts that differ by at most one minor
+// unit of the currency
+func SplitEvenly(total Amount, n int, currency string) []Amount {
+	weights := make([]int64, n)
+	for i := range weights {
+		weights[i] = 1
+	}
+	return Allocate(total, weights, currency)
+}
+
+// Convert multiplies the amount by an exchange rate into currency, rounding
+// half away from zero to that currency's minor unit
+func Convert(a Amount, rate float64, currency string) Amount {
+	unit := float64(Unit(currency))
+	return Amount(math.Round(float64(a)*rate/unit)) * Unit(currency)
+}
+
+// Sum adds up amounts
+func Sum(amounts ...Amount) Amount {
+	var total Amount
+	for _, a := range amounts {
+		total += a
+	}
+	return total
+}
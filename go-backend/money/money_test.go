@@ -0,0 +1,168 @@
+package money
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Amount
+		wantErr bool
+	}{
+		{in: "12", want: 12000},
+		{in: "-3.5", want: -3500},
+		{in: "0.07", want: 70},
+		{in: "1.125", want: 1125},
+		{in: "+2", want: 2000},
+		{in: ".5", want: 500},
+		{in: "-.5", want: -500},
+		{in: " 4.20 ", want: 4200},
+		{in: "-0", want: 0},
+		{in: "", wantErr: true},
+		{in: "-", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "1.", wantErr: true},
+		{in: "1.2345", wantErr: true},
+		{in: "1e2", wantErr: true},
+		{in: "1.2.3", wantErr: true},
+		{in: "--1", wantErr: true},
+		{in: "1,50", wantErr: true},
+		{in: "99999999999999999999", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if tt.wantErr {
+			if err != ErrInvalid {
+				t.Errorf("Parse(%q) = %d, %v; want ErrInvalid", tt.in, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %d, %v; want %d", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		a        Amount
+		currency string
+		want     string
+	}{
+		{a: -3500, currency: "USD", want: "-3.50"},
+		{a: -70, currency: "USD", want: "-0.07"},
+		{a: 0, currency: "USD", want: "0.00"},
+		{a: 1500000, currency: "JPY", want: "1500"},
+		{a: 3250, currency: "BHD", want: "3.250"},
+		// Finer than the currency's unit: keep every decimal
+		{a: 1125, currency: "USD", want: "1.125"},
+	}
+	for _, tt := range tests {
+		if got := Format(tt.a, tt.currency); got != tt.want {
+			t.Errorf("Format(%d, %s) = %q, want %q", tt.a, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		a        Amount
+		currency string
+		want     string
+	}{
+		{a: 1050, currency: "USD"},
+		{a: 1125, currency: "USD", want: "USD amounts have at most 2 decimals"},
+		{a: 1125, currency: "BHD"},
+		{a: 1000000, currency: "JPY"},
+		{a: 1500, currency: "JPY", want: "JPY amounts can't have decimals"},
+		{a: -1500, currency: "KRW", want: "KRW amounts can't have decimals"},
+	}
+	for _, tt := range tests {
+		err := Check(tt.a, tt.currency)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tt.want {
+			t.Errorf("Check(%d, %s) = %q, want %q", tt.a, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name     string
+		total    Amount
+		weights  []int64
+		currency string
+		want     []Amount
+	}{
+		{name: "leftover cent to the first part", total: 10000, weights: []int64{1, 1, 1}, currency: "USD", want: []Amount{3340, 3330, 3330}},
+		{name: "negative total", total: -10000, weights: []int64{1, 1, 1}, currency: "USD", want: []Amount{-3340, -3330, -3330}},
+		{name: "largest remainder first", total: 70, weights: []int64{2, 3, 5}, currency: "USD", want: []Amount{10, 20, 40}},
+		{name: "zero weight gets nothing", total: 50, weights: []int64{0, 1, 1}, currency: "USD", want: []Amount{0, 30, 20}},
+		{name: "whole yen", total: 100000, weights: []int64{1, 1, 1}, currency: "JPY", want: []Amount{34000, 33000, 33000}},
+		{name: "three-decimal currency", total: 1000, weights: []int64{1, 1, 1}, currency: "BHD", want: []Amount{334, 333, 333}},
+		{name: "excess below the unit to the first weighted part", total: 10005, weights: []int64{0, 1, 1}, currency: "USD", want: []Amount{0, 5005, 5000}},
+		{name: "all weights zero", total: 10000, weights: []int64{0, 0}, currency: "USD", want: []Amount{0, 0}},
+		{name: "no weights", total: 10000, weights: nil, currency: "USD", want: []Amount{}},
+		{name: "large total", total: 9e15, weights: []int64{1, 2}, currency: "USD", want: []Amount{3e15, 6e15}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Allocate(tt.total, tt.weights, tt.currency)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Allocate(%d, %v, %s) = %v, want %v", tt.total, tt.weights, tt.currency, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitEvenly(t *testing.T) {
+	tests := []struct {
+		total    Amount
+		n        int
+		currency string
+		want     []Amount
+	}{
+		{total: 100000, n: 3, currency: "USD", want: []Amount{33340, 33330, 33330}},
+		{total: 10, n: 3, currency: "USD", want: []Amount{10, 0, 0}},
+		{total: -50, n: 2, currency: "USD", want: []Amount{-30, -20}},
+		{total: 0, n: 2, currency: "USD", want: []Amount{0, 0}},
+		{total: 1000000, n: 3, currency: "KRW", want: []Amount{334000, 333000, 333000}},
+		{total: 10, n: 4, currency: "KWD", want: []Amount{3, 3, 2, 2}},
+		{total: 10000, n: 0, currency: "USD", want: []Amount{}},
+	}
+	for _, tt := range tests {
+		got := SplitEvenly(tt.total, tt.n, tt.currency)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitEvenly(%d, %d, %s) = %v, want %v", tt.total, tt.n, tt.currency, got, tt.want)
+		}
+		if tt.n > 0 && Sum(got...) != tt.total {
+			t.Errorf("SplitEvenly(%d, %d, %s) adds up to %d", tt.total, tt.n, tt.currency, Sum(got...))
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		a        Amount
+		rate     float64
+		currency string
+		want     Amount
+	}{
+		{a: 1001000, rate: 0.0067, currency: "USD", want: 6710},
+		{a: 10010, rate: 149.37, currency: "JPY", want: 1495000},
+		{a: -10010, rate: 149.37, currency: "JPY", want: -1495000},
+		{a: 10125, rate: 2.65, currency: "USD", want: 26830},
+		{a: 10000, rate: 0.377, currency: "BHD", want: 3770},
+		{a: 10000, rate: 1, currency: "USD", want: 10000},
+	}
+	for _, tt := range tests {
+		if got := Convert(tt.a, tt.rate, tt.currency); got != tt.want {
+			t.Errorf("Convert(%d, %g, %s) = %d, want %d", tt.a, tt.rate, tt.currency, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-backend/store"
+)
+
+// notifyGroup sends a notification to every member of a group. Failures are
+// logged rather than returned: the change that triggered them already happened.
+func (s *server) notifyGroup(ctx context.Context, groupID int, kind, message string) {
+	members, err := s.store.Groups.ListMembers(ctx, groupID)
+	if err != nil {
+		fmt.Println("[DEBUG] Could not list members to notify:", err)
+		return
+	}
+	for _, m := range members {
+		n := store.Notification{UserID: m.ID, GroupID: groupID, Kind: kind, Message: message}
+		if err := s.store.Notifications.Notify(ctx, n); err != nil {
+			fmt.Println("[DEBUG] Could not notify user", m.ID, ":", err)
+		}
+	}
+}
+
+// List the caller's notifications, newest first (?unread=true for unread only)
+func (s *server) notificationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	user, _ := currentUser(r)
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+	notifications, err := s.store.Notifications.ListNotifications(r.Context(), user.ID, unreadOnly)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if notifications == nil {
+		notifications = []store.Notification{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notifications)
+}
+
+// Mark the caller's notifications as read; an empty ids list marks all of them
+func (s *server) readNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		IDs []int `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	user, _ := currentUser(r)
+	if err := s.store.Notifications.MarkRead(r.Context(), user.ID, req.IDs, time.Now()); err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
+}
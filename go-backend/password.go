@@ -0,0 +1,47 @@
+package main
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// maxPasswordBytes is the longest password bcrypt accepts
+const maxPasswordBytes = 72
+
+// hashPassword returns the bcrypt hash stored in users.password
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// isPasswordHash reports whether a stored password is already a bcrypt hash.
+// Rows written before hashing was introduced still hold the plaintext.
+func isPasswordHash(stored string) bool {
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// checkPassword verifies a login attempt against the stored value.
+// needsUpgrade is true when the stored value is legacy plaintext (or a hash
+// with an outdated cost) and should be replaced with a fresh hash.
+func checkPassword(stored, password string) (ok bool, needsUpgrade bool) {
+	// Guests and external members are stored without a password and can't log in
+	if stored == "" {
+		return false, false
+	}
+	if isPasswordHash(stored) {
+		if bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) != nil {
+			return false, false
+		}
+		cost, err := bcrypt.Cost([]byte(stored))
+		return true, err == nil && cost < bcrypt.DefaultCost
+	}
+	if subtle.ConstantTimeCompare([]byte(stored), []byte(password)) != 1 {
+		return false, false
+	}
+	return true, true
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPassword(t *testing.T) {
+	current, err := hashPassword("s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cheap, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name             string
+		stored, password string
+		wantOK           bool
+		wantUpgrade      bool
+	}{
+		{name: "hash", stored: current, password: "s3cret", wantOK: true},
+		{name: "hash, wrong password", stored: current, password: "s3cret!"},
+		{name: "hash, empty password", stored: current, password: ""},
+		{name: "outdated cost", stored: string(cheap), password: "s3cret", wantOK: true, wantUpgrade: true},
+		{name: "outdated cost, wrong password", stored: string(cheap), password: "secret"},
+		{name: "legacy plaintext", stored: "hunter2", password: "hunter2", wantOK: true, wantUpgrade: true},
+		{name: "legacy plaintext, wrong password", stored: "hunter2", password: "hunter3"},
+		{name: "legacy plaintext, prefix only", stored: "hunter2", password: "hunter"},
+		{name: "the hash itself as the password", stored: current, password: current},
+		{name: "no password", stored: "", password: ""},
+		{name: "no password, any attempt", stored: "", password: "anything"},
+	}
+	for _, tt := range tests {
+		ok, upgrade := checkPassword(tt.stored, tt.password)
+		if ok != tt.wantOK || upgrade != tt.wantUpgrade {
+			t.Errorf("%s: checkPassword = %v, %v; want %v, %v", tt.name, ok, upgrade, tt.wantOK, tt.wantUpgrade)
+		}
+	}
+}
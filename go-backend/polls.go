@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-backend/store"
+)
+
+// defaultEventTitle names events finalized without an explicit title
+const defaultEventTitle = "Group hangout"
+
+// Ranking weights: a "yes" counts double a "maybe", and marking a date as
+// preferred adds a point on top of the member's answer
+const (
+	scoreYes       = 2
+	scoreMaybe     = 1
+	scorePreferred = 1
+)
+
+// dateScore is the ranking score of a proposed date
+func dateScore(d store.DateSummary) int {
+	return d.AvailableVotes*scoreYes + d.MaybeVotes*scoreMaybe + d.PreferredVotes*scorePreferred
+}
+
+// betterDate orders dates by score, then earliest date, then earliest proposal
+func betterDate(a, b store.DateSummary) bool {
+	if sa, sb := dateScore(a), dateScore(b); sa != sb {
+		return sa > sb
+	}
+	if a.Date != b.Date {
+		return a.Date < b.Date
+	}
+	return a.ID < b.ID
+}
+
+// pickWinner returns the best-scoring open date
+func pickWinner(dates []store.DateSummary) (store.DateSummary, bool) {
+	var best store.DateSummary
+	found := false
+	for _, d := range dates {
+		if d.Closed {
+			continue
+		}
+		if !found || betterDate(d, best) {
+			best, found = d, true
+		}
+	}
+	return best, found
+}
+
+// rankedDate is a proposed date as listed by groupDatesHandler
+type rankedDate struct {
+	store.DateSummary
+	Score    int          `json:"score"`
+	Rank     int          `json:"rank"` // 1 is best; equal scores share a rank
+	NotVoted []store.User `json:"not_voted"`
+}
+
+// rankDates scores the dates and finds the members who have not voted on
+// each one. The result keeps the order of dates.
+func rankDates(dates []store.DateSummary, votes []store.DateVote, members []store.User) []rankedDate {
+	voted := map[int]map[int]bool{}
+	for _, v := range votes {
+		if voted[v.EventDateID] == nil {
+			voted[v.EventDateID] = map[int]bool{}
+		}
+		voted[v.EventDateID][v.UserID] = true
+	}
+	ranked := make([]rankedDate, len(dates))
+	for i, d := range dates {
+		ranked[i] = rankedDate{DateSummary: d, Score: dateScore(d), NotVoted: []store.User{}}
+		for _, m := range members {
+			if !voted[d.ID][m.ID] {
+				ranked[i].NotVoted = append(ranked[i].NotVoted, m)
+			}
+		}
+	}
+	for i := range ranked {
+		ranked[i].Rank = 1
+		for j := range ranked {
+			if ranked[j].Score > ranked[i].Score {
+				ranked[i].Rank++
+			}
+		}
+	}
+	return ranked
+}
+
+// parseBallot validates a vote. response wins over the legacy available flag.
+func parseBallot(w http.ResponseWriter, response string, available *bool, preferred bool) (store.Ballot, bool) {
+	b := store.Ballot{Response: response, Preferred: preferred}
+	if b.Response == "" && available != nil {
+		b.Response = store.VoteNo
+		if *available {
+			b.Response = store.VoteYes
+		}
+	}
+	switch b.Response {
+	case store.VoteYes, store.VoteMaybe:
+	case store.VoteNo:
+		if b.Preferred {
+			http.Error(w, "A date you are not available for cannot be preferred", http.StatusBadRequest)
+			return b, false
+		}
+	default:
+		http.Error(w, "Invalid response (want yes, maybe or no)", http.StatusBadRequest)
+		return b, false
+	}
+	return b, true
+}
+
+// finalizeDate turns a proposed date into a scheduled event, closes the
+// group's poll and tells every member about it
+func (s *server) finalizeDate(ctx context.Context, date store.ProposedDate, title, description string, createdBy int) (store.Event, error) {
+	if title == "" {
+		title = defaultEventTitle
+	}
+	event := store.Event{
+		GroupID: date.GroupID, Title: title, Description: description,
+		Date: date.Date, EndDate: date.EndDate, Time: date.Time, CreatedBy: createdBy,
+	}
+	id, err := s.store.Dates.FinalizeDate(ctx, date.ID, event)
+	if err != nil {
+		return event, err
+	}
+	event.ID = id
+	when := event.Date
+	if event.Time != "" {
+		when += " " + event.Time
+	}
+	s.notifyGroup(ctx, event.GroupID, "event_scheduled", fmt.Sprintf("%s is scheduled for %s", event.Title, when))
+	return event, nil
+}
+
+// Finalize a group's date poll (admin only). Without event_date_id the
+// best-scoring open date wins.
+func (s *server) finalizeDateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		GroupID     int    `json:"group_id"`
+		EventDateID int    `json:"event_date_id"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	var date store.ProposedDate
+	if req.EventDateID != 0 {
+		var ok bool
+		if date, ok = s.authorizeDate(w, r, req.EventDateID, roleAdmin); !ok {
+			return
+		}
+	} else {
+		if !s.requireGroupRole(w, r, req.GroupID, roleAdmin) {
+			return
+		}
+		dates, err := s.store.Dates.ListDates(r.Context(), req.GroupID)
+		if err != nil {
+			http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		winner, found := pickWinner(dates)
+		if !found {
+			http.Error(w, "No open proposed dates to finalize", http.StatusConflict)
+			return
+		}
+		if date, err = s.store.Dates.GetDate(r.Context(), winner.ID); err != nil {
+			lookupFailed(w, err, "Event date not found")
+			return
+		}
+	}
+	if date.Closed {
+		http.Error(w, "Voting on this date is closed", http.StatusConflict)
+		return
+	}
+	user, _ := currentUser(r)
+	event, err := s.finalizeDate(r.Context(), date, req.Title, req.Description, user.ID)
+	if err == store.ErrConflict {
+		http.Error(w, "Voting on this date is closed", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Println("[DEBUG] Finalized date", date.ID, "as event", event.ID)
+	date.Closed, date.EventID = true, event.ID
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"event": event, "date": date})
+}
+
+// parseDeadline validates an optional RFC 3339 deadline and quorum
+func parseDeadline(w http.ResponseWriter, raw string, quorum int) (*time.Time, bool) {
+	if quorum < 0 {
+		http.Error(w, "Quorum cannot be negative", http.StatusBadRequest)
+		return nil, false
+	}
+	if raw == "" {
+		return nil, true
+	}
+	deadline, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		http.Error(w, "Invalid deadline (want RFC 3339, e.g. 2024-06-01T18:00:00Z)", http.StatusBadRequest)
+		return nil, false
+	}
+	if !deadline.After(time.Now()) {
+		http.Error(w, "Deadline must be in the future", http.StatusBadRequest)
+		return nil, false
+	}
+	deadline = deadline.UTC().Truncate(time.Second)
+	return &deadline, true
+}
+
+// votingOpen rejects votes on closed dates and on dates past their deadline
+// that the scheduler has not closed yet
+func votingOpen(w http.ResponseWriter, date store.ProposedDate, now time.Time) bool {
+	if date.Closed {
+		http.Error(w, "Voting on this date is closed", http.StatusConflict)
+		return false
+	}
+	if date.Deadline != nil && !now.Before(*date.Deadline) {
+		http.Error(w, "Voting on this date ended at "+date.Deadline.Format(time.RFC3339), http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// Set or clear the voting deadline and quorum of a proposed date (proposer or group admin)
+func (s *server) setDateDeadlineHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		EventDateID int    `json:"event_date_id"`
+		Deadline    string `json:"deadline"` // empty removes the deadline
+		Quorum      int    `json:"quorum"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	deadline, ok := parseDeadline(w, req.Deadline, req.Quorum)
+	if !ok {
+		return
+	}
+	date, ok := s.authorizeDate(w, r, req.EventDateID, roleMember)
+	if !ok {
+		return
+	}
+	user, _ := currentUser(r)
+	if date.ProposedBy != user.ID && !s.requireGroupRole(w, r, date.GroupID, roleAdmin) {
+		return
+	}
+	if date.Closed {
+		http.Error(w, "Voting on this date is closed", http.StatusConflict)
+		return
+	}
+	if err := s.store.Dates.SetDeadline(r.Context(), date.ID, deadline, req.Quorum); err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
+}
+
+// closeExpiredPolls closes every open date whose deadline has passed. In each
+// group, the best-scoring expired date that reached its quorum of "yes"
+// votes is scheduled as an event (which closes the rest of that group's poll);
+// otherwise the expired dates are just closed with their tally.
+func (s *server) closeExpiredPolls(ctx context.Context, now time.Time) {
+	expired, err := s.store.Dates.ExpiredDates(ctx, now)
+	if err != nil {
+		fmt.Println("[DEBUG] Could not list expired polls:", err)
+		return
+	}
+	byGroup := map[int][]store.ProposedDate{}
+	var groupIDs []int
+	for _, d := range expired {
+		if _, seen := byGroup[d.GroupID]; !seen {
+			groupIDs = append(groupIDs, d.GroupID)
+		}
+		byGroup[d.GroupID] = append(byGroup[d.GroupID], d)
+	}
+	for _, groupID := range groupIDs {
+		if err := s.closeGroupPoll(ctx, groupID, byGroup[groupID]); err != nil {
+			fmt.Println("[DEBUG] Could not close expired poll of group", groupID, ":", err)
+		}
+	}
+}
+
+// closeGroupPoll handles the expired dates of one group for closeExpiredPolls
+func (s *server) closeGroupPoll(ctx context.Context, groupID int, expired []store.ProposedDate) error {
+	summaries, err := s.store.Dates.ListDates(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	dates := map[int]store.ProposedDate{}
+	for _, d := range expired {
+		dates[d.ID] = d
+	}
+	var reached []store.DateSummary
+	for _, sum := range summaries {
+		if d, ok := dates[sum.ID]; ok && d.Quorum > 0 && sum.AvailableVotes >= d.Quorum {
+			reached = append(reached, sum)
+		}
+	}
+	if winner, found := pickWinner(reached); found {
+		date := dates[winner.ID]
+		event, err := s.finalizeDate(ctx, date, "", "", date.ProposedBy)
+		if err == store.ErrConflict {
+			return nil // finalized by hand in the meantime
+		}
+		if err == nil {
+			fmt.Println("[DEBUG] Poll deadline scheduled date", date.ID, "as event", event.ID)
+		}
+		return err
+	}
+	for _, d := range expired {
+		err := s.store.Dates.CloseDate(ctx, d.ID)
+		if err == store.ErrConflict || err == store.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		closed, err := s.store.Dates.GetDate(ctx, d.ID)
+		if err != nil {
+			return err
+		}
+		message := "Voting closed for " + closed.Date
+		if closed.Tally != nil {
+			message += fmt.Sprintf(": %d yes, %d maybe, %d no", closed.Tally.Available, closed.Tally.Maybe, closed.Tally.NotAvailable)
+		}
+		if closed.Quorum > 0 {
+			message += fmt.Sprintf(" (quorum of %d not reached)", closed.Quorum)
+		}
+		s.notifyGroup(ctx, groupID, "poll_closed", message)
+	}
+	return nil
+}
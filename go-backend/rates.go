@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+
+	"go-backend/store"
+)
+
+// rateProvider looks up exchange rates between currencies
+type rateProvider interface {
+	// Rate returns how many units of to one unit of from is worth
+	Rate(from, to string) (float64, error)
+}
+
+// errNoRate means the provider doesn't know the rate; the client has to send one
+var errNoRate = errors.New("no exchange rate available")
+
+// manualRates knows no rates: every foreign expense carries its own rate
+type manualRates struct{}
+
+func (manualRates) Rate(from, to string) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+	return 0, errNoRate
+}
+
+// fileRates serves rates from a JSON file so conversions work offline:
+//
+//	{"base": "USD", "rates": {"EUR": 0.92, "THB": 36.1}}
+//
+// Each rate is the number of units of that currency one unit of base buys.
+// Cross rates are derived through base.
+type fileRates struct {
+	Base  string             `json:"base"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+func loadFileRates(path string) (*fileRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading rates file: %w", err)
+	}
+	var f fileRates
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing rates file %s: %w", path, err)
+	}
+	if !isCurrencyCode(f.Base) {
+		return nil, fmt.Errorf("rates file %s: invalid base currency %q", path, f.Base)
+	}
+	for code, rate := range f.Rates {
+		if !isCurrencyCode(code) || rate <= 0 {
+			return nil, fmt.Errorf("rates file %s: invalid rate %v for %q", path, rate, code)
+		}
+	}
+	if f.Rates == nil {
+		f.Rates = map[string]float64{}
+	}
+	f.Rates[f.Base] = 1
+	return &f, nil
+}
+
+func (f *fileRates) Rate(from, to string) (float64, error) {
+	fromRate, ok := f.Rates[from]
+	if !ok {
+		return 0, errNoRate
+	}
+	toRate, ok := f.Rates[to]
+	if !ok {
+		return 0, errNoRate
+	}
+	return toRate / fromRate, nil
+}
+
+// newRateProvider builds the provider selected in the config
+func newRateProvider(cfg RatesConfig) (rateProvider, error) {
+	if cfg.Provider == "file" {
+		return loadFileRates(cfg.File)
+	}
+	return manualRates{}, nil
+}
+
+// isCurrencyCode reports whether s looks like an ISO 4217 code (e.g. EUR)
+func isCurrencyCode(s string) bool {
+	return len(s) == 3 && strings.Trim(s, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") == ""
+}
+
+// exchangeRate picks the rate for converting from into the base currency:
+// 1 for the base currency itself, else the client's rate, else the
+// provider's. On failure it writes the error response and returns false.
+func (s *server) exchangeRate(w http.ResponseWriter, from, base string, clientRate float64) (float64, bool) {
+	rate, err := s.resolveRate(from, base, clientRate)
+	if _, ok := err.(clientError); ok {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, false
+	}
+	if err != nil {
+		http.Error(w, "Exchange rate error: "+err.Error(), http.StatusInternalServerError)
+		return 0, false
+	}
+	return rate, true
+}
+
+// clientError is a rate error caused by the request rather than the provider
+type clientError string
+
+func (e clientError) Error() string { return string(e) }
+
+// resolveRate is exchangeRate without the response, for callers that
+// report errors their own way
+func (s *server) resolveRate(from, base string, clientRate float64) (float64, error) {
+	if !isCurrencyCode(from) {
+		return 0, clientError("Invalid currency (want an ISO 4217 code such as USD)")
+	}
+	if from == base {
+		if clientRate != 0 && clientRate != 1 {
+			return 0, clientError("exchange_rate must be 1 for the group's base currency")
+		}
+		return 1, nil
+	}
+	if clientRate < 0 {
+		return 0, clientError("exchange_rate must be positive")
+	}
+	if clientRate > 0 {
+		return roundRate(clientRate), nil
+	}
+	rate, err := s.rates.Rate(from, base)
+	if err == errNoRate {
+		return 0, clientError(fmt.Sprintf("exchange_rate is required: no %s to %s rate is available", from, base))
+	}
+	if err != nil {
+		return 0, err
+	}
+	return roundRate(rate), nil
+}
+
+// roundRate keeps the 8 decimals the expenses.exchange_rate column stores,
+// so the base amount is computed from the rate that is saved
+func roundRate(rate float64) float64 {
+	return math.Round(rate*1e8) / 1e8
+}
+
+// Change a group's base currency (admin only). Only allowed before the
+// group has expenses, whose rates are relative to the old base.
+func (s *server) setBaseCurrencyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		GroupID  int    `json:"group_id"`
+		Currency string `json:"currency"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if !isCurrencyCode(req.Currency) {
+		http.Error(w, "Invalid currency (want an ISO 4217 code such as USD)", http.StatusBadRequest)
+		return
+	}
+	if !s.requireGroupRole(w, r, req.GroupID, roleAdmin) {
+		return
+	}
+	// Recurring expenses keep their amount in the currency they were set up
+	// in, so scheduled ones block the change like recorded amounts do
+	err := s.store.Groups.SetBaseCurrency(r.Context(), req.GroupID, req.Currency)
+	if err == store.ErrConflict {
+		http.Error(w, "The base currency can't change once the group has expenses, settlements, budgets or scheduled recurring expenses", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
+}
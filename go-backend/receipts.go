@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"go-backend/store"
+)
+
+// maxReceiptBytes caps the size of one receipt file
+const maxReceiptBytes = 10 << 20
+
+// receiptTypes are the content types accepted for receipts, as detected
+// from the file's first bytes (the client's Content-Type is not trusted)
+var receiptTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// receiptKey picks a new, unguessable blob key for a receipt of an expense
+func receiptKey(e store.Expense) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("receipts/%d/%d/%s", e.GroupID, e.ID, hex.EncodeToString(b)), nil
+}
+
+// deleteBlobs removes the blobs of receipts whose rows are already gone.
+// Failures only leave orphaned files behind, so they are logged.
+func (s *server) deleteBlobs(receipts []store.Receipt) {
+	for _, rc := range receipts {
+		if err := s.blobs.Delete(rc.BlobKey); err != nil {
+			fmt.Println("[DEBUG] Failed to delete receipt blob", rc.BlobKey+":", err)
+		}
+	}
+}
+
+// Attach a receipt to an expense: a multipart form with expense_id and the
+// file in "receipt". Images (JPEG, PNG, GIF, WebP) and PDFs up to 10 MB.
+func (s *server) uploadReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	// Leave room for the form's other parts and boundaries
+	r.Body = http.MaxBytesReader(w, r.Body, maxReceiptBytes+1<<20)
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Receipt too large (max 10 MB)", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+	expenseID, err := strconv.Atoi(r.FormValue("expense_id"))
+	if err != nil {
+		http.Error(w, "Invalid expense_id", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("receipt")
+	if err != nil {
+		http.Error(w, "Missing receipt file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	if header.Size > maxReceiptBytes {
+		http.Error(w, "Receipt too large (max 10 MB)", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if header.Size == 0 {
+		http.Error(w, "Receipt file is empty", http.StatusBadRequest)
+		return
+	}
+	expense, ok := s.authorizeExpense(w, r, expenseID, roleMember)
+	if !ok {
+		return
+	}
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	sniff = sniff[:n]
+	contentType := http.DetectContentType(sniff)
+	if !receiptTypes[contentType] {
+		http.Error(w, "Unsupported receipt type "+contentType+" (want JPEG, PNG, GIF, WebP or PDF)", http.StatusUnsupportedMediaType)
+		return
+	}
+	filename := filepath.Base(filepath.Clean("/" + header.Filename))
+	if filename == "/" || filename == "." {
+		filename = "receipt"
+	}
+	if len(filename) > 255 {
+		filename = filename[len(filename)-255:]
+	}
+
+	key, err := receiptKey(expense)
+	if err != nil {
+		http.Error(w, "Storage error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.blobs.Put(key, io.MultiReader(bytes.NewReader(sniff), file)); err != nil {
+		http.Error(w, "Storage error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	user, _ := currentUser(r)
+	rc := store.Receipt{
+		ExpenseID: expense.ID, Filename: filename, ContentType: contentType,
+		Size: header.Size, BlobKey: key, UploadedBy: user.ID,
+	}
+	rc.ID, err = s.store.Receipts.AddReceipt(r.Context(), rc)
+	if err != nil {
+		// The expense was deleted meanwhile, or the row couldn't be written
+		s.deleteBlobs([]store.Receipt{rc})
+		lookupFailed(w, err, "Expense not found")
+		return
+	}
+	fmt.Println("[DEBUG] Stored receipt", rc.ID, "for expense", expense.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": rc.ID, "filename": filename, "content_type": contentType, "size": rc.Size})
+}
+
+// List the receipts attached to an expense
+func (s *server) expenseReceiptsHandler(w http.ResponseWriter, r *http.Request) {
+	expenseID, ok := idParam(w, r, "expense_id")
+	if !ok {
+		return
+	}
+	if _, ok := s.authorizeExpense(w, r, expenseID, roleMember); !ok {
+		return
+	}
+	receipts, err := s.store.Receipts.ListReceipts(r.Context(), expenseID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if receipts == nil {
+		receipts = []store.Receipt{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(receipts)
+}
+
+// authorizeReceipt loads a receipt and checks that the caller belongs to
+// the group of its expense
+func (s *server) authorizeReceipt(w http.ResponseWriter, r *http.Request, receiptID int) (store.Receipt, bool) {
+	rc, err := s.store.Receipts.GetReceipt(r.Context(), receiptID)
+	if err != nil {
+		lookupFailed(w, err, "Receipt not found")
+		return rc, false
+	}
+	_, ok := s.authorizeExpense(w, r, rc.ExpenseID, roleMember)
+	return rc, ok
+}
+
+// Download a receipt (members of the expense's group only)
+func (s *server) downloadReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	receiptID, ok := idParam(w, r, "receipt_id")
+	if !ok {
+		return
+	}
+	rc, ok := s.authorizeReceipt(w, r, receiptID)
+	if !ok {
+		return
+	}
+	content, err := s.blobs.Open(rc.BlobKey)
+	if err == errBlobNotFound {
+		http.Error(w, "Receipt file is missing", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Storage error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer content.Close()
+	w.Header().Set("Content-Type", rc.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(rc.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": rc.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := io.Copy(w, content); err != nil {
+		fmt.Println("[DEBUG] Failed to send receipt", rc.ID, err)
+	}
+}
+
+// Remove a receipt from an expense
+func (s *server) deleteReceiptHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ReceiptID int `json:"receipt_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	rc, ok := s.authorizeReceipt(w, r, req.ReceiptID)
+	if !ok {
+		return
+	}
+	if err := s.store.Receipts.DeleteReceipt(r.Context(), rc.ID); err != nil {
+		lookupFailed(w, err, "Receipt not found")
+		return
+	}
+	s.deleteBlobs([]store.Receipt{rc})
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
+}
@@ -0,0 +1,428 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-backend/money"
+	"go-backend/store"
+)
+
+// maxCatchUp caps how many missed occurrences of one template a scheduler
+// run posts; the rest follow on the next runs
+const maxCatchUp = 100
+
+// occurrenceDate returns the date of occurrence n of a template, counting its
+// start date as 0. Monthly occurrences keep the start date's day of the
+// month, or fall on the last day of shorter months.
+func occurrenceDate(rec store.RecurringExpense, n int) string {
+	start, _ := time.Parse("2006-01-02", rec.StartDate)
+	var next time.Time
+	switch rec.Frequency {
+	case store.RecurWeekly:
+		next = start.AddDate(0, 0, 7*rec.Interval*n)
+	case store.RecurMonthly:
+		first := time.Date(start.Year(), start.Month()+time.Month(rec.Interval*n), 1, 0, 0, 0, 0, time.UTC)
+		last := first.AddDate(0, 1, -1).Day()
+		next = first.AddDate(0, 0, min(start.Day(), last)-1)
+	default:
+		next = start.AddDate(0, 0, rec.Interval*n)
+	}
+	return next.Format("2006-01-02")
+}
+
+// advanceRecurring moves a template to its next occurrence, ending it when
+// that falls after its end date
+func advanceRecurring(rec *store.RecurringExpense) {
+	rec.Occurrence++
+	rec.NextDate = occurrenceDate(*rec, rec.Occurrence)
+	if rec.EndDate != "" && rec.NextDate > rec.EndDate {
+		rec.Status = store.RecurEnded
+	}
+}
+
+// checkSchedule validates the schedule fields of a template
+func checkSchedule(w http.ResponseWriter, rec store.RecurringExpense) bool {
+	switch rec.Frequency {
+	case store.RecurWeekly, store.RecurMonthly, store.RecurCustom:
+	default:
+		http.Error(w, "Invalid frequency (want weekly, monthly or custom)", http.StatusBadRequest)
+		return false
+	}
+	if rec.Interval < 1 {
+		http.Error(w, "interval must be at least 1", http.StatusBadRequest)
+		return false
+	}
+	if !isISODate(rec.StartDate) {
+		http.Error(w, "Invalid start_date (want YYYY-MM-DD)", http.StatusBadRequest)
+		return false
+	}
+	if rec.EndDate != "" && !isISODate(rec.EndDate) {
+		http.Error(w, "Invalid end_date (want YYYY-MM-DD)", http.StatusBadRequest)
+		return false
+	}
+	if rec.EndDate != "" && rec.EndDate < rec.NextDate {
+		http.Error(w, "end_date is before the next occurrence", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// templateRate validates a template's currency and returns the rate to
+// store: the client's rate for a foreign currency, or zero to look the rate
+// up each time an occurrence is posted
+func (s *server) templateRate(w http.ResponseWriter, currency, base string, clientRate float64) (float64, bool) {
+	rate, ok := s.exchangeRate(w, currency, base, clientRate)
+	if !ok || currency == base || clientRate == 0 {
+		return 0, ok
+	}
+	return rate, true
+}
+
+// Add a recurring expense template. Its occurrences are posted as expenses
+// by the scheduler, starting on start_date (default today).
+func (s *server) addRecurringExpenseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		GroupID      int          `json:"group_id"`
+		Description  string       `json:"description"`
+		Amount       money.Amount `json:"amount"`
+		PaidBy       int          `json:"paid_by"` // defaults to the caller
+		Category     string       `json:"category"`
+		Currency     string       `json:"currency"`
+		ExchangeRate float64      `json:"exchange_rate"` // fixed rate; otherwise looked up when posting
+		SplitMode    string       `json:"split_mode"`
+		SplitWith    []int        `json:"split_with"`
+		Splits       []splitInput `json:"splits"`
+		Frequency    string       `json:"frequency"` // weekly, monthly or custom
+		Interval     int          `json:"interval"`  // weeks, months or (custom) days; default 1
+		StartDate    string       `json:"start_date"`
+		EndDate      string       `json:"end_date"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Amount <= 0 {
+		http.Error(w, "Amount must be positive", http.StatusBadRequest)
+		return
+	}
+	if req.SplitMode == "" {
+		req.SplitMode = store.SplitEqual
+	}
+	if req.Interval == 0 && req.Frequency != store.RecurCustom {
+		req.Interval = 1
+	}
+	if req.StartDate == "" {
+		req.StartDate = time.Now().Format("2006-01-02")
+	}
+	user, _ := currentUser(r)
+	if req.PaidBy == 0 {
+		req.PaidBy = user.ID
+	}
+	rec := store.RecurringExpense{
+		GroupID: req.GroupID, Description: req.Description, Amount: req.Amount, Currency: req.Currency,
+		PaidBy: req.PaidBy, Category: req.Category, SplitMode: req.SplitMode,
+		Frequency: req.Frequency, Interval: req.Interval, StartDate: req.StartDate, EndDate: req.EndDate,
+		NextDate: req.StartDate, Status: store.RecurActive, CreatedBy: user.ID,
+	}
+	if !checkSchedule(w, rec) {
+		return
+	}
+	if !s.requireGroupRole(w, r, req.GroupID, roleMember) {
+		return
+	}
+	var ok bool
+	if rec.Category, ok = s.checkCategory(w, r, req.GroupID, req.Category); !ok {
+		return
+	}
+	group, err := s.store.Groups.GetGroup(r.Context(), req.GroupID)
+	if err != nil {
+		lookupFailed(w, err, "Group not found")
+		return
+	}
+	if rec.Currency == "" {
+		rec.Currency = group.BaseCurrency
+	}
+	splits, err := computeSplits(req.SplitMode, req.Amount, rec.Currency, req.SplitWith, req.Splits)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rec.Splits = splits
+	if !s.requireMembers(w, r, req.GroupID, append(splits, store.Split{UserID: req.PaidBy})) {
+		return
+	}
+	if rec.Rate, ok = s.templateRate(w, rec.Currency, group.BaseCurrency, req.ExchangeRate); !ok {
+		return
+	}
+	id, err := s.store.Recurring.CreateRecurring(r.Context(), rec)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Println("[DEBUG] Added recurring expense", id, "to group", req.GroupID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "next_date": rec.NextDate})
+}
+
+// List a group's recurring expense templates
+func (s *server) groupRecurringExpensesHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := groupIDParam(w, r)
+	if !ok || !s.requireGroupRole(w, r, groupID, roleMember) {
+		return
+	}
+	templates, err := s.store.Recurring.ListRecurring(r.Context(), groupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if templates == nil {
+		templates = []store.RecurringExpense{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(templates)
+}
+
+// Edit a recurring expense. Omitted fields keep their value. Changing the
+// schedule restarts it from start_date (default the next occurrence);
+// expenses already posted are not touched.
+func (s *server) updateRecurringExpenseHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		RecurringID  int          `json:"recurring_id"`
+		Description  string       `json:"description"`
+		Amount       money.Amount `json:"amount"`
+		PaidBy       int          `json:"paid_by"`
+		Category     string       `json:"category"`
+		Currency     string       `json:"currency"`
+		ExchangeRate float64      `json:"exchange_rate"`
+		SplitMode    string       `json:"split_mode"`
+		SplitWith    []int        `json:"split_with"`
+		Splits       []splitInput `json:"splits"`
+		Frequency    string       `json:"frequency"`
+		Interval     int          `json:"interval"`
+		StartDate    string       `json:"start_date"`
+		EndDate      *string      `json:"end_date"` // "" removes the end date
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Amount < 0 {
+		http.Error(w, "Amount must be positive", http.StatusBadRequest)
+		return
+	}
+	rec, ok := s.authorizeRecurring(w, r, req.RecurringID)
+	if !ok {
+		return
+	}
+	if rec.Status != store.RecurActive && rec.Status != store.RecurPaused {
+		http.Error(w, "This recurring expense is "+rec.Status, http.StatusConflict)
+		return
+	}
+	old := rec
+	if req.Description != "" {
+		rec.Description = req.Description
+	}
+	if req.Amount != 0 {
+		rec.Amount = req.Amount
+	}
+	if req.Category != "" {
+		if rec.Category, ok = s.checkCategory(w, r, rec.GroupID, req.Category); !ok {
+			return
+		}
+	}
+	if req.SplitMode != "" {
+		rec.SplitMode = req.SplitMode
+	}
+	if req.PaidBy != 0 {
+		rec.PaidBy = req.PaidBy
+	}
+	if req.Currency != "" {
+		rec.Currency = req.Currency
+	}
+	group, err := s.store.Groups.GetGroup(r.Context(), rec.GroupID)
+	if err != nil {
+		lookupFailed(w, err, "Group not found")
+		return
+	}
+	if rec.Amount != old.Amount || rec.SplitMode != old.SplitMode || rec.Currency != old.Currency ||
+		req.SplitWith != nil || req.Splits != nil {
+		splitWith, inputs := req.SplitWith, req.Splits
+		if splitWith == nil && inputs == nil && (rec.SplitMode == old.SplitMode || rec.SplitMode == store.SplitEqual) {
+			splitWith, inputs = resplitInputs(rec.SplitMode, old.Splits)
+		}
+		splits, err := computeSplits(rec.SplitMode, rec.Amount, rec.Currency, splitWith, inputs)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rec.Splits = splits
+	}
+	if !s.requireMembers(w, r, rec.GroupID, append(rec.Splits, store.Split{UserID: rec.PaidBy})) {
+		return
+	}
+	if req.Currency != "" || req.ExchangeRate != 0 {
+		if rec.Rate, ok = s.templateRate(w, rec.Currency, group.BaseCurrency, req.ExchangeRate); !ok {
+			return
+		}
+	}
+	if req.Frequency != "" || req.Interval != 0 || req.StartDate != "" {
+		if req.Frequency != "" {
+			rec.Frequency = req.Frequency
+		}
+		if req.Interval != 0 {
+			rec.Interval = req.Interval
+		}
+		rec.StartDate = rec.NextDate
+		if req.StartDate != "" {
+			rec.StartDate = req.StartDate
+		}
+		rec.NextDate, rec.Occurrence = rec.StartDate, 0
+	}
+	if req.EndDate != nil {
+		rec.EndDate = *req.EndDate
+	}
+	if !checkSchedule(w, rec) {
+		return
+	}
+	if err := s.store.Recurring.UpdateRecurring(r.Context(), rec); err != nil {
+		lookupFailed(w, err, "Recurring expense not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rec)
+}
+
+// recurringStatusHandler moves a template to status, from one of the given
+// statuses: pause, resume and cancel. Resuming skips the occurrences that
+// fell while the template was paused.
+func (s *server) recurringStatusHandler(status string, from ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req struct {
+			RecurringID int `json:"recurring_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+		rec, ok := s.authorizeRecurring(w, r, req.RecurringID)
+		if !ok {
+			return
+		}
+		allowed := false
+		for _, f := range from {
+			allowed = allowed || rec.Status == f
+		}
+		if !allowed {
+			http.Error(w, "This recurring expense is "+rec.Status, http.StatusConflict)
+			return
+		}
+		rec.Status = status
+		if status == store.RecurActive {
+			today := time.Now().UTC().Format("2006-01-02")
+			for rec.Status == store.RecurActive && rec.NextDate < today {
+				advanceRecurring(&rec)
+			}
+		}
+		if err := s.store.Recurring.UpdateRecurring(r.Context(), rec); err != nil {
+			lookupFailed(w, err, "Recurring expense not found")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(rec)
+	}
+}
+
+// postingRate is the rate an occurrence is posted at
+func (s *server) postingRate(rec store.RecurringExpense, base string) (float64, error) {
+	if rec.Currency == base {
+		return 1, nil
+	}
+	if rec.Rate > 0 {
+		return rec.Rate, nil
+	}
+	rate, err := s.rates.Rate(rec.Currency, base)
+	if err != nil {
+		return 0, fmt.Errorf("no %s to %s rate: %w", rec.Currency, base, err)
+	}
+	return roundRate(rate), nil
+}
+
+// postDueRecurring posts every occurrence of the active templates that is
+// due by now, catching up on occurrences missed while the server was down
+func (s *server) postDueRecurring(ctx context.Context, now time.Time) {
+	today := now.UTC().Format("2006-01-02")
+	due, err := s.store.Recurring.DueRecurring(ctx, today)
+	if err != nil {
+		fmt.Println("[DEBUG] Could not list due recurring expenses:", err)
+		return
+	}
+	for _, rec := range due {
+		if err := s.postRecurring(ctx, rec, today); err != nil {
+			fmt.Println("[DEBUG] Could not post recurring expense", rec.ID, ":", err)
+		}
+	}
+}
+
+// postRecurring posts the due occurrences of one template. Each expense is
+// created in the same transaction that advances the template, so an
+// occurrence is never posted twice or skipped. Budgets are checked once it
+// commits, as for expenses added by hand.
+func (s *server) postRecurring(ctx context.Context, rec store.RecurringExpense, today string) error {
+	group, err := s.store.Groups.GetGroup(ctx, rec.GroupID)
+	if err != nil {
+		return err
+	}
+	for n := 0; n < maxCatchUp && rec.Status == store.RecurActive && rec.NextDate <= today; n++ {
+		rate, err := s.postingRate(rec, group.BaseCurrency)
+		if err != nil {
+			return err
+		}
+		date := rec.NextDate
+		expense := store.Expense{
+			GroupID: rec.GroupID, Description: rec.Description, Amount: rec.Amount,
+			Currency: rec.Currency, Rate: rate, BaseAmount: money.Convert(rec.Amount, rate, group.BaseCurrency),
+			PaidBy: rec.PaidBy, Date: date, Category: rec.Category, SplitMode: rec.SplitMode, RecurringID: rec.ID,
+		}
+		splits := append([]store.Split(nil), rec.Splits...)
+		setBaseAmounts(expense, group.BaseCurrency, splits)
+		advanceRecurring(&rec)
+		err = s.store.WithTx(ctx, func(tx *store.Store) error {
+			if err := tx.Recurring.AdvanceRecurring(ctx, rec, date); err != nil {
+				return err
+			}
+			expense.ID, err = tx.Expenses.CreateExpense(ctx, expense, splits)
+			return err
+		})
+		if err == store.ErrConflict {
+			return nil // paused, edited or posted elsewhere in the meantime
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println("[DEBUG] Posted recurring expense", rec.ID, "for", date, "as expense", expense.ID)
+		description := rec.Description
+		if description == "" {
+			description = "A recurring expense"
+		}
+		s.notifyGroup(ctx, rec.GroupID, "recurring_expense", fmt.Sprintf("%s of %s %s was added for %s",
+			description, money.Format(rec.Amount, rec.Currency), rec.Currency, date))
+		s.checkBudgets(ctx, group, expense)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"go-backend/store"
+)
+
+func TestOccurrenceDate(t *testing.T) {
+	tests := []struct {
+		frequency string
+		interval  int
+		start     string
+		n         int
+		want      string
+	}{
+		{frequency: store.RecurMonthly, interval: 1, start: "2024-01-31", n: 0, want: "2024-01-31"},
+		{frequency: store.RecurMonthly, interval: 1, start: "2024-01-31", n: 1, want: "2024-02-29"},
+		{frequency: store.RecurMonthly, interval: 1, start: "2024-01-31", n: 2, want: "2024-03-31"},
+		{frequency: store.RecurMonthly, interval: 1, start: "2024-01-31", n: 3, want: "2024-04-30"},
+		{frequency: store.RecurMonthly, interval: 1, start: "2024-01-31", n: 13, want: "2025-02-28"},
+		{frequency: store.RecurMonthly, interval: 1, start: "2023-01-31", n: 1, want: "2023-02-28"},
+		{frequency: store.RecurMonthly, interval: 1, start: "2024-12-31", n: 2, want: "2025-02-28"},
+		{frequency: store.RecurMonthly, interval: 1, start: "2024-02-29", n: 1, want: "2024-03-29"},
+		{frequency: store.RecurMonthly, interval: 12, start: "2024-02-29", n: 1, want: "2025-02-28"},
+		{frequency: store.RecurMonthly, interval: 12, start: "2024-02-29", n: 4, want: "2028-02-29"},
+		{frequency: store.RecurMonthly, interval: 3, start: "2024-11-30", n: 1, want: "2025-02-28"},
+		{frequency: store.RecurMonthly, interval: 1, start: "2099-12-15", n: 1, want: "2100-01-15"},
+		{frequency: store.RecurWeekly, interval: 1, start: "2024-02-22", n: 1, want: "2024-02-29"},
+		{frequency: store.RecurWeekly, interval: 2, start: "2024-02-22", n: 3, want: "2024-04-04"},
+		{frequency: store.RecurWeekly, interval: 1, start: "2023-02-22", n: 1, want: "2023-03-01"},
+		{frequency: store.RecurCustom, interval: 10, start: "2023-12-31", n: 6, want: "2024-02-29"},
+		{frequency: store.RecurCustom, interval: 1, start: "2100-02-28", n: 1, want: "2100-03-01"},
+	}
+	for _, tt := range tests {
+		rec := store.RecurringExpense{Frequency: tt.frequency, Interval: tt.interval, StartDate: tt.start}
+		if got := occurrenceDate(rec, tt.n); got != tt.want {
+			t.Errorf("occurrenceDate(%s every %d from %s, %d) = %s, want %s", tt.frequency, tt.interval, tt.start, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestAdvanceRecurring(t *testing.T) {
+	tests := []struct {
+		name       string
+		rec        store.RecurringExpense
+		steps      int
+		wantDates  []string
+		wantStatus string
+	}{
+		{
+			name:       "month-end doesn't drift after February",
+			rec:        store.RecurringExpense{Frequency: store.RecurMonthly, Interval: 1, StartDate: "2024-01-31"},
+			steps:      4,
+			wantDates:  []string{"2024-02-29", "2024-03-31", "2024-04-30", "2024-05-31"},
+			wantStatus: store.RecurActive,
+		},
+		{
+			name:       "leap day yearly",
+			rec:        store.RecurringExpense{Frequency: store.RecurMonthly, Interval: 12, StartDate: "2024-02-29"},
+			steps:      4,
+			wantDates:  []string{"2025-02-28", "2026-02-28", "2027-02-28", "2028-02-29"},
+			wantStatus: store.RecurActive,
+		},
+		{
+			name:       "occurrence on the end date still posts",
+			rec:        store.RecurringExpense{Frequency: store.RecurMonthly, Interval: 1, StartDate: "2024-01-31", EndDate: "2024-02-29"},
+			steps:      1,
+			wantDates:  []string{"2024-02-29"},
+			wantStatus: store.RecurActive,
+		},
+		{
+			name:       "ends after the end date",
+			rec:        store.RecurringExpense{Frequency: store.RecurMonthly, Interval: 1, StartDate: "2024-01-31", EndDate: "2024-03-30"},
+			steps:      2,
+			wantDates:  []string{"2024-02-29", "2024-03-31"},
+			wantStatus: store.RecurEnded,
+		},
+		{
+			name:       "weekly across the leap day",
+			rec:        store.RecurringExpense{Frequency: store.RecurWeekly, Interval: 1, StartDate: "2024-02-15", EndDate: "2024-03-01"},
+			steps:      3,
+			wantDates:  []string{"2024-02-22", "2024-02-29", "2024-03-07"},
+			wantStatus: store.RecurEnded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := tt.rec
+			rec.NextDate, rec.Status = rec.StartDate, store.RecurActive
+			var dates []string
+			for i := 0; i < tt.steps; i++ {
+				advanceRecurring(&rec)
+				dates = append(dates, rec.NextDate)
+			}
+			if !reflect.DeepEqual(dates, tt.wantDates) {
+				t.Errorf("next dates = %v, want %v", dates, tt.wantDates)
+			}
+			if rec.Occurrence != tt.steps {
+				t.Errorf("occurrence = %d, want %d", rec.Occurrence, tt.steps)
+			}
+			if rec.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", rec.Status, tt.wantStatus)
+			}
+		})
+	}
+}
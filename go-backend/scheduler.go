@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// runScheduler runs the periodic background jobs until the process exits.
+// The first run happens right away so work missed while the server was down
+// is caught up.
+func (s *server) runScheduler(interval time.Duration) {
+	s.runJobs(context.Background(), time.Now())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		s.runJobs(context.Background(), now)
+	}
+}
+
+// runJobs runs every background job once
+func (s *server) runJobs(ctx context.Context, now time.Time) {
+	s.closeExpiredPolls(ctx, now)
+	s.postDueRecurring(ctx, now)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"go-backend/money"
+	"go-backend/store"
+)
+
+// Record that one member paid another back
+func (s *server) recordSettlementHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		GroupID      int          `json:"group_id"`
+		FromUserID   int          `json:"from_user_id"` // defaults to the caller
+		ToUserID     int          `json:"to_user_id"`
+		Amount       money.Amount `json:"amount"`
+		Currency     string       `json:"currency"` // defaults to the group's base currency
+		ExchangeRate float64      `json:"exchange_rate"`
+		Date         string       `json:"date"` // defaults to today
+		Note         string       `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Amount <= 0 {
+		http.Error(w, "Amount must be positive", http.StatusBadRequest)
+		return
+	}
+	if req.Date == "" {
+		req.Date = time.Now().Format("2006-01-02")
+	} else if !isISODate(req.Date) {
+		http.Error(w, "Invalid date (want YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+	if !s.requireGroupRole(w, r, req.GroupID, roleMember) {
+		return
+	}
+	user, _ := currentUser(r)
+	if req.FromUserID == 0 {
+		req.FromUserID = user.ID
+	}
+	if req.FromUserID == req.ToUserID {
+		http.Error(w, "A member can't pay themselves", http.StatusBadRequest)
+		return
+	}
+	parties := []store.Split{{UserID: req.FromUserID}, {UserID: req.ToUserID}}
+	if !s.requireMembers(w, r, req.GroupID, parties) {
+		return
+	}
+	group, err := s.store.Groups.GetGroup(r.Context(), req.GroupID)
+	if err != nil {
+		lookupFailed(w, err, "Group not found")
+		return
+	}
+	if req.Currency == "" {
+		req.Currency = group.BaseCurrency
+	}
+	if err := money.Check(req.Amount, req.Currency); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rate, ok := s.exchangeRate(w, req.Currency, group.BaseCurrency, req.ExchangeRate)
+	if !ok {
+		return
+	}
+	settlement := store.Settlement{
+		GroupID: req.GroupID, FromUserID: req.FromUserID, ToUserID: req.ToUserID,
+		Amount: req.Amount, Currency: req.Currency, Rate: rate, BaseAmount: money.Convert(req.Amount, rate, group.BaseCurrency),
+		Date: req.Date, Note: req.Note, CreatedBy: user.ID,
+	}
+	settlement.ID, err = s.store.Settlements.CreateSettlement(r.Context(), settlement)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Println("[DEBUG] Recorded settlement", settlement.ID, "in group", req.GroupID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settlement)
+}
+
+// List a group's settlements, newest first
+func (s *server) groupSettlementsHandler(w http.ResponseWriter, r *http.Request) {
+	groupID, ok := groupIDParam(w, r)
+	if !ok || !s.requireGroupRole(w, r, groupID, roleMember) {
+		return
+	}
+	settlements, err := s.store.Settlements.ListSettlements(r.Context(), groupID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if settlements == nil {
+		settlements = []store.Settlement{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settlements)
+}
+
+// Undo a settlement (whoever recorded it, either party, or the group admin)
+func (s *server) undoSettlementHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		SettlementID int `json:"settlement_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	settlement, err := s.store.Settlements.GetSettlement(r.Context(), req.SettlementID)
+	if err != nil {
+		lookupFailed(w, err, "Settlement not found")
+		return
+	}
+	user, _ := currentUser(r)
+	role := roleAdmin
+	if user.ID == settlement.CreatedBy || user.ID == settlement.FromUserID || user.ID == settlement.ToUserID {
+		role = roleMember
+	}
+	if !s.requireGroupRole(w, r, settlement.GroupID, role) {
+		return
+	}
+	if err := s.store.Settlements.DeleteSettlement(r.Context(), settlement.ID); err != nil {
+		lookupFailed(w, err, "Settlement not found")
+		return
+	}
+	fmt.Println("[DEBUG] Undid settlement", settlement.ID)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
+}
@@ -0,0 +1,1493 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go-backend/money"
+)
+
+// Memory implements every store interface in process memory. It mirrors the
+// behaviour of the MySQL implementation and is meant for tests and local dev.
+type Memory struct {
+	mu   *sync.Mutex
+	inTx bool // mu is held by the transaction this view belongs to
+	*memTables
+}
+
+// memTables is the data of a Memory store
+type memTables struct {
+	nextID   map[string]int
+	users    map[int]User
+	sessions map[string]memSession
+	groups   map[int]Group
+	members  map[int]map[int]bool // group ID -> user IDs
+	dates    map[int]ProposedDate
+	votes    map[int]map[int]Ballot // event date ID -> user ID -> ballot
+	events   map[int]Event
+	tasks    map[int]Task
+	taskLog  map[int][]TaskStatusChange // task ID -> status changes, oldest first
+	assigned map[int][]int              // task ID -> user IDs, in the order assigned
+	checks   map[int]ChecklistItem
+	expenses map[int]Expense
+	splits   map[int][]Split           // expense ID -> splits
+	history  map[int][]ExpenseRevision // expense ID -> revisions, oldest first
+	receipts map[int]Receipt
+
+	categories    map[int]Category
+	recurring     map[int]RecurringExpense
+	budgets       map[int]Budget
+	budgetAlerts  map[int]BudgetAlert
+	settlements   map[int]Settlement
+	notifications map[int]Notification
+	readAt        map[int]time.Time // notification ID -> read time
+}
+
+type memSession struct {
+	userID    int
+	expiresAt time.Time
+}
+
+// NewMemory returns an empty in-memory Store
+func NewMemory() *Store {
+	m := &Memory{mu: &sync.Mutex{}, memTables: &memTables{
+		nextID:   map[string]int{},
+		users:    map[int]User{},
+		sessions: map[string]memSession{},
+		groups:   map[int]Group{},
+		members:  map[int]map[int]bool{},
+		dates:    map[int]ProposedDate{},
+		votes:    map[int]map[int]Ballot{},
+		events:   map[int]Event{},
+		tasks:    map[int]Task{},
+		taskLog:  map[int][]TaskStatusChange{},
+		assigned: map[int][]int{},
+		checks:   map[int]ChecklistItem{},
+		expenses: map[int]Expense{},
+		splits:   map[int][]Split{},
+		history:  map[int][]ExpenseRevision{},
+		receipts: map[int]Receipt{},
+
+		categories:    map[int]Category{},
+		recurring:     map[int]RecurringExpense{},
+		budgets:       map[int]Budget{},
+		budgetAlerts:  map[int]BudgetAlert{},
+		settlements:   map[int]Settlement{},
+		notifications: map[int]Notification{},
+		readAt:        map[int]time.Time{},
+	}}
+	return m.store()
+}
+
+func (m *Memory) store() *Store {
+	return &Store{
+		Users: m, Sessions: m, Groups: m, Dates: m, Events: m, Tasks: m, Expenses: m,
+		Receipts: m, Categories: m, Recurring: m, Budgets: m, Settlements: m, Notifications: m,
+		withTx: m.withTx,
+	}
+}
+
+// lock takes the store's mutex unless a transaction already holds it
+func (m *Memory) lock() {
+	if !m.inTx {
+		m.mu.Lock()
+	}
+}
+
+func (m *Memory) unlock() {
+	if !m.inTx {
+		m.mu.Unlock()
+	}
+}
+
+// withTx holds the mutex for the whole of fn, so its writes are not
+// interleaved with anyone else's, and restores a snapshot if fn fails
+func (m *Memory) withTx(ctx context.Context, fn func(tx *Store) error) error {
+	if m.inTx {
+		return fn(m.store())
+	}
+	m.lock()
+	defer m.unlock()
+	snapshot := m.memTables.clone()
+	tx := &Memory{mu: m.mu, inTx: true, memTables: m.memTables}
+	if err := fn(tx.store()); err != nil {
+		*m.memTables = *snapshot
+		return err
+	}
+	return nil
+}
+
+// clone copies the tables deeply enough that writes to the copy leave the
+// original untouched
+func (t *memTables) clone() *memTables {
+	return &memTables{
+		nextID:   cloneMap(t.nextID),
+		users:    cloneMap(t.users),
+		sessions: cloneMap(t.sessions),
+		groups:   cloneMap(t.groups),
+		members:  cloneNested(t.members),
+		dates:    cloneMap(t.dates),
+		votes:    cloneNested(t.votes),
+		events:   cloneMap(t.events),
+		tasks:    cloneMap(t.tasks),
+		taskLog:  cloneMap(t.taskLog),
+		assigned: cloneMap(t.assigned),
+		checks:   cloneMap(t.checks),
+		expenses: cloneMap(t.expenses),
+		splits:   cloneMap(t.splits),
+		history:  cloneMap(t.history),
+		receipts: cloneMap(t.receipts),
+
+		categories:    cloneMap(t.categories),
+		recurring:     cloneMap(t.recurring),
+		budgets:       cloneMap(t.budgets),
+		budgetAlerts:  cloneMap(t.budgetAlerts),
+		settlements:   cloneMap(t.settlements),
+		notifications: cloneMap(t.notifications),
+		readAt:        cloneMap(t.readAt),
+	}
+}
+
+// cloneMap copies a map. Slice values are shared: the store only ever
+// replaces or appends to them, never writes to their elements.
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+func cloneNested[K1, K2 comparable, V any](m map[K1]map[K2]V) map[K1]map[K2]V {
+	c := make(map[K1]map[K2]V, len(m))
+	for k, v := range m {
+		c[k] = cloneMap(v)
+	}
+	return c
+}
+
+func (m *Memory) newID(table string) int {
+	m.nextID[table]++
+	return m.nextID[table]
+}
+
+// sortedKeys returns map keys in ascending order so listings are stable
+func sortedKeys[V any](items map[int]V) []int {
+	keys := make([]int, 0, len(items))
+	for k := range items {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// Users
+
+func (m *Memory) CreateUser(ctx context.Context, username, password string) (int, error) {
+	m.lock()
+	defer m.unlock()
+	for _, u := range m.users {
+		if u.Username == username {
+			return 0, ErrConflict
+		}
+	}
+	id := m.newID("users")
+	m.users[id] = User{ID: id, Username: username, Password: password}
+	return id, nil
+}
+
+func (m *Memory) GetUser(ctx context.Context, id int) (User, error) {
+	m.lock()
+	defer m.unlock()
+	u, ok := m.users[id]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return u, nil
+}
+
+func (m *Memory) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	m.lock()
+	defer m.unlock()
+	for _, u := range m.users {
+		if u.Username == username {
+			return u, nil
+		}
+	}
+	return User{}, ErrNotFound
+}
+
+func (m *Memory) UpdatePassword(ctx context.Context, id int, password string) error {
+	m.lock()
+	defer m.unlock()
+	u, ok := m.users[id]
+	if !ok {
+		return nil
+	}
+	u.Password = password
+	m.users[id] = u
+	return nil
+}
+
+// Sessions
+
+func (m *Memory) CreateSession(ctx context.Context, tokenHash string, userID int, expiresAt time.Time) error {
+	m.lock()
+	defer m.unlock()
+	m.sessions[tokenHash] = memSession{userID: userID, expiresAt: expiresAt}
+	return nil
+}
+
+func (m *Memory) SessionUser(ctx context.Context, tokenHash string, now time.Time) (User, error) {
+	m.lock()
+	defer m.unlock()
+	s, ok := m.sessions[tokenHash]
+	if !ok || !s.expiresAt.After(now) {
+		return User{}, ErrNotFound
+	}
+	u, ok := m.users[s.userID]
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return User{ID: u.ID, Username: u.Username}, nil
+}
+
+func (m *Memory) DeleteSession(ctx context.Context, tokenHash string) error {
+	m.lock()
+	defer m.unlock()
+	delete(m.sessions, tokenHash)
+	return nil
+}
+
+// Groups
+
+func (m *Memory) CreateGroup(ctx context.Context, g Group) (int, error) {
+	m.lock()
+	defer m.unlock()
+	for _, existing := range m.groups {
+		if existing.Code == g.Code {
+			return 0, ErrConflict
+		}
+	}
+	g.ID = m.newID("groups")
+	g.Members = nil
+	m.groups[g.ID] = g
+	m.members[g.ID] = map[int]bool{g.AdminID: true}
+	return g.ID, nil
+}
+
+func (m *Memory) GetGroup(ctx context.Context, id int) (Group, error) {
+	m.lock()
+	defer m.unlock()
+	g, ok := m.groups[id]
+	if !ok {
+		return Group{}, ErrNotFound
+	}
+	return g, nil
+}
+
+func (m *Memory) GetGroupByCode(ctx context.Context, code string) (Group, error) {
+	m.lock()
+	defer m.unlock()
+	for _, g := range m.groups {
+		if g.Code == code {
+			return g, nil
+		}
+	}
+	return Group{}, ErrNotFound
+}
+
+func (m *Memory) ListGroupsForUser(ctx context.Context, userID int) ([]Group, error) {
+	m.lock()
+	defer m.unlock()
+	var groups []Group
+	for _, id := range sortedKeys(m.groups) {
+		if m.members[id][userID] {
+			groups = append(groups, m.groups[id])
+		}
+	}
+	return groups, nil
+}
+
+func (m *Memory) UpdateCode(ctx context.Context, id int, code string) error {
+	m.lock()
+	defer m.unlock()
+	for _, g := range m.groups {
+		if g.Code == code && g.ID != id {
+			return ErrConflict
+		}
+	}
+	g, ok := m.groups[id]
+	if !ok {
+		return nil
+	}
+	g.Code = code
+	m.groups[id] = g
+	return nil
+}
+
+func (m *Memory) SetBaseCurrency(ctx context.Context, id int, currency string) error {
+	m.lock()
+	defer m.unlock()
+	g, ok := m.groups[id]
+	if !ok {
+		return nil
+	}
+	for _, e := range m.expenses {
+		if e.GroupID == id {
+			return ErrConflict
+		}
+	}
+	for _, st := range m.settlements {
+		if st.GroupID == id {
+			return ErrConflict
+		}
+	}
+	for _, b := range m.budgets {
+		if b.GroupID == id {
+			return ErrConflict
+		}
+	}
+	for _, r := range m.recurring {
+		if r.GroupID == id && (r.Status == RecurActive || r.Status == RecurPaused) {
+			return ErrConflict
+		}
+	}
+	g.BaseCurrency = currency
+	m.groups[id] = g
+	return nil
+}
+
+func (m *Memory) DeleteGroup(ctx context.Context, id int) error {
+	m.lock()
+	defer m.unlock()
+	for eid, e := range m.expenses {
+		if e.GroupID == id {
+			delete(m.expenses, eid)
+			delete(m.splits, eid)
+			delete(m.history, eid)
+			m.deleteReceipts(eid)
+		}
+	}
+	for rid, r := range m.recurring {
+		if r.GroupID == id {
+			delete(m.recurring, rid)
+		}
+	}
+	for cid, c := range m.categories {
+		if c.GroupID == id {
+			delete(m.categories, cid)
+		}
+	}
+	for bid, b := range m.budgets {
+		if b.GroupID == id {
+			delete(m.budgets, bid)
+		}
+	}
+	for aid, a := range m.budgetAlerts {
+		if a.GroupID == id {
+			delete(m.budgetAlerts, aid)
+		}
+	}
+	for sid, st := range m.settlements {
+		if st.GroupID == id {
+			delete(m.settlements, sid)
+		}
+	}
+	for did, d := range m.dates {
+		if d.GroupID == id {
+			delete(m.dates, did)
+			delete(m.votes, did)
+		}
+	}
+	for tid, t := range m.tasks {
+		if t.GroupID == id {
+			m.deleteTask(tid)
+		}
+	}
+	for evid, e := range m.events {
+		if e.GroupID == id {
+			delete(m.events, evid)
+		}
+	}
+	for nid, n := range m.notifications {
+		if n.GroupID == id {
+			delete(m.notifications, nid)
+			delete(m.readAt, nid)
+		}
+	}
+	delete(m.members, id)
+	delete(m.groups, id)
+	return nil
+}
+
+func (m *Memory) IsMember(ctx context.Context, groupID, userID int) (bool, error) {
+	m.lock()
+	defer m.unlock()
+	return m.members[groupID][userID], nil
+}
+
+func (m *Memory) AddMember(ctx context.Context, groupID, userID int) error {
+	m.lock()
+	defer m.unlock()
+	if m.members[groupID] == nil {
+		m.members[groupID] = map[int]bool{}
+	}
+	if m.members[groupID][userID] {
+		return ErrConflict
+	}
+	m.members[groupID][userID] = true
+	return nil
+}
+
+func (m *Memory) RemoveMember(ctx context.Context, groupID, userID int) error {
+	m.lock()
+	defer m.unlock()
+	if !m.members[groupID][userID] {
+		return ErrNotFound
+	}
+	delete(m.members[groupID], userID)
+	return nil
+}
+
+func (m *Memory) ListMembers(ctx context.Context, groupID int) ([]User, error) {
+	m.lock()
+	defer m.unlock()
+	var members []User
+	for _, id := range sortedKeys(m.members[groupID]) {
+		u := m.users[id]
+		members = append(members, User{ID: u.ID, Username: u.Username})
+	}
+	return members, nil
+}
+
+// Proposed dates
+
+func (m *Memory) ProposeDate(ctx context.Context, d ProposedDate) (int, error) {
+	m.lock()
+	defer m.unlock()
+	d.ID = m.newID("event_dates")
+	m.dates[d.ID] = d
+	return d.ID, nil
+}
+
+func (m *Memory) GetDate(ctx context.Context, id int) (ProposedDate, error) {
+	m.lock()
+	defer m.unlock()
+	d, ok := m.dates[id]
+	if !ok {
+		return ProposedDate{}, ErrNotFound
+	}
+	return d, nil
+}
+
+func (m *Memory) ListDates(ctx context.Context, groupID int) ([]DateSummary, error) {
+	m.lock()
+	defer m.unlock()
+	var dates []DateSummary
+	for _, id := range sortedKeys(m.dates) {
+		d := m.dates[id]
+		if d.GroupID != groupID {
+			continue
+		}
+		sum := DateSummary{
+			ID: d.ID, Date: d.Date, EndDate: d.EndDate, Time: d.Time,
+			ProposedBy: d.ProposedBy, ProposedByUsername: m.users[d.ProposedBy].Username,
+			Closed: d.Closed, EventID: d.EventID, Deadline: d.Deadline, Quorum: d.Quorum, Tally: d.Tally,
+		}
+		for _, b := range m.votes[id] {
+			switch b.Response {
+			case VoteYes:
+				sum.AvailableVotes++
+			case VoteMaybe:
+				sum.MaybeVotes++
+			case VoteNo:
+				sum.NotAvailableVotes++
+			}
+			if b.Preferred {
+				sum.PreferredVotes++
+			}
+		}
+		dates = append(dates, sum)
+	}
+	sort.SliceStable(dates, func(i, j int) bool {
+		if dates[i].Date != dates[j].Date {
+			return dates[i].Date < dates[j].Date
+		}
+		return dates[i].Time < dates[j].Time
+	})
+	return dates, nil
+}
+
+func (m *Memory) Vote(ctx context.Context, eventDateID, userID int, b Ballot) error {
+	m.lock()
+	defer m.unlock()
+	if m.votes[eventDateID] == nil {
+		m.votes[eventDateID] = map[int]Ballot{}
+	}
+	m.votes[eventDateID][userID] = b
+	return nil
+}
+
+func (m *Memory) ListVotes(ctx context.Context, groupID int) ([]DateVote, error) {
+	m.lock()
+	defer m.unlock()
+	var votes []DateVote
+	for _, dateID := range sortedKeys(m.votes) {
+		if m.dates[dateID].GroupID != groupID {
+			continue
+		}
+		for _, userID := range sortedKeys(m.votes[dateID]) {
+			votes = append(votes, DateVote{EventDateID: dateID, UserID: userID, Ballot: m.votes[dateID][userID]})
+		}
+	}
+	return votes, nil
+}
+
+func (m *Memory) DeleteDate(ctx context.Context, id int) error {
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.dates[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.votes, id)
+	delete(m.dates, id)
+	return nil
+}
+
+func (m *Memory) SetDeadline(ctx context.Context, id int, deadline *time.Time, quorum int) error {
+	m.lock()
+	defer m.unlock()
+	d, ok := m.dates[id]
+	if !ok {
+		return nil
+	}
+	d.Deadline, d.Quorum = deadline, quorum
+	m.dates[id] = d
+	return nil
+}
+
+func (m *Memory) ExpiredDates(ctx context.Context, now time.Time) ([]ProposedDate, error) {
+	m.lock()
+	defer m.unlock()
+	var dates []ProposedDate
+	for _, id := range sortedKeys(m.dates) {
+		d := m.dates[id]
+		if !d.Closed && d.Deadline != nil && !d.Deadline.After(now) {
+			dates = append(dates, d)
+		}
+	}
+	// Grouped like the MySQL query
+	sort.SliceStable(dates, func(i, j int) bool { return dates[i].GroupID < dates[j].GroupID })
+	return dates, nil
+}
+
+// closeDate ends voting on a date and records its tally; the lock must be held
+func (m *Memory) closeDate(id int) {
+	d := m.dates[id]
+	tally := Tally{}
+	for _, b := range m.votes[id] {
+		switch b.Response {
+		case VoteYes:
+			tally.Available++
+		case VoteMaybe:
+			tally.Maybe++
+		case VoteNo:
+			tally.NotAvailable++
+		}
+	}
+	d.Closed, d.Tally = true, &tally
+	m.dates[id] = d
+}
+
+func (m *Memory) CloseDate(ctx context.Context, id int) error {
+	m.lock()
+	defer m.unlock()
+	d, ok := m.dates[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if d.Closed {
+		return ErrConflict
+	}
+	m.closeDate(id)
+	return nil
+}
+
+func (m *Memory) FinalizeDate(ctx context.Context, eventDateID int, e Event) (int, error) {
+	m.lock()
+	defer m.unlock()
+	winner, ok := m.dates[eventDateID]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if winner.Closed {
+		return 0, ErrConflict
+	}
+	e.ID = m.newID("events")
+	m.events[e.ID] = e
+	for id, d := range m.dates {
+		if d.GroupID == e.GroupID && !d.Closed {
+			m.closeDate(id)
+		}
+	}
+	winner = m.dates[eventDateID]
+	winner.EventID = e.ID
+	m.dates[eventDateID] = winner
+	return e.ID, nil
+}
+
+// Events
+
+func (m *Memory) CreateEvent(ctx context.Context, e Event) (int, error) {
+	m.lock()
+	defer m.unlock()
+	e.ID = m.newID("events")
+	m.events[e.ID] = e
+	return e.ID, nil
+}
+
+func (m *Memory) GetEvent(ctx context.Context, id int) (Event, error) {
+	m.lock()
+	defer m.unlock()
+	e, ok := m.events[id]
+	if !ok {
+		return Event{}, ErrNotFound
+	}
+	return e, nil
+}
+
+func (m *Memory) ListEvents(ctx context.Context, f EventFilter) ([]Event, error) {
+	m.lock()
+	defer m.unlock()
+	var events []Event
+	for _, id := range sortedKeys(m.events) {
+		e := m.events[id]
+		if e.GroupID != f.GroupID || (f.From != "" && e.Date < f.From) || (f.To != "" && e.Date > f.To) {
+			continue
+		}
+		events = append(events, e)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Date < events[j].Date })
+	return events, nil
+}
+
+func (m *Memory) UpdateEvent(ctx context.Context, e Event) error {
+	m.lock()
+	defer m.unlock()
+	existing, ok := m.events[e.ID]
+	if !ok {
+		return nil
+	}
+	existing.Title, existing.Description, existing.Date = e.Title, e.Description, e.Date
+	existing.EndDate, existing.Time = e.EndDate, e.Time
+	m.events[e.ID] = existing
+	return nil
+}
+
+func (m *Memory) DeleteEvent(ctx context.Context, id int) error {
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.events[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.events, id)
+	return nil
+}
+
+// Tasks
+
+func (m *Memory) CreateTask(ctx context.Context, t Task) (int, error) {
+	m.lock()
+	defer m.unlock()
+	t.ID = m.newID("tasks")
+	if t.AssigneeID != 0 {
+		m.assigned[t.ID] = []int{t.AssigneeID}
+	}
+	t.AssigneeID, t.Assignees, t.Checklist = 0, nil, nil
+	m.tasks[t.ID] = t
+	return t.ID, nil
+}
+
+// filledTask returns the task with its assignees and checklist
+func (m *Memory) filledTask(t Task) Task {
+	t.Assignees = append([]int{}, m.assigned[t.ID]...)
+	if len(t.Assignees) > 0 {
+		t.AssigneeID = t.Assignees[0]
+	}
+	t.Checklist = []ChecklistItem{}
+	for _, id := range sortedKeys(m.checks) {
+		if item := m.checks[id]; item.TaskID == t.ID {
+			t.Checklist = append(t.Checklist, item)
+		}
+	}
+	return t
+}
+
+func (m *Memory) GetTask(ctx context.Context, id int) (Task, error) {
+	m.lock()
+	defer m.unlock()
+	t, ok := m.tasks[id]
+	if !ok {
+		return Task{}, ErrNotFound
+	}
+	return m.filledTask(t), nil
+}
+
+func (m *Memory) ListTasks(ctx context.Context, f TaskFilter) ([]Task, error) {
+	m.lock()
+	defer m.unlock()
+	var tasks []Task
+	for _, id := range sortedKeys(m.tasks) {
+		t := m.tasks[id]
+		if t.GroupID == f.GroupID && (f.Status == "" || t.Status == f.Status) && (f.AssigneeID == 0 || m.isAssigned(id, f.AssigneeID)) {
+			tasks = append(tasks, m.filledTask(t))
+		}
+	}
+	return tasks, nil
+}
+
+func (m *Memory) isAssigned(taskID, userID int) bool {
+	for _, id := range m.assigned[taskID] {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) AssignTask(ctx context.Context, id, assigneeID int) error {
+	m.lock()
+	defer m.unlock()
+	m.assignTask(id, assigneeID)
+	return nil
+}
+
+func (m *Memory) assignTask(id, assigneeID int) {
+	if _, ok := m.tasks[id]; !ok {
+		return
+	}
+	if assigneeID == 0 {
+		delete(m.assigned, id)
+		return
+	}
+	m.assigned[id] = []int{assigneeID}
+}
+
+func (m *Memory) AddTaskAssignee(ctx context.Context, taskID, userID int) error {
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.tasks[taskID]; !ok {
+		return ErrNotFound
+	}
+	if m.isAssigned(taskID, userID) {
+		return ErrConflict
+	}
+	m.assigned[taskID] = append(append([]int{}, m.assigned[taskID]...), userID)
+	return nil
+}
+
+func (m *Memory) RemoveTaskAssignee(ctx context.Context, taskID, userID int) error {
+	m.lock()
+	defer m.unlock()
+	if !m.isAssigned(taskID, userID) {
+		return ErrNotFound
+	}
+	var rest []int
+	for _, id := range m.assigned[taskID] {
+		if id != userID {
+			rest = append(rest, id)
+		}
+	}
+	if len(rest) == 0 {
+		delete(m.assigned, taskID)
+	} else {
+		m.assigned[taskID] = rest
+	}
+	return nil
+}
+
+func (m *Memory) UpdateTask(ctx context.Context, id int, u TaskUpdate) error {
+	m.lock()
+	defer m.unlock()
+	t, ok := m.tasks[id]
+	if !ok {
+		return nil
+	}
+	if u.Title != "" {
+		t.Title = u.Title
+	}
+	if u.Description != "" {
+		t.Description = u.Description
+	}
+	if u.DueDate != "" {
+		t.DueDate = u.DueDate
+	}
+	m.tasks[id] = t
+	if u.AssigneeID != 0 {
+		m.assignTask(id, u.AssigneeID)
+	}
+	return nil
+}
+
+func (m *Memory) SetTaskStatus(ctx context.Context, id int, from, to string, changedBy int) error {
+	m.lock()
+	defer m.unlock()
+	t, ok := m.tasks[id]
+	if !ok || t.Status != from {
+		return ErrConflict
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	t.Status, t.StatusChangedBy, t.StatusChangedAt = to, changedBy, &now
+	m.tasks[id] = t
+	change := TaskStatusChange{ID: m.newID("task_status_history"), TaskID: id, From: from, To: to, ChangedBy: changedBy, ChangedAt: now}
+	m.taskLog[id] = append(m.taskLog[id], change)
+	return nil
+}
+
+func (m *Memory) ListTaskStatusHistory(ctx context.Context, taskID int) ([]TaskStatusChange, error) {
+	m.lock()
+	defer m.unlock()
+	changes := m.taskLog[taskID]
+	history := make([]TaskStatusChange, 0, len(changes))
+	for i := len(changes) - 1; i >= 0; i-- {
+		history = append(history, changes[i])
+	}
+	return history, nil
+}
+
+func (m *Memory) DeleteTask(ctx context.Context, id int) error {
+	m.lock()
+	defer m.unlock()
+	m.deleteTask(id)
+	return nil
+}
+
+// deleteTask removes a task and everything attached to it
+func (m *Memory) deleteTask(id int) {
+	delete(m.tasks, id)
+	delete(m.taskLog, id)
+	delete(m.assigned, id)
+	for cid, item := range m.checks {
+		if item.TaskID == id {
+			delete(m.checks, cid)
+		}
+	}
+}
+
+func (m *Memory) AddChecklistItem(ctx context.Context, taskID int, title string) (int, error) {
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.tasks[taskID]; !ok {
+		return 0, ErrNotFound
+	}
+	item := ChecklistItem{ID: m.newID("task_checklist_items"), TaskID: taskID, Title: title}
+	m.checks[item.ID] = item
+	return item.ID, nil
+}
+
+func (m *Memory) GetChecklistItem(ctx context.Context, id int) (ChecklistItem, error) {
+	m.lock()
+	defer m.unlock()
+	item, ok := m.checks[id]
+	if !ok {
+		return ChecklistItem{}, ErrNotFound
+	}
+	return item, nil
+}
+
+func (m *Memory) RenameChecklistItem(ctx context.Context, id int, title string) error {
+	m.lock()
+	defer m.unlock()
+	item, ok := m.checks[id]
+	if !ok {
+		return ErrNotFound
+	}
+	item.Title = title
+	m.checks[id] = item
+	return nil
+}
+
+func (m *Memory) SetChecklistItemDone(ctx context.Context, id int, done bool, doneBy int) error {
+	m.lock()
+	defer m.unlock()
+	item, ok := m.checks[id]
+	if !ok {
+		return ErrNotFound
+	}
+	item.Done, item.DoneBy, item.DoneAt = done, 0, nil
+	if done {
+		now := time.Now().UTC().Truncate(time.Second)
+		item.DoneBy, item.DoneAt = doneBy, &now
+	}
+	m.checks[id] = item
+	return nil
+}
+
+func (m *Memory) DeleteChecklistItem(ctx context.Context, id int) error {
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.checks[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.checks, id)
+	return nil
+}
+
+// Expenses
+
+func (m *Memory) CreateExpense(ctx context.Context, e Expense, splits []Split) (int, error) {
+	m.lock()
+	defer m.unlock()
+	e.ID = m.newID("expenses")
+	m.expenses[e.ID] = e
+	m.splits[e.ID] = append([]Split(nil), splits...)
+	return e.ID, nil
+}
+
+func (m *Memory) GetExpense(ctx context.Context, id int) (Expense, error) {
+	m.lock()
+	defer m.unlock()
+	e, ok := m.expenses[id]
+	if !ok {
+		return Expense{}, ErrNotFound
+	}
+	return e, nil
+}
+
+func (m *Memory) ListExpenses(ctx context.Context, groupID int) ([]Expense, error) {
+	m.lock()
+	defer m.unlock()
+	var expenses []Expense
+	for _, e := range m.expenses {
+		if e.GroupID == groupID {
+			expenses = append(expenses, e)
+		}
+	}
+	// Newest first, like the MySQL query
+	sort.Slice(expenses, func(i, j int) bool {
+		if expenses[i].Date != expenses[j].Date {
+			return expenses[i].Date > expenses[j].Date
+		}
+		return expenses[i].ID > expenses[j].ID
+	})
+	return expenses, nil
+}
+
+func (m *Memory) ListSplits(ctx context.Context, expenseID int) ([]Split, error) {
+	m.lock()
+	defer m.unlock()
+	return append([]Split(nil), m.splits[expenseID]...), nil
+}
+
+func (m *Memory) GroupSplits(ctx context.Context, groupID int) (map[int][]Split, error) {
+	m.lock()
+	defer m.unlock()
+	splits := map[int][]Split{}
+	for id, e := range m.expenses {
+		if e.GroupID == groupID && len(m.splits[id]) > 0 {
+			splits[id] = append([]Split(nil), m.splits[id]...)
+		}
+	}
+	return splits, nil
+}
+
+func (m *Memory) GroupBalances(ctx context.Context, groupID int) (map[int]money.Amount, error) {
+	m.lock()
+	defer m.unlock()
+	balances := map[int]money.Amount{}
+	for id, e := range m.expenses {
+		if e.GroupID != groupID {
+			continue
+		}
+		balances[e.PaidBy] += e.BaseAmount
+		for _, s := range m.splits[id] {
+			balances[s.UserID] -= s.BaseAmount
+		}
+	}
+	for _, st := range m.settlements {
+		if st.GroupID == groupID {
+			balances[st.FromUserID] += st.BaseAmount
+			balances[st.ToUserID] -= st.BaseAmount
+		}
+	}
+	return balances, nil
+}
+
+func (m *Memory) UpdateExpense(ctx context.Context, e Expense, splits []Split, rev ExpenseRevision) error {
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.expenses[e.ID]; !ok {
+		return ErrNotFound
+	}
+	m.expenses[e.ID] = e
+	m.splits[e.ID] = append([]Split(nil), splits...)
+	rev.ID = m.newID("expense_history")
+	rev.ExpenseID = e.ID
+	rev.ChangedAt = time.Now().UTC().Truncate(time.Second)
+	rev.Changes = append([]ExpenseChange(nil), rev.Changes...)
+	m.history[e.ID] = append(m.history[e.ID], rev)
+	return nil
+}
+
+func (m *Memory) ListExpenseHistory(ctx context.Context, expenseID int) ([]ExpenseRevision, error) {
+	m.lock()
+	defer m.unlock()
+	revs := m.history[expenseID]
+	history := make([]ExpenseRevision, 0, len(revs))
+	for i := len(revs) - 1; i >= 0; i-- {
+		history = append(history, revs[i])
+	}
+	return history, nil
+}
+
+func (m *Memory) Report(ctx context.Context, f ReportFilter) (ExpenseReport, error) {
+	m.lock()
+	defer m.unlock()
+	report := ExpenseReport{ByCategory: []CategoryTotal{}, ByMember: []MemberTotal{}, ByMonth: []MonthTotal{}}
+	categories := map[string]*CategoryTotal{}
+	members := map[int]*MemberTotal{}
+	months := map[string]*MonthTotal{}
+	member := func(userID int) *MemberTotal {
+		if members[userID] == nil {
+			members[userID] = &MemberTotal{UserID: userID, Username: m.users[userID].Username}
+		}
+		return members[userID]
+	}
+	for id, e := range m.expenses {
+		if e.GroupID != f.GroupID || (f.From != "" && e.Date < f.From) || (f.To != "" && e.Date > f.To) {
+			continue
+		}
+		report.Total += e.BaseAmount
+		report.Count++
+		if categories[e.Category] == nil {
+			categories[e.Category] = &CategoryTotal{Category: e.Category}
+		}
+		categories[e.Category].Total += e.BaseAmount
+		categories[e.Category].Count++
+		month := e.Date
+		if len(month) > 7 {
+			month = month[:7]
+		}
+		if months[month] == nil {
+			months[month] = &MonthTotal{Month: month}
+		}
+		months[month].Total += e.BaseAmount
+		months[month].Count++
+		member(e.PaidBy).Paid += e.BaseAmount
+		for _, s := range m.splits[id] {
+			member(s.UserID).Share += s.BaseAmount
+		}
+	}
+	// Same orderings as the MySQL queries
+	for _, c := range categories {
+		report.ByCategory = append(report.ByCategory, *c)
+	}
+	sort.Slice(report.ByCategory, func(i, j int) bool {
+		a, b := report.ByCategory[i], report.ByCategory[j]
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Category < b.Category
+	})
+	for _, mt := range members {
+		report.ByMember = append(report.ByMember, *mt)
+	}
+	sort.Slice(report.ByMember, func(i, j int) bool { return report.ByMember[i].UserID < report.ByMember[j].UserID })
+	for _, mt := range months {
+		report.ByMonth = append(report.ByMonth, *mt)
+	}
+	sort.Slice(report.ByMonth, func(i, j int) bool { return report.ByMonth[i].Month < report.ByMonth[j].Month })
+	return report, nil
+}
+
+func (m *Memory) DeleteExpense(ctx context.Context, id int) error {
+	m.lock()
+	defer m.unlock()
+	delete(m.splits, id)
+	delete(m.history, id)
+	m.deleteReceipts(id)
+	delete(m.expenses, id)
+	return nil
+}
+
+// deleteReceipts drops an expense's receipts; the caller holds the lock
+func (m *Memory) deleteReceipts(expenseID int) {
+	for id, rc := range m.receipts {
+		if rc.ExpenseID == expenseID {
+			delete(m.receipts, id)
+		}
+	}
+}
+
+// Receipts
+
+func (m *Memory) AddReceipt(ctx context.Context, rc Receipt) (int, error) {
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.expenses[rc.ExpenseID]; !ok {
+		return 0, ErrNotFound
+	}
+	rc.ID = m.newID("expense_receipts")
+	rc.UploadedAt = time.Now().UTC().Truncate(time.Second)
+	m.receipts[rc.ID] = rc
+	return rc.ID, nil
+}
+
+func (m *Memory) GetReceipt(ctx context.Context, id int) (Receipt, error) {
+	m.lock()
+	defer m.unlock()
+	rc, ok := m.receipts[id]
+	if !ok {
+		return Receipt{}, ErrNotFound
+	}
+	return rc, nil
+}
+
+func (m *Memory) ListReceipts(ctx context.Context, expenseID int) ([]Receipt, error) {
+	m.lock()
+	defer m.unlock()
+	var receipts []Receipt
+	for _, rc := range m.receipts {
+		if rc.ExpenseID == expenseID {
+			receipts = append(receipts, rc)
+		}
+	}
+	sort.Slice(receipts, func(i, j int) bool { return receipts[i].ID < receipts[j].ID })
+	return receipts, nil
+}
+
+func (m *Memory) ListGroupReceipts(ctx context.Context, groupID int) ([]Receipt, error) {
+	m.lock()
+	defer m.unlock()
+	var receipts []Receipt
+	for _, rc := range m.receipts {
+		if m.expenses[rc.ExpenseID].GroupID == groupID {
+			receipts = append(receipts, rc)
+		}
+	}
+	sort.Slice(receipts, func(i, j int) bool { return receipts[i].ID < receipts[j].ID })
+	return receipts, nil
+}
+
+func (m *Memory) DeleteReceipt(ctx context.Context, id int) error {
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.receipts[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.receipts, id)
+	return nil
+}
+
+// Categories
+
+func (m *Memory) ListCategories(ctx context.Context, groupID int) ([]Category, error) {
+	m.lock()
+	defer m.unlock()
+	var categories []Category
+	for _, c := range m.categories {
+		if c.GroupID == groupID {
+			categories = append(categories, c)
+		}
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	return categories, nil
+}
+
+func (m *Memory) GetCategory(ctx context.Context, id int) (Category, error) {
+	m.lock()
+	defer m.unlock()
+	c, ok := m.categories[id]
+	if !ok {
+		return Category{}, ErrNotFound
+	}
+	return c, nil
+}
+
+func (m *Memory) AddCategory(ctx context.Context, groupID int, name string) (int, error) {
+	m.lock()
+	defer m.unlock()
+	for _, c := range m.categories {
+		if c.GroupID == groupID && c.Name == name {
+			return 0, ErrConflict
+		}
+	}
+	id := m.newID("expense_categories")
+	m.categories[id] = Category{ID: id, GroupID: groupID, Name: name}
+	return id, nil
+}
+
+func (m *Memory) DeleteCategory(ctx context.Context, id int) error {
+	m.lock()
+	defer m.unlock()
+	c, ok := m.categories[id]
+	if !ok {
+		return ErrNotFound
+	}
+	for _, e := range m.expenses {
+		if e.GroupID == c.GroupID && e.Category == c.Name {
+			return ErrConflict
+		}
+	}
+	for _, r := range m.recurring {
+		if r.GroupID == c.GroupID && r.Category == c.Name {
+			return ErrConflict
+		}
+	}
+	for _, b := range m.budgets {
+		if b.GroupID == c.GroupID && b.Category == c.Name {
+			return ErrConflict
+		}
+	}
+	delete(m.categories, id)
+	return nil
+}
+
+// Recurring expenses
+
+func (m *Memory) CreateRecurring(ctx context.Context, r RecurringExpense) (int, error) {
+	m.lock()
+	defer m.unlock()
+	r.ID = m.newID("recurring_expenses")
+	r.Splits = append([]Split(nil), r.Splits...)
+	m.recurring[r.ID] = r
+	return r.ID, nil
+}
+
+func (m *Memory) GetRecurring(ctx context.Context, id int) (RecurringExpense, error) {
+	m.lock()
+	defer m.unlock()
+	r, ok := m.recurring[id]
+	if !ok {
+		return RecurringExpense{}, ErrNotFound
+	}
+	return r, nil
+}
+
+func (m *Memory) ListRecurring(ctx context.Context, groupID int) ([]RecurringExpense, error) {
+	m.lock()
+	defer m.unlock()
+	var templates []RecurringExpense
+	for _, id := range sortedKeys(m.recurring) {
+		if r := m.recurring[id]; r.GroupID == groupID {
+			templates = append(templates, r)
+		}
+	}
+	return templates, nil
+}
+
+func (m *Memory) UpdateRecurring(ctx context.Context, r RecurringExpense) error {
+	m.lock()
+	defer m.unlock()
+	old, ok := m.recurring[r.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	r.GroupID, r.CreatedBy = old.GroupID, old.CreatedBy
+	r.Splits = append([]Split(nil), r.Splits...)
+	m.recurring[r.ID] = r
+	return nil
+}
+
+func (m *Memory) DueRecurring(ctx context.Context, today string) ([]RecurringExpense, error) {
+	m.lock()
+	defer m.unlock()
+	var due []RecurringExpense
+	for _, id := range sortedKeys(m.recurring) {
+		if r := m.recurring[id]; r.Status == RecurActive && r.NextDate <= today {
+			due = append(due, r)
+		}
+	}
+	return due, nil
+}
+
+func (m *Memory) AdvanceRecurring(ctx context.Context, r RecurringExpense, fromDate string) error {
+	m.lock()
+	defer m.unlock()
+	stored, ok := m.recurring[r.ID]
+	if !ok || stored.Status != RecurActive || stored.NextDate != fromDate {
+		return ErrConflict
+	}
+	stored.NextDate, stored.Occurrence, stored.Status = r.NextDate, r.Occurrence, r.Status
+	m.recurring[r.ID] = stored
+	return nil
+}
+
+// Budgets
+
+func (m *Memory) SetBudget(ctx context.Context, b Budget) (int, error) {
+	m.lock()
+	defer m.unlock()
+	for id, existing := range m.budgets {
+		if existing.GroupID == b.GroupID && existing.Category == b.Category {
+			existing.Limit = b.Limit
+			m.budgets[id] = existing
+			return id, nil
+		}
+	}
+	b.ID = m.newID("budgets")
+	m.budgets[b.ID] = b
+	return b.ID, nil
+}
+
+func (m *Memory) GetBudget(ctx context.Context, id int) (Budget, error) {
+	m.lock()
+	defer m.unlock()
+	b, ok := m.budgets[id]
+	if !ok {
+		return Budget{}, ErrNotFound
+	}
+	return b, nil
+}
+
+func (m *Memory) ListBudgets(ctx context.Context, groupID int) ([]Budget, error) {
+	m.lock()
+	defer m.unlock()
+	var budgets []Budget
+	for _, b := range m.budgets {
+		if b.GroupID == groupID {
+			budgets = append(budgets, b)
+		}
+	}
+	sort.Slice(budgets, func(i, j int) bool { return budgets[i].Category < budgets[j].Category })
+	return budgets, nil
+}
+
+func (m *Memory) DeleteBudget(ctx context.Context, id int) error {
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.budgets[id]; !ok {
+		return ErrNotFound
+	}
+	for aid, a := range m.budgetAlerts {
+		if a.BudgetID == id {
+			delete(m.budgetAlerts, aid)
+		}
+	}
+	delete(m.budgets, id)
+	return nil
+}
+
+func (m *Memory) AddBudgetAlert(ctx context.Context, a BudgetAlert) (int, error) {
+	m.lock()
+	defer m.unlock()
+	a.ID = m.newID("budget_alerts")
+	a.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	m.budgetAlerts[a.ID] = a
+	return a.ID, nil
+}
+
+func (m *Memory) ListBudgetAlerts(ctx context.Context, groupID int) ([]BudgetAlert, error) {
+	m.lock()
+	defer m.unlock()
+	var alerts []BudgetAlert
+	for _, a := range m.budgetAlerts {
+		if a.GroupID == groupID {
+			if _, ok := m.expenses[a.ExpenseID]; !ok {
+				a.ExpenseID = 0 // like ON DELETE SET NULL
+			}
+			alerts = append(alerts, a)
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].ID > alerts[j].ID })
+	return alerts, nil
+}
+
+// Settlements
+
+func (m *Memory) CreateSettlement(ctx context.Context, st Settlement) (int, error) {
+	m.lock()
+	defer m.unlock()
+	st.ID = m.newID("settlements")
+	m.settlements[st.ID] = st
+	return st.ID, nil
+}
+
+func (m *Memory) GetSettlement(ctx context.Context, id int) (Settlement, error) {
+	m.lock()
+	defer m.unlock()
+	st, ok := m.settlements[id]
+	if !ok {
+		return Settlement{}, ErrNotFound
+	}
+	return st, nil
+}
+
+func (m *Memory) ListSettlements(ctx context.Context, groupID int) ([]Settlement, error) {
+	m.lock()
+	defer m.unlock()
+	var settlements []Settlement
+	for _, st := range m.settlements {
+		if st.GroupID == groupID {
+			settlements = append(settlements, st)
+		}
+	}
+	// Newest first, like the MySQL query
+	sort.Slice(settlements, func(i, j int) bool {
+		if settlements[i].Date != settlements[j].Date {
+			return settlements[i].Date > settlements[j].Date
+		}
+		return settlements[i].ID > settlements[j].ID
+	})
+	return settlements, nil
+}
+
+func (m *Memory) DeleteSettlement(ctx context.Context, id int) error {
+	m.lock()
+	defer m.unlock()
+	if _, ok := m.settlements[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.settlements, id)
+	return nil
+}
+
+// Notifications
+
+func (m *Memory) Notify(ctx context.Context, n Notification) error {
+	m.lock()
+	defer m.unlock()
+	n.ID = m.newID("notifications")
+	n.CreatedAt = time.Now().UTC().Truncate(time.Second)
+	n.Read = false
+	m.notifications[n.ID] = n
+	return nil
+}
+
+func (m *Memory) ListNotifications(ctx context.Context, userID int, unreadOnly bool) ([]Notification, error) {
+	m.lock()
+	defer m.unlock()
+	var notifications []Notification
+	keys := sortedKeys(m.notifications)
+	// Newest first, like the MySQL query
+	for i := len(keys) - 1; i >= 0; i-- {
+		n := m.notifications[keys[i]]
+		_, n.Read = m.readAt[n.ID]
+		if n.UserID != userID || (unreadOnly && n.Read) {
+			continue
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, nil
+}
+
+func (m *Memory) MarkRead(ctx context.Context, userID int, ids []int, now time.Time) error {
+	m.lock()
+	defer m.unlock()
+	if len(ids) == 0 {
+		ids = sortedKeys(m.notifications)
+	}
+	for _, id := range ids {
+		n, ok := m.notifications[id]
+		if !ok || n.UserID != userID {
+			continue
+		}
+		if _, read := m.readAt[id]; !read {
+			m.readAt[id] = now
+		}
+	}
+	return nil
+}
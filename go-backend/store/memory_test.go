@@ -0,0 +1,285 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go-backend/money"
+)
+
+// newTestGroup returns a store holding users alice (the admin) and bob and
+// a group both belong to
+func newTestGroup(t *testing.T) (*Store, int, int, int) {
+	t.Helper()
+	ctx := context.Background()
+	st := NewMemory()
+	alice, err := st.Users.CreateUser(ctx, "alice", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := st.Users.CreateUser(ctx, "bob", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	groupID, err := st.Groups.CreateGroup(ctx, Group{Name: "Trip", Code: "TRIP01", AdminID: alice, BaseCurrency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Groups.AddMember(ctx, groupID, bob); err != nil {
+		t.Fatal(err)
+	}
+	return st, groupID, alice, bob
+}
+
+func TestMemoryUsers(t *testing.T) {
+	ctx := context.Background()
+	st := NewMemory()
+	id, err := st.Users.CreateUser(ctx, "alice", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Users.CreateUser(ctx, "alice", "other"); err != ErrConflict {
+		t.Errorf("CreateUser with a taken username: err = %v, want ErrConflict", err)
+	}
+	u, err := st.Users.GetUserByUsername(ctx, "alice")
+	if err != nil || u.ID != id || u.Password != "hash" {
+		t.Errorf("GetUserByUsername = %+v, %v; want ID %d with its hash", u, err, id)
+	}
+	if err := st.Users.UpdatePassword(ctx, id, "new"); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := st.Users.GetUser(ctx, id); u.Password != "new" {
+		t.Errorf("password after UpdatePassword = %q, want %q", u.Password, "new")
+	}
+	if _, err := st.Users.GetUser(ctx, id+1); err != ErrNotFound {
+		t.Errorf("GetUser of a missing user: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMemoryMembers(t *testing.T) {
+	ctx := context.Background()
+	st, groupID, alice, bob := newTestGroup(t)
+	carol, err := st.Users.CreateUser(ctx, "carol", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Groups.CreateGroup(ctx, Group{Name: "Copy", Code: "TRIP01", AdminID: carol}); err != ErrConflict {
+		t.Errorf("CreateGroup with a taken code: err = %v, want ErrConflict", err)
+	}
+	if err := st.Groups.AddMember(ctx, groupID, bob); err != ErrConflict {
+		t.Errorf("AddMember twice: err = %v, want ErrConflict", err)
+	}
+	if err := st.Groups.RemoveMember(ctx, groupID, carol); err != ErrNotFound {
+		t.Errorf("RemoveMember of a non-member: err = %v, want ErrNotFound", err)
+	}
+	for _, tt := range []struct {
+		userID int
+		want   bool
+	}{{alice, true}, {bob, true}, {carol, false}} {
+		if got, err := st.Groups.IsMember(ctx, groupID, tt.userID); err != nil || got != tt.want {
+			t.Errorf("IsMember(%d) = %v, %v; want %v", tt.userID, got, err, tt.want)
+		}
+	}
+	members, err := st.Groups.ListMembers(ctx, groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []User{{ID: alice, Username: "alice"}, {ID: bob, Username: "bob"}}
+	if !reflect.DeepEqual(members, want) {
+		t.Errorf("ListMembers = %+v, want %+v", members, want)
+	}
+	if err := st.Groups.RemoveMember(ctx, groupID, bob); err != nil {
+		t.Fatal(err)
+	}
+	if groups, _ := st.Groups.ListGroupsForUser(ctx, bob); len(groups) != 0 {
+		t.Errorf("ListGroupsForUser after leaving = %+v, want none", groups)
+	}
+}
+
+func TestMemoryExpenses(t *testing.T) {
+	ctx := context.Background()
+	st, groupID, alice, bob := newTestGroup(t)
+	dinner := Expense{GroupID: groupID, Description: "Dinner", Amount: 30000, Currency: "USD", Rate: 1,
+		BaseAmount: 30000, PaidBy: alice, Date: "2026-10-01", SplitMode: SplitEqual}
+	dinnerID, err := st.Expenses.CreateExpense(ctx, dinner, []Split{
+		{UserID: alice, Amount: 15000, BaseAmount: 15000},
+		{UserID: bob, Amount: 15000, BaseAmount: 15000},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	taxi := Expense{GroupID: groupID, Description: "Taxi", Amount: 9000, Currency: "USD", Rate: 1,
+		BaseAmount: 9000, PaidBy: bob, Date: "2026-10-02", SplitMode: SplitEqual}
+	taxiID, err := st.Expenses.CreateExpense(ctx, taxi, []Split{{UserID: alice, Amount: 9000, BaseAmount: 9000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expenses, err := st.Expenses.ListExpenses(ctx, groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expenses) != 2 || expenses[0].ID != taxiID || expenses[1].ID != dinnerID {
+		t.Errorf("ListExpenses = %+v, want the taxi then the dinner", expenses)
+	}
+	balances, err := st.Expenses.GroupBalances(ctx, groupID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]money.Amount{alice: 6000, bob: -6000}
+	if !reflect.DeepEqual(balances, want) {
+		t.Errorf("GroupBalances = %v, want %v", balances, want)
+	}
+
+	if err := st.Expenses.DeleteExpense(ctx, dinnerID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Expenses.GetExpense(ctx, dinnerID); err != ErrNotFound {
+		t.Errorf("GetExpense after delete: err = %v, want ErrNotFound", err)
+	}
+	if splits, _ := st.Expenses.ListSplits(ctx, dinnerID); len(splits) != 0 {
+		t.Errorf("splits left after delete: %+v", splits)
+	}
+}
+
+func TestMemoryWithTx(t *testing.T) {
+	ctx := context.Background()
+	st, groupID, alice, _ := newTestGroup(t)
+	e := Expense{GroupID: groupID, Amount: 5000, Currency: "USD", Rate: 1, BaseAmount: 5000, PaidBy: alice, Date: "2026-10-01"}
+
+	failed := errors.New("split insert failed")
+	err := st.WithTx(ctx, func(tx *Store) error {
+		if _, err := tx.Expenses.CreateExpense(ctx, e, []Split{{UserID: alice, Amount: 5000, BaseAmount: 5000}}); err != nil {
+			return err
+		}
+		if _, err := tx.Users.CreateUser(ctx, "carol", "hash"); err != nil {
+			return err
+		}
+		// A nested WithTx joins the transaction instead of committing
+		return tx.WithTx(ctx, func(tx *Store) error {
+			if err := tx.Groups.RemoveMember(ctx, groupID, alice); err != nil {
+				return err
+			}
+			return failed
+		})
+	})
+	if err != failed {
+		t.Fatalf("WithTx = %v, want the error of fn", err)
+	}
+	if expenses, _ := st.Expenses.ListExpenses(ctx, groupID); len(expenses) != 0 {
+		t.Errorf("expenses after rollback = %+v, want none", expenses)
+	}
+	if _, err := st.Users.GetUserByUsername(ctx, "carol"); err != ErrNotFound {
+		t.Errorf("user created in the rolled back tx: err = %v, want ErrNotFound", err)
+	}
+	if ok, _ := st.Groups.IsMember(ctx, groupID, alice); !ok {
+		t.Error("member removed in the rolled back nested tx is gone")
+	}
+
+	var id int
+	err = st.WithTx(ctx, func(tx *Store) error {
+		var err error
+		id, err = tx.Expenses.CreateExpense(ctx, e, nil)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Expenses.GetExpense(ctx, id); err != nil {
+		t.Errorf("GetExpense after commit: %v", err)
+	}
+}
+
+func TestMemoryDeleteGroup(t *testing.T) {
+	ctx := context.Background()
+	st, groupID, alice, bob := newTestGroup(t)
+	expenseID, err := st.Expenses.CreateExpense(ctx, Expense{GroupID: groupID, Amount: 1000, Currency: "USD", Rate: 1,
+		BaseAmount: 1000, PaidBy: alice, Date: "2026-10-01"}, []Split{{UserID: bob, Amount: 1000, BaseAmount: 1000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dateID, err := st.Dates.ProposeDate(ctx, ProposedDate{GroupID: groupID, Date: "2026-11-01", ProposedBy: bob})
+	if err != nil {
+		t.Fatal(err)
+	}
+	taskID, err := st.Tasks.CreateTask(ctx, Task{GroupID: groupID, Title: "Book the cabin", AssigneeID: alice})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := st.Groups.DeleteGroup(ctx, groupID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Groups.GetGroup(ctx, groupID); err != ErrNotFound {
+		t.Errorf("GetGroup after delete: err = %v, want ErrNotFound", err)
+	}
+	if _, err := st.Expenses.GetExpense(ctx, expenseID); err != ErrNotFound {
+		t.Errorf("GetExpense after deleting its group: err = %v, want ErrNotFound", err)
+	}
+	if _, err := st.Dates.GetDate(ctx, dateID); err != ErrNotFound {
+		t.Errorf("GetDate after deleting its group: err = %v, want ErrNotFound", err)
+	}
+	if _, err := st.Tasks.GetTask(ctx, taskID); err != ErrNotFound {
+		t.Errorf("GetTask after deleting its group: err = %v, want ErrNotFound", err)
+	}
+	if ok, _ := st.Groups.IsMember(ctx, groupID, bob); ok {
+		t.Error("bob is still a member of the deleted group")
+	}
+}
+
+func TestMemorySetBaseCurrency(t *testing.T) {
+	recurring := func(status string) func(*Store, int, int) error {
+		return func(st *Store, groupID, userID int) error {
+			_, err := st.Recurring.CreateRecurring(context.Background(), RecurringExpense{GroupID: groupID, Amount: 1000,
+				Currency: "EUR", PaidBy: userID, Frequency: RecurMonthly, Interval: 1, Status: status})
+			return err
+		}
+	}
+	tests := []struct {
+		name    string
+		add     func(st *Store, groupID, userID int) error // nil for an empty group
+		wantErr error
+	}{
+		{name: "empty group"},
+		{name: "expense", wantErr: ErrConflict, add: func(st *Store, groupID, userID int) error {
+			_, err := st.Expenses.CreateExpense(context.Background(), Expense{GroupID: groupID, Amount: 1000,
+				Currency: "USD", Rate: 1, BaseAmount: 1000, PaidBy: userID, Date: "2026-10-01"}, nil)
+			return err
+		}},
+		{name: "settlement", wantErr: ErrConflict, add: func(st *Store, groupID, userID int) error {
+			_, err := st.Settlements.CreateSettlement(context.Background(), Settlement{GroupID: groupID, FromUserID: userID,
+				ToUserID: userID, Amount: 1000, Currency: "USD", Rate: 1, BaseAmount: 1000, Date: "2026-10-01"})
+			return err
+		}},
+		{name: "budget", wantErr: ErrConflict, add: func(st *Store, groupID, userID int) error {
+			_, err := st.Budgets.SetBudget(context.Background(), Budget{GroupID: groupID, Limit: 50000, CreatedBy: userID})
+			return err
+		}},
+		{name: "active recurring expense", wantErr: ErrConflict, add: recurring(RecurActive)},
+		{name: "paused recurring expense", wantErr: ErrConflict, add: recurring(RecurPaused)},
+		{name: "cancelled recurring expense", add: recurring(RecurCancelled)},
+		{name: "ended recurring expense", add: recurring(RecurEnded)},
+	}
+	for _, tt := range tests {
+		ctx := context.Background()
+		st, groupID, alice, _ := newTestGroup(t)
+		if tt.add != nil {
+			if err := tt.add(st, groupID, alice); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := st.Groups.SetBaseCurrency(ctx, groupID, "EUR"); err != tt.wantErr {
+			t.Errorf("%s: SetBaseCurrency = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		want := "EUR"
+		if tt.wantErr != nil {
+			want = "USD"
+		}
+		if g, _ := st.Groups.GetGroup(ctx, groupID); g.BaseCurrency != want {
+			t.Errorf("%s: base currency = %s, want %s", tt.name, g.BaseCurrency, want)
+		}
+	}
+}
@@ -0,0 +1,1625 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"go-backend/money"
+)
+
+// MySQL implements every store interface on top of a MySQL database
+type MySQL struct {
+	db dbtx
+}
+
+// dbtx runs statements: the connection pool, or a transaction in progress
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// NewMySQL returns a Store backed by db
+func NewMySQL(db *sql.DB) *Store {
+	return (&MySQL{db: db}).store()
+}
+
+func (m *MySQL) store() *Store {
+	return &Store{
+		Users: m, Sessions: m, Groups: m, Dates: m, Events: m, Tasks: m, Expenses: m,
+		Receipts: m, Categories: m, Recurring: m, Budgets: m, Settlements: m, Notifications: m,
+		withTx: func(ctx context.Context, fn func(tx *Store) error) error {
+			return m.inTx(ctx, func(tx *MySQL) error { return fn(tx.store()) })
+		},
+	}
+}
+
+// inTx runs fn in a transaction, committing if it returns nil and rolling
+// back otherwise. Inside a transaction already, fn joins it.
+func (m *MySQL) inTx(ctx context.Context, fn func(tx *MySQL) error) error {
+	db, ok := m.db.(*sql.DB)
+	if !ok {
+		return fn(m)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(&MySQL{db: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// notFound maps sql.ErrNoRows to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// isDuplicate reports whether err is a unique key violation
+func isDuplicate(err error) bool {
+	var me *mysql.MySQLError
+	return errors.As(err, &me) && me.Number == 1062
+}
+
+// requireRow returns ErrNotFound when an UPDATE/DELETE matched nothing
+func requireRow(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// nullIfEmpty stores an empty string as NULL (for optional DATE/TIME columns)
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// nullIfZero stores a zero ID as NULL (for optional foreign keys)
+func nullIfZero(id int) interface{} {
+	if id == 0 {
+		return nil
+	}
+	return id
+}
+
+// nullIfZeroFloat stores an unset optional number as NULL
+func nullIfZeroFloat(f float64) interface{} {
+	if f == 0 {
+		return nil
+	}
+	return f
+}
+
+// Users
+
+func (m *MySQL) CreateUser(ctx context.Context, username, password string) (int, error) {
+	result, err := m.db.ExecContext(ctx, "INSERT INTO users (username, password) VALUES (?, ?)", username, password)
+	if isDuplicate(err) {
+		return 0, ErrConflict
+	}
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func (m *MySQL) GetUser(ctx context.Context, id int) (User, error) {
+	var u User
+	err := m.db.QueryRowContext(ctx, "SELECT id, username, password FROM users WHERE id = ?", id).Scan(&u.ID, &u.Username, &u.Password)
+	return u, notFound(err)
+}
+
+func (m *MySQL) GetUserByUsername(ctx context.Context, username string) (User, error) {
+	var u User
+	err := m.db.QueryRowContext(ctx, "SELECT id, username, password FROM users WHERE username = ?", username).Scan(&u.ID, &u.Username, &u.Password)
+	return u, notFound(err)
+}
+
+func (m *MySQL) UpdatePassword(ctx context.Context, id int, password string) error {
+	_, err := m.db.ExecContext(ctx, "UPDATE users SET password = ? WHERE id = ?", password, id)
+	return err
+}
+
+// Sessions
+
+func (m *MySQL) CreateSession(ctx context.Context, tokenHash string, userID int, expiresAt time.Time) error {
+	_, err := m.db.ExecContext(ctx, "INSERT INTO sessions (token_hash, user_id, expires_at) VALUES (?, ?, ?)", tokenHash, userID, expiresAt)
+	return err
+}
+
+func (m *MySQL) SessionUser(ctx context.Context, tokenHash string, now time.Time) (User, error) {
+	var u User
+	err := m.db.QueryRowContext(ctx,
+		"SELECT u.id, u.username FROM sessions s JOIN users u ON s.user_id = u.id WHERE s.token_hash = ? AND s.expires_at > ?",
+		tokenHash, now,
+	).Scan(&u.ID, &u.Username)
+	return u, notFound(err)
+}
+
+func (m *MySQL) DeleteSession(ctx context.Context, tokenHash string) error {
+	_, err := m.db.ExecContext(ctx, "DELETE FROM sessions WHERE token_hash = ?", tokenHash)
+	return err
+}
+
+// Groups
+
+func (m *MySQL) CreateGroup(ctx context.Context, g Group) (int, error) {
+	var groupID int64
+	err := m.inTx(ctx, func(tx *MySQL) error {
+		result, err := tx.db.ExecContext(ctx,
+			"INSERT INTO `groups` (name, code, admin_id, base_currency) VALUES (?, ?, ?, ?)",
+			g.Name, g.Code, g.AdminID, g.BaseCurrency,
+		)
+		if isDuplicate(err) {
+			return ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+		if groupID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		// Add creator to group_members
+		_, err = tx.db.ExecContext(ctx, "INSERT INTO group_members (group_id, user_id) VALUES (?, ?)", groupID, g.AdminID)
+		return err
+	})
+	return int(groupID), err
+}
+
+func (m *MySQL) GetGroup(ctx context.Context, id int) (Group, error) {
+	var g Group
+	err := m.db.QueryRowContext(ctx, "SELECT id, name, code, admin_id, base_currency FROM `groups` WHERE id = ?", id).
+		Scan(&g.ID, &g.Name, &g.Code, &g.AdminID, &g.BaseCurrency)
+	return g, notFound(err)
+}
+
+func (m *MySQL) GetGroupByCode(ctx context.Context, code string) (Group, error) {
+	var g Group
+	err := m.db.QueryRowContext(ctx, "SELECT id, name, code, admin_id, base_currency FROM `groups` WHERE code = ?", code).
+		Scan(&g.ID, &g.Name, &g.Code, &g.AdminID, &g.BaseCurrency)
+	return g, notFound(err)
+}
+
+func (m *MySQL) ListGroupsForUser(ctx context.Context, userID int) ([]Group, error) {
+	rows, err := m.db.QueryContext(ctx,
+		"SELECT g.id, g.name, g.code, g.admin_id, g.base_currency FROM group_members gm JOIN `groups` g ON gm.group_id = g.id WHERE gm.user_id = ?",
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var groups []Group
+	for rows.Next() {
+		var g Group
+		if err := rows.Scan(&g.ID, &g.Name, &g.Code, &g.AdminID, &g.BaseCurrency); err != nil {
+			return nil, err
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+func (m *MySQL) UpdateCode(ctx context.Context, id int, code string) error {
+	_, err := m.db.ExecContext(ctx, "UPDATE `groups` SET code = ? WHERE id = ?", code, id)
+	if isDuplicate(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (m *MySQL) SetBaseCurrency(ctx context.Context, id int, currency string) error {
+	return m.inTx(ctx, func(tx *MySQL) error {
+		var inUse bool
+		err := tx.db.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM expenses e WHERE e.group_id = g.id)
+				OR EXISTS (SELECT 1 FROM settlements s WHERE s.group_id = g.id)
+				OR EXISTS (SELECT 1 FROM budgets b WHERE b.group_id = g.id)
+				OR EXISTS (SELECT 1 FROM recurring_expenses r WHERE r.group_id = g.id AND r.status IN (?, ?))
+			FROM `+"`groups`"+` g WHERE g.id = ? FOR UPDATE`, RecurActive, RecurPaused, id).Scan(&inUse)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return ErrConflict
+		}
+		_, err = tx.db.ExecContext(ctx, "UPDATE `groups` SET base_currency = ? WHERE id = ?", currency, id)
+		return err
+	})
+}
+
+func (m *MySQL) DeleteGroup(ctx context.Context, id int) error {
+	// Children first, then the group itself
+	stmts := []string{
+		"DELETE es FROM expense_splits es JOIN expenses e ON es.expense_id = e.id WHERE e.group_id = ?",
+		"DELETE eh FROM expense_history eh JOIN expenses e ON eh.expense_id = e.id WHERE e.group_id = ?",
+		"DELETE er FROM expense_receipts er JOIN expenses e ON er.expense_id = e.id WHERE e.group_id = ?",
+		"DELETE FROM expenses WHERE group_id = ?",
+		"DELETE rs FROM recurring_expense_splits rs JOIN recurring_expenses re ON rs.recurring_id = re.id WHERE re.group_id = ?",
+		"DELETE FROM recurring_expenses WHERE group_id = ?",
+		"DELETE FROM expense_categories WHERE group_id = ?",
+		"DELETE FROM budget_alerts WHERE group_id = ?",
+		"DELETE FROM budgets WHERE group_id = ?",
+		"DELETE FROM settlements WHERE group_id = ?",
+		"DELETE dv FROM date_votes dv JOIN event_dates ed ON dv.event_date_id = ed.id WHERE ed.group_id = ?",
+		"DELETE FROM event_dates WHERE group_id = ?",
+		"DELETE th FROM task_status_history th JOIN tasks t ON th.task_id = t.id WHERE t.group_id = ?",
+		"DELETE ta FROM task_assignees ta JOIN tasks t ON ta.task_id = t.id WHERE t.group_id = ?",
+		"DELETE tc FROM task_checklist_items tc JOIN tasks t ON tc.task_id = t.id WHERE t.group_id = ?",
+		"DELETE FROM tasks WHERE group_id = ?",
+		"DELETE FROM events WHERE group_id = ?",
+		"DELETE FROM notifications WHERE group_id = ?",
+		"DELETE FROM group_members WHERE group_id = ?",
+		"DELETE FROM `groups` WHERE id = ?",
+	}
+	return m.inTx(ctx, func(tx *MySQL) error {
+		for _, stmt := range stmts {
+			if _, err := tx.db.ExecContext(ctx, stmt, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (m *MySQL) IsMember(ctx context.Context, groupID, userID int) (bool, error) {
+	var exists int
+	err := m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM group_members WHERE group_id = ? AND user_id = ?", groupID, userID).Scan(&exists)
+	return exists > 0, err
+}
+
+func (m *MySQL) AddMember(ctx context.Context, groupID, userID int) error {
+	_, err := m.db.ExecContext(ctx, "INSERT INTO group_members (group_id, user_id) VALUES (?, ?)", groupID, userID)
+	if isDuplicate(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (m *MySQL) RemoveMember(ctx context.Context, groupID, userID int) error {
+	return requireRow(m.db.ExecContext(ctx, "DELETE FROM group_members WHERE group_id = ? AND user_id = ?", groupID, userID))
+}
+
+func (m *MySQL) ListMembers(ctx context.Context, groupID int) ([]User, error) {
+	rows, err := m.db.QueryContext(ctx, `SELECT u.id, u.username FROM group_members gm JOIN users u ON gm.user_id = u.id WHERE gm.group_id = ?`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var members []User
+	for rows.Next() {
+		var u User
+		if err := rows.Scan(&u.ID, &u.Username); err != nil {
+			return nil, err
+		}
+		members = append(members, u)
+	}
+	return members, rows.Err()
+}
+
+// Proposed dates
+
+func (m *MySQL) ProposeDate(ctx context.Context, d ProposedDate) (int, error) {
+	result, err := m.db.ExecContext(ctx,
+		"INSERT INTO event_dates (group_id, date, end_date, time, proposed_by, deadline, quorum) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		d.GroupID, d.Date, nullIfEmpty(d.EndDate), nullIfEmpty(d.Time), d.ProposedBy, utcOrNil(d.Deadline), d.Quorum,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+const dateColumns = "ed.id, ed.group_id, ed.date, COALESCE(ed.end_date, ''), COALESCE(ed.time, ''), ed.proposed_by, " +
+	"ed.closed_at IS NOT NULL, COALESCE(ed.event_id, 0), " + pollColumns
+
+// pollColumns are the voting window and tally columns of event_dates, read by scanPoll
+const pollColumns = "DATE_FORMAT(ed.deadline, '%Y-%m-%dT%H:%i:%sZ'), ed.quorum, ed.final_available, ed.final_maybe, ed.final_not_available"
+
+// pollFields holds the raw pollColumns of a row until they are converted
+type pollFields struct {
+	deadline                   sql.NullString
+	quorum                     int
+	available, maybe, notAvail sql.NullInt64
+}
+
+func (p *pollFields) dest() []interface{} {
+	return []interface{}{&p.deadline, &p.quorum, &p.available, &p.maybe, &p.notAvail}
+}
+
+// values converts the raw columns; deadlines are stored in UTC
+func (p *pollFields) values() (deadline *time.Time, quorum int, tally *Tally, err error) {
+	if p.deadline.Valid {
+		t, err := time.Parse(time.RFC3339, p.deadline.String)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		deadline = &t
+	}
+	if p.available.Valid {
+		tally = &Tally{Available: int(p.available.Int64), Maybe: int(p.maybe.Int64), NotAvailable: int(p.notAvail.Int64)}
+	}
+	return deadline, p.quorum, tally, nil
+}
+
+func scanDate(row interface{ Scan(...interface{}) error }) (ProposedDate, error) {
+	var d ProposedDate
+	var p pollFields
+	dest := append([]interface{}{&d.ID, &d.GroupID, &d.Date, &d.EndDate, &d.Time, &d.ProposedBy, &d.Closed, &d.EventID}, p.dest()...)
+	if err := row.Scan(dest...); err != nil {
+		return d, err
+	}
+	var err error
+	d.Deadline, d.Quorum, d.Tally, err = p.values()
+	return d, err
+}
+
+func (m *MySQL) GetDate(ctx context.Context, id int) (ProposedDate, error) {
+	d, err := scanDate(m.db.QueryRowContext(ctx, "SELECT "+dateColumns+" FROM event_dates ed WHERE ed.id = ?", id))
+	return d, notFound(err)
+}
+
+func (m *MySQL) ListDates(ctx context.Context, groupID int) ([]DateSummary, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT ed.id, ed.date, COALESCE(ed.end_date, ''), ed.time, ed.proposed_by, u.username,
+			   COALESCE(SUM(CASE WHEN dv.response = 'yes' THEN 1 ELSE 0 END), 0) as available_votes,
+			   COALESCE(SUM(CASE WHEN dv.response = 'maybe' THEN 1 ELSE 0 END), 0) as maybe_votes,
+			   COALESCE(SUM(CASE WHEN dv.response = 'no' THEN 1 ELSE 0 END), 0) as not_available_votes,
+			   COALESCE(SUM(dv.preferred), 0) as preferred_votes,
+			   ed.closed_at IS NOT NULL, COALESCE(ed.event_id, 0), `+pollColumns+`
+		FROM event_dates ed
+		LEFT JOIN date_votes dv ON ed.id = dv.event_date_id
+		LEFT JOIN users u ON ed.proposed_by = u.id
+		WHERE ed.group_id = ?
+		GROUP BY ed.id, ed.date, ed.end_date, ed.time, ed.proposed_by, u.username, ed.closed_at, ed.event_id,
+				 ed.deadline, ed.quorum, ed.final_available, ed.final_maybe, ed.final_not_available
+		ORDER BY ed.date ASC, ed.time ASC`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var dates []DateSummary
+	for rows.Next() {
+		var d DateSummary
+		var timeNull sql.NullString
+		var p pollFields
+		dest := append([]interface{}{&d.ID, &d.Date, &d.EndDate, &timeNull, &d.ProposedBy, &d.ProposedByUsername,
+			&d.AvailableVotes, &d.MaybeVotes, &d.NotAvailableVotes, &d.PreferredVotes, &d.Closed, &d.EventID}, p.dest()...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if timeNull.Valid {
+			d.Time = timeNull.String
+		}
+		var err error
+		if d.Deadline, d.Quorum, d.Tally, err = p.values(); err != nil {
+			return nil, err
+		}
+		dates = append(dates, d)
+	}
+	return dates, rows.Err()
+}
+
+func (m *MySQL) Vote(ctx context.Context, eventDateID, userID int, b Ballot) error {
+	// Upsert: if vote exists, update; else insert
+	_, err := m.db.ExecContext(ctx,
+		`REPLACE INTO date_votes (event_date_id, user_id, response, preferred) VALUES (?, ?, ?, ?)`,
+		eventDateID, userID, b.Response, b.Preferred,
+	)
+	return err
+}
+
+func (m *MySQL) ListVotes(ctx context.Context, groupID int) ([]DateVote, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT dv.event_date_id, dv.user_id, dv.response, dv.preferred
+		FROM date_votes dv JOIN event_dates ed ON dv.event_date_id = ed.id
+		WHERE ed.group_id = ?
+		ORDER BY dv.event_date_id, dv.user_id`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var votes []DateVote
+	for rows.Next() {
+		var v DateVote
+		if err := rows.Scan(&v.EventDateID, &v.UserID, &v.Response, &v.Preferred); err != nil {
+			return nil, err
+		}
+		votes = append(votes, v)
+	}
+	return votes, rows.Err()
+}
+
+func (m *MySQL) DeleteDate(ctx context.Context, id int) error {
+	return m.inTx(ctx, func(tx *MySQL) error {
+		// Delete related votes first
+		if _, err := tx.db.ExecContext(ctx, "DELETE FROM date_votes WHERE event_date_id = ?", id); err != nil {
+			return err
+		}
+		return requireRow(tx.db.ExecContext(ctx, "DELETE FROM event_dates WHERE id = ?", id))
+	})
+}
+
+// closeDates ends voting and snapshots the vote counts; callers append the WHERE clause
+const closeDates = `UPDATE event_dates ed SET ed.closed_at = NOW(),
+	ed.final_available = (SELECT COUNT(*) FROM date_votes dv WHERE dv.event_date_id = ed.id AND dv.response = 'yes'),
+	ed.final_maybe = (SELECT COUNT(*) FROM date_votes dv WHERE dv.event_date_id = ed.id AND dv.response = 'maybe'),
+	ed.final_not_available = (SELECT COUNT(*) FROM date_votes dv WHERE dv.event_date_id = ed.id AND dv.response = 'no') `
+
+// utcOrNil stores an optional timestamp as a UTC DATETIME
+func utcOrNil(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+func (m *MySQL) SetDeadline(ctx context.Context, id int, deadline *time.Time, quorum int) error {
+	_, err := m.db.ExecContext(ctx, "UPDATE event_dates SET deadline = ?, quorum = ? WHERE id = ?", utcOrNil(deadline), quorum, id)
+	return err
+}
+
+func (m *MySQL) ExpiredDates(ctx context.Context, now time.Time) ([]ProposedDate, error) {
+	rows, err := m.db.QueryContext(ctx,
+		"SELECT "+dateColumns+" FROM event_dates ed WHERE ed.closed_at IS NULL AND ed.deadline <= ? ORDER BY ed.group_id, ed.id",
+		now.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var dates []ProposedDate
+	for rows.Next() {
+		d, err := scanDate(rows)
+		if err != nil {
+			return nil, err
+		}
+		dates = append(dates, d)
+	}
+	return dates, rows.Err()
+}
+
+func (m *MySQL) CloseDate(ctx context.Context, id int) error {
+	err := requireRow(m.db.ExecContext(ctx, closeDates+"WHERE ed.id = ? AND ed.closed_at IS NULL", id))
+	if err == ErrNotFound {
+		// Either missing or already closed
+		if _, err := m.GetDate(ctx, id); err != nil {
+			return err
+		}
+		return ErrConflict
+	}
+	return err
+}
+
+func (m *MySQL) FinalizeDate(ctx context.Context, eventDateID int, e Event) (int, error) {
+	var eventID int64
+	err := m.inTx(ctx, func(tx *MySQL) error {
+		var closed bool
+		err := tx.db.QueryRowContext(ctx, "SELECT closed_at IS NOT NULL FROM event_dates WHERE id = ? FOR UPDATE", eventDateID).Scan(&closed)
+		if err != nil {
+			return notFound(err)
+		}
+		if closed {
+			return ErrConflict
+		}
+		result, err := tx.db.ExecContext(ctx,
+			"INSERT INTO events (group_id, title, description, date, end_date, time, created_by) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			e.GroupID, e.Title, e.Description, e.Date, nullIfEmpty(e.EndDate), nullIfEmpty(e.Time), e.CreatedBy,
+		)
+		if err != nil {
+			return err
+		}
+		if eventID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		// Close the whole poll, then link the winner to its event
+		if _, err := tx.db.ExecContext(ctx, closeDates+"WHERE ed.group_id = ? AND ed.closed_at IS NULL", e.GroupID); err != nil {
+			return err
+		}
+		_, err = tx.db.ExecContext(ctx, "UPDATE event_dates SET event_id = ? WHERE id = ?", eventID, eventDateID)
+		return err
+	})
+	return int(eventID), err
+}
+
+// Events
+
+func (m *MySQL) CreateEvent(ctx context.Context, e Event) (int, error) {
+	result, err := m.db.ExecContext(ctx,
+		"INSERT INTO events (group_id, title, description, date, end_date, time, created_by) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		e.GroupID, e.Title, e.Description, e.Date, nullIfEmpty(e.EndDate), nullIfEmpty(e.Time), e.CreatedBy,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+const eventColumns = "id, group_id, title, description, date, COALESCE(end_date, ''), COALESCE(time, ''), created_by"
+
+func (m *MySQL) GetEvent(ctx context.Context, id int) (Event, error) {
+	var e Event
+	err := m.db.QueryRowContext(ctx, "SELECT "+eventColumns+" FROM events WHERE id = ?", id).
+		Scan(&e.ID, &e.GroupID, &e.Title, &e.Description, &e.Date, &e.EndDate, &e.Time, &e.CreatedBy)
+	return e, notFound(err)
+}
+
+func (m *MySQL) ListEvents(ctx context.Context, f EventFilter) ([]Event, error) {
+	query := "SELECT " + eventColumns + " FROM events WHERE group_id = ?"
+	args := []interface{}{f.GroupID}
+	if f.From != "" {
+		query += " AND date >= ?"
+		args = append(args, f.From)
+	}
+	if f.To != "" {
+		query += " AND date <= ?"
+		args = append(args, f.To)
+	}
+	query += " ORDER BY date ASC, id ASC"
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.GroupID, &e.Title, &e.Description, &e.Date, &e.EndDate, &e.Time, &e.CreatedBy); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (m *MySQL) UpdateEvent(ctx context.Context, e Event) error {
+	_, err := m.db.ExecContext(ctx,
+		"UPDATE events SET title = ?, description = ?, date = ?, end_date = ?, time = ? WHERE id = ?",
+		e.Title, e.Description, e.Date, nullIfEmpty(e.EndDate), nullIfEmpty(e.Time), e.ID,
+	)
+	return err
+}
+
+func (m *MySQL) DeleteEvent(ctx context.Context, id int) error {
+	return requireRow(m.db.ExecContext(ctx, "DELETE FROM events WHERE id = ?", id))
+}
+
+// Tasks
+
+func (m *MySQL) CreateTask(ctx context.Context, t Task) (int, error) {
+	var taskID int64
+	err := m.inTx(ctx, func(tx *MySQL) error {
+		result, err := tx.db.ExecContext(ctx,
+			"INSERT INTO tasks (group_id, title, description, due_date, status) VALUES (?, ?, ?, ?, ?)",
+			t.GroupID, t.Title, t.Description, nullIfEmpty(t.DueDate), t.Status,
+		)
+		if err != nil {
+			return err
+		}
+		if taskID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		if t.AssigneeID == 0 {
+			return nil
+		}
+		return tx.AddTaskAssignee(ctx, int(taskID), t.AssigneeID)
+	})
+	return int(taskID), err
+}
+
+const taskColumns = "id, group_id, title, description, COALESCE(due_date, ''), status, " +
+	"COALESCE(status_changed_by, 0), COALESCE(UNIX_TIMESTAMP(status_changed_at), 0)"
+
+func scanTask(row interface{ Scan(...interface{}) error }) (Task, error) {
+	var t Task
+	var changedAt int64
+	err := row.Scan(&t.ID, &t.GroupID, &t.Title, &t.Description, &t.DueDate, &t.Status, &t.StatusChangedBy, &changedAt)
+	if changedAt != 0 {
+		at := time.Unix(changedAt, 0).UTC()
+		t.StatusChangedAt = &at
+	}
+	return t, err
+}
+
+// fillTasks loads the assignees and checklist items of tasks from the rows
+// of task_assignees and task_checklist_items matching where
+func (m *MySQL) fillTasks(ctx context.Context, tasks []Task, where string, arg int) error {
+	byID := make(map[int]*Task, len(tasks))
+	for i := range tasks {
+		tasks[i].Assignees = []int{}
+		tasks[i].Checklist = []ChecklistItem{}
+		byID[tasks[i].ID] = &tasks[i]
+	}
+	assignees, err := m.taskAssignees(ctx, where, arg)
+	if err != nil {
+		return err
+	}
+	for taskID, users := range assignees {
+		if t, ok := byID[taskID]; ok {
+			t.Assignees = users
+		}
+	}
+	items, err := m.listChecklistItems(ctx, "SELECT "+checklistColumns+" FROM task_checklist_items WHERE "+where+" ORDER BY id", arg)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if t, ok := byID[item.TaskID]; ok {
+			t.Checklist = append(t.Checklist, item)
+		}
+	}
+	for _, t := range byID {
+		if len(t.Assignees) > 0 {
+			t.AssigneeID = t.Assignees[0]
+		}
+	}
+	return nil
+}
+
+// taskAssignees maps task IDs to the user IDs assigned to them, in the
+// order they were assigned, for the rows of task_assignees matching where
+func (m *MySQL) taskAssignees(ctx context.Context, where string, arg int) (map[int][]int, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT task_id, user_id FROM task_assignees WHERE "+where+" ORDER BY id", arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	assignees := map[int][]int{}
+	for rows.Next() {
+		var taskID, userID int
+		if err := rows.Scan(&taskID, &userID); err != nil {
+			return nil, err
+		}
+		assignees[taskID] = append(assignees[taskID], userID)
+	}
+	return assignees, rows.Err()
+}
+
+func (m *MySQL) GetTask(ctx context.Context, id int) (Task, error) {
+	t, err := scanTask(m.db.QueryRowContext(ctx, "SELECT "+taskColumns+" FROM tasks WHERE id = ?", id))
+	if err != nil {
+		return t, notFound(err)
+	}
+	tasks := []Task{t}
+	err = m.fillTasks(ctx, tasks, "task_id = ?", id)
+	return tasks[0], err
+}
+
+func (m *MySQL) ListTasks(ctx context.Context, f TaskFilter) ([]Task, error) {
+	query := "SELECT " + taskColumns + " FROM tasks WHERE group_id = ?"
+	args := []interface{}{f.GroupID}
+	if f.Status != "" {
+		query += " AND status = ?"
+		args = append(args, f.Status)
+	}
+	if f.AssigneeID != 0 {
+		query += " AND id IN (SELECT task_id FROM task_assignees WHERE user_id = ?)"
+		args = append(args, f.AssigneeID)
+	}
+	tasks, err := m.listTasks(ctx, query+" ORDER BY id", args...)
+	if err != nil || len(tasks) == 0 {
+		return tasks, err
+	}
+	err = m.fillTasks(ctx, tasks, "task_id IN (SELECT id FROM tasks WHERE group_id = ?)", f.GroupID)
+	return tasks, err
+}
+
+func (m *MySQL) listTasks(ctx context.Context, query string, args ...interface{}) ([]Task, error) {
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tasks []Task
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+func (m *MySQL) AssignTask(ctx context.Context, id, assigneeID int) error {
+	return m.inTx(ctx, func(tx *MySQL) error {
+		if _, err := tx.db.ExecContext(ctx, "DELETE FROM task_assignees WHERE task_id = ?", id); err != nil {
+			return err
+		}
+		if assigneeID == 0 {
+			return nil
+		}
+		return tx.AddTaskAssignee(ctx, id, assigneeID)
+	})
+}
+
+func (m *MySQL) AddTaskAssignee(ctx context.Context, taskID, userID int) error {
+	_, err := m.db.ExecContext(ctx, "INSERT INTO task_assignees (task_id, user_id) VALUES (?, ?)", taskID, userID)
+	if isDuplicate(err) {
+		return ErrConflict
+	}
+	return err
+}
+
+func (m *MySQL) RemoveTaskAssignee(ctx context.Context, taskID, userID int) error {
+	return requireRow(m.db.ExecContext(ctx, "DELETE FROM task_assignees WHERE task_id = ? AND user_id = ?", taskID, userID))
+}
+
+func (m *MySQL) UpdateTask(ctx context.Context, id int, u TaskUpdate) error {
+	set := []string{}
+	args := []interface{}{}
+	if u.Title != "" {
+		set = append(set, "title = ?")
+		args = append(args, u.Title)
+	}
+	if u.Description != "" {
+		set = append(set, "description = ?")
+		args = append(args, u.Description)
+	}
+	if u.DueDate != "" {
+		set = append(set, "due_date = ?")
+		args = append(args, u.DueDate)
+	}
+	return m.inTx(ctx, func(tx *MySQL) error {
+		if len(set) > 0 {
+			query := "UPDATE tasks SET " + strings.Join(set, ", ") + " WHERE id = ?"
+			if _, err := tx.db.ExecContext(ctx, query, append(args, id)...); err != nil {
+				return err
+			}
+		}
+		if u.AssigneeID == 0 {
+			return nil
+		}
+		return tx.AssignTask(ctx, id, u.AssigneeID)
+	})
+}
+
+func (m *MySQL) SetTaskStatus(ctx context.Context, id int, from, to string, changedBy int) error {
+	return m.inTx(ctx, func(tx *MySQL) error {
+		result, err := tx.db.ExecContext(ctx,
+			"UPDATE tasks SET status = ?, status_changed_by = ?, status_changed_at = CURRENT_TIMESTAMP WHERE id = ? AND status = ?",
+			to, changedBy, id, from)
+		if err := requireRow(result, err); err == ErrNotFound {
+			return ErrConflict
+		} else if err != nil {
+			return err
+		}
+		_, err = tx.db.ExecContext(ctx,
+			"INSERT INTO task_status_history (task_id, from_status, to_status, changed_by) VALUES (?, ?, ?, ?)",
+			id, from, to, changedBy)
+		return err
+	})
+}
+
+func (m *MySQL) ListTaskStatusHistory(ctx context.Context, taskID int) ([]TaskStatusChange, error) {
+	rows, err := m.db.QueryContext(ctx,
+		"SELECT id, task_id, from_status, to_status, changed_by, UNIX_TIMESTAMP(changed_at) FROM task_status_history WHERE task_id = ? ORDER BY id DESC",
+		taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var history []TaskStatusChange
+	for rows.Next() {
+		var c TaskStatusChange
+		var changedAt int64
+		if err := rows.Scan(&c.ID, &c.TaskID, &c.From, &c.To, &c.ChangedBy, &changedAt); err != nil {
+			return nil, err
+		}
+		c.ChangedAt = time.Unix(changedAt, 0).UTC()
+		history = append(history, c)
+	}
+	return history, rows.Err()
+}
+
+func (m *MySQL) DeleteTask(ctx context.Context, id int) error {
+	_, err := m.db.ExecContext(ctx, "DELETE FROM tasks WHERE id = ?", id)
+	return err
+}
+
+const checklistColumns = "id, task_id, title, done, COALESCE(done_by, 0), COALESCE(UNIX_TIMESTAMP(done_at), 0)"
+
+func scanChecklistItem(row interface{ Scan(...interface{}) error }) (ChecklistItem, error) {
+	var item ChecklistItem
+	var doneAt int64
+	err := row.Scan(&item.ID, &item.TaskID, &item.Title, &item.Done, &item.DoneBy, &doneAt)
+	if doneAt != 0 {
+		at := time.Unix(doneAt, 0).UTC()
+		item.DoneAt = &at
+	}
+	return item, err
+}
+
+func (m *MySQL) listChecklistItems(ctx context.Context, query string, arg int) ([]ChecklistItem, error) {
+	rows, err := m.db.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChecklistItem
+	for rows.Next() {
+		item, err := scanChecklistItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (m *MySQL) AddChecklistItem(ctx context.Context, taskID int, title string) (int, error) {
+	result, err := m.db.ExecContext(ctx,
+		"INSERT INTO task_checklist_items (task_id, title) SELECT id, ? FROM tasks WHERE id = ?", title, taskID)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, ErrNotFound
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func (m *MySQL) GetChecklistItem(ctx context.Context, id int) (ChecklistItem, error) {
+	item, err := scanChecklistItem(m.db.QueryRowContext(ctx, "SELECT "+checklistColumns+" FROM task_checklist_items WHERE id = ?", id))
+	return item, notFound(err)
+}
+
+func (m *MySQL) RenameChecklistItem(ctx context.Context, id int, title string) error {
+	return requireRow(m.db.ExecContext(ctx, "UPDATE task_checklist_items SET title = ? WHERE id = ?", title, id))
+}
+
+func (m *MySQL) SetChecklistItemDone(ctx context.Context, id int, done bool, doneBy int) error {
+	if !done {
+		return requireRow(m.db.ExecContext(ctx,
+			"UPDATE task_checklist_items SET done = 0, done_by = NULL, done_at = NULL WHERE id = ?", id))
+	}
+	return requireRow(m.db.ExecContext(ctx,
+		"UPDATE task_checklist_items SET done = 1, done_by = ?, done_at = CURRENT_TIMESTAMP WHERE id = ?", doneBy, id))
+}
+
+func (m *MySQL) DeleteChecklistItem(ctx context.Context, id int) error {
+	return requireRow(m.db.ExecContext(ctx, "DELETE FROM task_checklist_items WHERE id = ?", id))
+}
+
+// Expenses
+
+func (m *MySQL) CreateExpense(ctx context.Context, e Expense, splits []Split) (int, error) {
+	var expenseID int64
+	err := m.inTx(ctx, func(tx *MySQL) error {
+		result, err := tx.db.ExecContext(ctx,
+			"INSERT INTO expenses (group_id, description, amount_mills, currency, exchange_rate, base_amount_mills, paid_by, date, category, split_mode, recurring_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			e.GroupID, e.Description, e.Amount, e.Currency, e.Rate, e.BaseAmount, e.PaidBy, e.Date, e.Category, e.SplitMode, nullIfZero(e.RecurringID),
+		)
+		if err != nil {
+			return err
+		}
+		if expenseID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		return tx.insertSplits(ctx, int(expenseID), splits)
+	})
+	return int(expenseID), err
+}
+
+func (m *MySQL) insertSplits(ctx context.Context, expenseID int, splits []Split) error {
+	for _, s := range splits {
+		_, err := m.db.ExecContext(ctx,
+			"INSERT INTO expense_splits (expense_id, user_id, amount_mills, base_amount_mills, weight) VALUES (?, ?, ?, ?, ?)",
+			expenseID, s.UserID, s.Amount, s.BaseAmount, nullIfZeroFloat(s.Weight),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const expenseColumns = "id, group_id, description, amount_mills, currency, exchange_rate, base_amount_mills, paid_by, date, category, split_mode, COALESCE(recurring_id, 0)"
+
+func (m *MySQL) GetExpense(ctx context.Context, id int) (Expense, error) {
+	var e Expense
+	err := m.db.QueryRowContext(ctx, "SELECT "+expenseColumns+" FROM expenses WHERE id = ?", id).
+		Scan(&e.ID, &e.GroupID, &e.Description, &e.Amount, &e.Currency, &e.Rate, &e.BaseAmount, &e.PaidBy, &e.Date, &e.Category, &e.SplitMode, &e.RecurringID)
+	return e, notFound(err)
+}
+
+func (m *MySQL) ListExpenses(ctx context.Context, groupID int) ([]Expense, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT "+expenseColumns+" FROM expenses WHERE group_id = ? ORDER BY date DESC, id DESC", groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var expenses []Expense
+	for rows.Next() {
+		var e Expense
+		if err := rows.Scan(&e.ID, &e.GroupID, &e.Description, &e.Amount, &e.Currency, &e.Rate, &e.BaseAmount, &e.PaidBy, &e.Date, &e.Category, &e.SplitMode, &e.RecurringID); err != nil {
+			return nil, err
+		}
+		expenses = append(expenses, e)
+	}
+	return expenses, rows.Err()
+}
+
+func (m *MySQL) ListSplits(ctx context.Context, expenseID int) ([]Split, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT user_id, amount_mills, base_amount_mills, COALESCE(weight, 0) FROM expense_splits WHERE expense_id = ?", expenseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var splits []Split
+	for rows.Next() {
+		var s Split
+		if err := rows.Scan(&s.UserID, &s.Amount, &s.BaseAmount, &s.Weight); err != nil {
+			return nil, err
+		}
+		splits = append(splits, s)
+	}
+	return splits, rows.Err()
+}
+
+func (m *MySQL) GroupSplits(ctx context.Context, groupID int) (map[int][]Split, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT s.expense_id, s.user_id, s.amount_mills, s.base_amount_mills, COALESCE(s.weight, 0)
+		FROM expense_splits s JOIN expenses e ON s.expense_id = e.id
+		WHERE e.group_id = ?`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	splits := map[int][]Split{}
+	for rows.Next() {
+		var expenseID int
+		var s Split
+		if err := rows.Scan(&expenseID, &s.UserID, &s.Amount, &s.BaseAmount, &s.Weight); err != nil {
+			return nil, err
+		}
+		splits[expenseID] = append(splits[expenseID], s)
+	}
+	return splits, rows.Err()
+}
+
+func (m *MySQL) GroupBalances(ctx context.Context, groupID int) (map[int]money.Amount, error) {
+	rows, err := m.db.QueryContext(ctx, `
+		SELECT user_id, SUM(amount) FROM (
+			SELECT paid_by AS user_id, base_amount_mills AS amount FROM expenses WHERE group_id = ?
+			UNION ALL
+			SELECT s.user_id, -s.base_amount_mills FROM expense_splits s JOIN expenses e ON s.expense_id = e.id WHERE e.group_id = ?
+			UNION ALL
+			SELECT from_user_id, base_amount_mills FROM settlements WHERE group_id = ?
+			UNION ALL
+			SELECT to_user_id, -base_amount_mills FROM settlements WHERE group_id = ?
+		) entries
+		GROUP BY user_id`, groupID, groupID, groupID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	balances := map[int]money.Amount{}
+	for rows.Next() {
+		var userID int
+		var balance money.Amount
+		if err := rows.Scan(&userID, &balance); err != nil {
+			return nil, err
+		}
+		balances[userID] = balance
+	}
+	return balances, rows.Err()
+}
+
+func (m *MySQL) UpdateExpense(ctx context.Context, e Expense, splits []Split, rev ExpenseRevision) error {
+	changes, err := json.Marshal(rev.Changes)
+	if err != nil {
+		return err
+	}
+	return m.inTx(ctx, func(tx *MySQL) error {
+		result, err := tx.db.ExecContext(ctx,
+			`UPDATE expenses SET description = ?, amount_mills = ?, currency = ?, exchange_rate = ?, base_amount_mills = ?,
+			paid_by = ?, date = ?, category = ?, split_mode = ? WHERE id = ?`,
+			e.Description, e.Amount, e.Currency, e.Rate, e.BaseAmount, e.PaidBy, e.Date, e.Category, e.SplitMode, e.ID,
+		)
+		if err := requireRow(result, err); err != nil {
+			return err
+		}
+		if _, err := tx.db.ExecContext(ctx, "DELETE FROM expense_splits WHERE expense_id = ?", e.ID); err != nil {
+			return err
+		}
+		if err := tx.insertSplits(ctx, e.ID, splits); err != nil {
+			return err
+		}
+		_, err = tx.db.ExecContext(ctx,
+			"INSERT INTO expense_history (expense_id, changed_by, changes) VALUES (?, ?, ?)",
+			e.ID, rev.ChangedBy, string(changes),
+		)
+		return err
+	})
+}
+
+func (m *MySQL) ListExpenseHistory(ctx context.Context, expenseID int) ([]ExpenseRevision, error) {
+	rows, err := m.db.QueryContext(ctx,
+		"SELECT id, expense_id, changed_by, UNIX_TIMESTAMP(changed_at), changes FROM expense_history WHERE expense_id = ? ORDER BY id DESC",
+		expenseID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var history []ExpenseRevision
+	for rows.Next() {
+		var rev ExpenseRevision
+		var changedAt int64
+		var changes string
+		if err := rows.Scan(&rev.ID, &rev.ExpenseID, &rev.ChangedBy, &changedAt, &changes); err != nil {
+			return nil, err
+		}
+		rev.ChangedAt = time.Unix(changedAt, 0).UTC()
+		if err := json.Unmarshal([]byte(changes), &rev.Changes); err != nil {
+			return nil, err
+		}
+		history = append(history, rev)
+	}
+	return history, rows.Err()
+}
+
+// reportWhere filters the expenses table (aliased e) for a report
+func reportWhere(f ReportFilter) (string, []interface{}) {
+	where := "e.group_id = ?"
+	args := []interface{}{f.GroupID}
+	if f.From != "" {
+		where += " AND e.date >= ?"
+		args = append(args, f.From)
+	}
+	if f.To != "" {
+		where += " AND e.date <= ?"
+		args = append(args, f.To)
+	}
+	return where, args
+}
+
+func (m *MySQL) Report(ctx context.Context, f ReportFilter) (ExpenseReport, error) {
+	report := ExpenseReport{ByCategory: []CategoryTotal{}, ByMember: []MemberTotal{}, ByMonth: []MonthTotal{}}
+	where, args := reportWhere(f)
+
+	rows, err := m.db.QueryContext(ctx,
+		"SELECT e.category, SUM(e.base_amount_mills), COUNT(*) FROM expenses e WHERE "+where+
+			" GROUP BY e.category ORDER BY SUM(e.base_amount_mills) DESC, e.category", args...)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var c CategoryTotal
+		if err := rows.Scan(&c.Category, &c.Total, &c.Count); err != nil {
+			return report, err
+		}
+		report.ByCategory = append(report.ByCategory, c)
+		report.Total += c.Total
+		report.Count += c.Count
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+
+	memberRows, err := m.db.QueryContext(ctx, `
+		SELECT t.user_id, u.username, SUM(t.paid), SUM(t.share) FROM (
+			SELECT e.paid_by AS user_id, e.base_amount_mills AS paid, 0 AS share FROM expenses e WHERE `+where+`
+			UNION ALL
+			SELECT s.user_id, 0, s.base_amount_mills FROM expense_splits s JOIN expenses e ON s.expense_id = e.id WHERE `+where+`
+		) t JOIN users u ON t.user_id = u.id
+		GROUP BY t.user_id, u.username
+		ORDER BY t.user_id`, append(append([]interface{}{}, args...), args...)...)
+	if err != nil {
+		return report, err
+	}
+	defer memberRows.Close()
+	for memberRows.Next() {
+		var mt MemberTotal
+		if err := memberRows.Scan(&mt.UserID, &mt.Username, &mt.Paid, &mt.Share); err != nil {
+			return report, err
+		}
+		report.ByMember = append(report.ByMember, mt)
+	}
+	if err := memberRows.Err(); err != nil {
+		return report, err
+	}
+
+	monthRows, err := m.db.QueryContext(ctx,
+		"SELECT DATE_FORMAT(e.date, '%Y-%m') AS month, SUM(e.base_amount_mills), COUNT(*) FROM expenses e WHERE "+where+
+			" GROUP BY month ORDER BY month", args...)
+	if err != nil {
+		return report, err
+	}
+	defer monthRows.Close()
+	for monthRows.Next() {
+		var mt MonthTotal
+		if err := monthRows.Scan(&mt.Month, &mt.Total, &mt.Count); err != nil {
+			return report, err
+		}
+		report.ByMonth = append(report.ByMonth, mt)
+	}
+	return report, monthRows.Err()
+}
+
+func (m *MySQL) DeleteExpense(ctx context.Context, id int) error {
+	return m.inTx(ctx, func(tx *MySQL) error {
+		// Delete splits, history and receipts for this expense first
+		if _, err := tx.db.ExecContext(ctx, "DELETE FROM expense_splits WHERE expense_id = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.db.ExecContext(ctx, "DELETE FROM expense_history WHERE expense_id = ?", id); err != nil {
+			return err
+		}
+		if _, err := tx.db.ExecContext(ctx, "DELETE FROM expense_receipts WHERE expense_id = ?", id); err != nil {
+			return err
+		}
+		// Then delete from expenses table
+		_, err := tx.db.ExecContext(ctx, "DELETE FROM expenses WHERE id = ?", id)
+		return err
+	})
+}
+
+// Receipts
+
+const receiptColumns = "r.id, r.expense_id, r.filename, r.content_type, r.size_bytes, r.blob_key, r.uploaded_by, UNIX_TIMESTAMP(r.uploaded_at)"
+
+func scanReceipt(row interface{ Scan(...interface{}) error }) (Receipt, error) {
+	var rc Receipt
+	var uploadedAt int64
+	err := row.Scan(&rc.ID, &rc.ExpenseID, &rc.Filename, &rc.ContentType, &rc.Size, &rc.BlobKey, &rc.UploadedBy, &uploadedAt)
+	rc.UploadedAt = time.Unix(uploadedAt, 0).UTC()
+	return rc, err
+}
+
+func (m *MySQL) listReceipts(ctx context.Context, query string, arg int) ([]Receipt, error) {
+	rows, err := m.db.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var receipts []Receipt
+	for rows.Next() {
+		rc, err := scanReceipt(rows)
+		if err != nil {
+			return nil, err
+		}
+		receipts = append(receipts, rc)
+	}
+	return receipts, rows.Err()
+}
+
+func (m *MySQL) AddReceipt(ctx context.Context, rc Receipt) (int, error) {
+	result, err := m.db.ExecContext(ctx,
+		"INSERT INTO expense_receipts (expense_id, filename, content_type, size_bytes, blob_key, uploaded_by) SELECT id, ?, ?, ?, ?, ? FROM expenses WHERE id = ?",
+		rc.Filename, rc.ContentType, rc.Size, rc.BlobKey, rc.UploadedBy, rc.ExpenseID)
+	if err != nil {
+		return 0, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, ErrNotFound
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func (m *MySQL) GetReceipt(ctx context.Context, id int) (Receipt, error) {
+	rc, err := scanReceipt(m.db.QueryRowContext(ctx, "SELECT "+receiptColumns+" FROM expense_receipts r WHERE r.id = ?", id))
+	return rc, notFound(err)
+}
+
+func (m *MySQL) ListReceipts(ctx context.Context, expenseID int) ([]Receipt, error) {
+	return m.listReceipts(ctx, "SELECT "+receiptColumns+" FROM expense_receipts r WHERE r.expense_id = ? ORDER BY r.id", expenseID)
+}
+
+func (m *MySQL) ListGroupReceipts(ctx context.Context, groupID int) ([]Receipt, error) {
+	return m.listReceipts(ctx, "SELECT "+receiptColumns+" FROM expense_receipts r JOIN expenses e ON r.expense_id = e.id WHERE e.group_id = ? ORDER BY r.id", groupID)
+}
+
+func (m *MySQL) DeleteReceipt(ctx context.Context, id int) error {
+	return requireRow(m.db.ExecContext(ctx, "DELETE FROM expense_receipts WHERE id = ?", id))
+}
+
+// Categories
+
+func (m *MySQL) ListCategories(ctx context.Context, groupID int) ([]Category, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT id, group_id, name FROM expense_categories WHERE group_id = ? ORDER BY name", groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var categories []Category
+	for rows.Next() {
+		var c Category
+		if err := rows.Scan(&c.ID, &c.GroupID, &c.Name); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
+
+func (m *MySQL) GetCategory(ctx context.Context, id int) (Category, error) {
+	var c Category
+	err := m.db.QueryRowContext(ctx, "SELECT id, group_id, name FROM expense_categories WHERE id = ?", id).Scan(&c.ID, &c.GroupID, &c.Name)
+	return c, notFound(err)
+}
+
+func (m *MySQL) AddCategory(ctx context.Context, groupID int, name string) (int, error) {
+	result, err := m.db.ExecContext(ctx, "INSERT INTO expense_categories (group_id, name) VALUES (?, ?)", groupID, name)
+	if isDuplicate(err) {
+		return 0, ErrConflict
+	}
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func (m *MySQL) DeleteCategory(ctx context.Context, id int) error {
+	return m.inTx(ctx, func(tx *MySQL) error {
+		var inUse bool
+		err := tx.db.QueryRowContext(ctx, `
+			SELECT EXISTS (SELECT 1 FROM expenses e WHERE e.group_id = c.group_id AND e.category = c.name)
+				OR EXISTS (SELECT 1 FROM recurring_expenses r WHERE r.group_id = c.group_id AND r.category = c.name)
+				OR EXISTS (SELECT 1 FROM budgets b WHERE b.group_id = c.group_id AND b.category = c.name)
+			FROM expense_categories c WHERE c.id = ? FOR UPDATE`, id).Scan(&inUse)
+		if err != nil {
+			return notFound(err)
+		}
+		if inUse {
+			return ErrConflict
+		}
+		return requireRow(tx.db.ExecContext(ctx, "DELETE FROM expense_categories WHERE id = ?", id))
+	})
+}
+
+// Recurring expenses
+
+const recurringColumns = `id, group_id, description, amount_mills, currency, exchange_rate, paid_by, category, split_mode,
+	frequency, ` + "`interval`" + `, start_date, COALESCE(end_date, ''), next_date, occurrence, status, created_by`
+
+func scanRecurring(row interface{ Scan(...interface{}) error }) (RecurringExpense, error) {
+	var r RecurringExpense
+	err := row.Scan(&r.ID, &r.GroupID, &r.Description, &r.Amount, &r.Currency, &r.Rate, &r.PaidBy, &r.Category, &r.SplitMode,
+		&r.Frequency, &r.Interval, &r.StartDate, &r.EndDate, &r.NextDate, &r.Occurrence, &r.Status, &r.CreatedBy)
+	return r, err
+}
+
+// listRecurring runs a query selecting recurringColumns and loads the
+// templates' splits with one more query
+func (m *MySQL) listRecurring(ctx context.Context, where string, args ...interface{}) ([]RecurringExpense, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT "+recurringColumns+" FROM recurring_expenses WHERE "+where+" ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var templates []RecurringExpense
+	index := map[int]int{}
+	var ids []interface{}
+	for rows.Next() {
+		r, err := scanRecurring(rows)
+		if err != nil {
+			return nil, err
+		}
+		index[r.ID] = len(templates)
+		ids = append(ids, r.ID)
+		templates = append(templates, r)
+	}
+	if err := rows.Err(); err != nil || len(templates) == 0 {
+		return templates, err
+	}
+	splitRows, err := m.db.QueryContext(ctx,
+		"SELECT recurring_id, user_id, amount_mills, COALESCE(weight, 0) FROM recurring_expense_splits WHERE recurring_id IN (?"+strings.Repeat(", ?", len(ids)-1)+") ORDER BY recurring_id, user_id",
+		ids...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer splitRows.Close()
+	for splitRows.Next() {
+		var id int
+		var s Split
+		if err := splitRows.Scan(&id, &s.UserID, &s.Amount, &s.Weight); err != nil {
+			return nil, err
+		}
+		templates[index[id]].Splits = append(templates[index[id]].Splits, s)
+	}
+	return templates, splitRows.Err()
+}
+
+func (m *MySQL) insertRecurringSplits(ctx context.Context, recurringID int, splits []Split) error {
+	for _, s := range splits {
+		_, err := m.db.ExecContext(ctx,
+			"INSERT INTO recurring_expense_splits (recurring_id, user_id, amount_mills, weight) VALUES (?, ?, ?, ?)",
+			recurringID, s.UserID, s.Amount, nullIfZeroFloat(s.Weight),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MySQL) CreateRecurring(ctx context.Context, r RecurringExpense) (int, error) {
+	var id int64
+	err := m.inTx(ctx, func(tx *MySQL) error {
+		result, err := tx.db.ExecContext(ctx,
+			`INSERT INTO recurring_expenses (group_id, description, amount_mills, currency, exchange_rate, paid_by, category, split_mode,
+			frequency, `+"`interval`"+`, start_date, end_date, next_date, occurrence, status, created_by)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			r.GroupID, r.Description, r.Amount, r.Currency, r.Rate, r.PaidBy, r.Category, r.SplitMode,
+			r.Frequency, r.Interval, r.StartDate, nullIfEmpty(r.EndDate), r.NextDate, r.Occurrence, r.Status, r.CreatedBy,
+		)
+		if err != nil {
+			return err
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		return tx.insertRecurringSplits(ctx, int(id), r.Splits)
+	})
+	return int(id), err
+}
+
+func (m *MySQL) GetRecurring(ctx context.Context, id int) (RecurringExpense, error) {
+	templates, err := m.listRecurring(ctx, "id = ?", id)
+	if err != nil {
+		return RecurringExpense{}, err
+	}
+	if len(templates) == 0 {
+		return RecurringExpense{}, ErrNotFound
+	}
+	return templates[0], nil
+}
+
+func (m *MySQL) ListRecurring(ctx context.Context, groupID int) ([]RecurringExpense, error) {
+	return m.listRecurring(ctx, "group_id = ?", groupID)
+}
+
+func (m *MySQL) UpdateRecurring(ctx context.Context, r RecurringExpense) error {
+	return m.inTx(ctx, func(tx *MySQL) error {
+		result, err := tx.db.ExecContext(ctx,
+			`UPDATE recurring_expenses SET description = ?, amount_mills = ?, currency = ?, exchange_rate = ?, paid_by = ?, category = ?,
+			split_mode = ?, frequency = ?, `+"`interval`"+` = ?, start_date = ?, end_date = ?, next_date = ?, occurrence = ?, status = ?
+			WHERE id = ?`,
+			r.Description, r.Amount, r.Currency, r.Rate, r.PaidBy, r.Category,
+			r.SplitMode, r.Frequency, r.Interval, r.StartDate, nullIfEmpty(r.EndDate), r.NextDate, r.Occurrence, r.Status,
+			r.ID,
+		)
+		if err := requireRow(result, err); err != nil {
+			return err
+		}
+		if _, err := tx.db.ExecContext(ctx, "DELETE FROM recurring_expense_splits WHERE recurring_id = ?", r.ID); err != nil {
+			return err
+		}
+		return tx.insertRecurringSplits(ctx, r.ID, r.Splits)
+	})
+}
+
+func (m *MySQL) DueRecurring(ctx context.Context, today string) ([]RecurringExpense, error) {
+	return m.listRecurring(ctx, "status = ? AND next_date <= ?", RecurActive, today)
+}
+
+func (m *MySQL) AdvanceRecurring(ctx context.Context, r RecurringExpense, fromDate string) error {
+	err := requireRow(m.db.ExecContext(ctx,
+		"UPDATE recurring_expenses SET next_date = ?, occurrence = ?, status = ? WHERE id = ? AND next_date = ? AND status = ?",
+		r.NextDate, r.Occurrence, r.Status, r.ID, fromDate, RecurActive,
+	))
+	if err == ErrNotFound {
+		return ErrConflict
+	}
+	return err
+}
+
+// Budgets
+
+func (m *MySQL) SetBudget(ctx context.Context, b Budget) (int, error) {
+	// LAST_INSERT_ID(id) makes an update report the existing row's ID
+	result, err := m.db.ExecContext(ctx,
+		`INSERT INTO budgets (group_id, category, limit_mills, created_by) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE limit_mills = VALUES(limit_mills), id = LAST_INSERT_ID(id)`,
+		b.GroupID, b.Category, b.Limit, b.CreatedBy)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+const budgetColumns = "id, group_id, category, limit_mills, created_by"
+
+func scanBudget(row interface{ Scan(...interface{}) error }) (Budget, error) {
+	var b Budget
+	err := row.Scan(&b.ID, &b.GroupID, &b.Category, &b.Limit, &b.CreatedBy)
+	return b, err
+}
+
+func (m *MySQL) GetBudget(ctx context.Context, id int) (Budget, error) {
+	b, err := scanBudget(m.db.QueryRowContext(ctx, "SELECT "+budgetColumns+" FROM budgets WHERE id = ?", id))
+	return b, notFound(err)
+}
+
+func (m *MySQL) ListBudgets(ctx context.Context, groupID int) ([]Budget, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT "+budgetColumns+" FROM budgets WHERE group_id = ? ORDER BY category", groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var budgets []Budget
+	for rows.Next() {
+		b, err := scanBudget(rows)
+		if err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, b)
+	}
+	return budgets, rows.Err()
+}
+
+func (m *MySQL) DeleteBudget(ctx context.Context, id int) error {
+	return m.inTx(ctx, func(tx *MySQL) error {
+		if _, err := tx.db.ExecContext(ctx, "DELETE FROM budget_alerts WHERE budget_id = ?", id); err != nil {
+			return err
+		}
+		return requireRow(tx.db.ExecContext(ctx, "DELETE FROM budgets WHERE id = ?", id))
+	})
+}
+
+func (m *MySQL) AddBudgetAlert(ctx context.Context, a BudgetAlert) (int, error) {
+	result, err := m.db.ExecContext(ctx,
+		"INSERT INTO budget_alerts (budget_id, group_id, category, limit_mills, spent_mills, expense_id) VALUES (?, ?, ?, ?, ?, ?)",
+		a.BudgetID, a.GroupID, a.Category, a.Limit, a.Spent, nullIfZero(a.ExpenseID))
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+func (m *MySQL) ListBudgetAlerts(ctx context.Context, groupID int) ([]BudgetAlert, error) {
+	rows, err := m.db.QueryContext(ctx,
+		`SELECT id, budget_id, group_id, category, limit_mills, spent_mills, COALESCE(expense_id, 0), UNIX_TIMESTAMP(created_at)
+		FROM budget_alerts WHERE group_id = ? ORDER BY id DESC`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var alerts []BudgetAlert
+	for rows.Next() {
+		var a BudgetAlert
+		var createdAt int64
+		if err := rows.Scan(&a.ID, &a.BudgetID, &a.GroupID, &a.Category, &a.Limit, &a.Spent, &a.ExpenseID, &createdAt); err != nil {
+			return nil, err
+		}
+		a.CreatedAt = time.Unix(createdAt, 0).UTC()
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// Settlements
+
+func (m *MySQL) CreateSettlement(ctx context.Context, st Settlement) (int, error) {
+	result, err := m.db.ExecContext(ctx,
+		`INSERT INTO settlements (group_id, from_user_id, to_user_id, amount_mills, currency, exchange_rate, base_amount_mills, date, note, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		st.GroupID, st.FromUserID, st.ToUserID, st.Amount, st.Currency, st.Rate, st.BaseAmount, st.Date, st.Note, st.CreatedBy,
+	)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	return int(id), err
+}
+
+const settlementColumns = "id, group_id, from_user_id, to_user_id, amount_mills, currency, exchange_rate, base_amount_mills, date, note, created_by"
+
+func scanSettlement(row interface{ Scan(...interface{}) error }) (Settlement, error) {
+	var st Settlement
+	err := row.Scan(&st.ID, &st.GroupID, &st.FromUserID, &st.ToUserID, &st.Amount, &st.Currency, &st.Rate, &st.BaseAmount, &st.Date, &st.Note, &st.CreatedBy)
+	return st, err
+}
+
+func (m *MySQL) GetSettlement(ctx context.Context, id int) (Settlement, error) {
+	st, err := scanSettlement(m.db.QueryRowContext(ctx, "SELECT "+settlementColumns+" FROM settlements WHERE id = ?", id))
+	return st, notFound(err)
+}
+
+func (m *MySQL) ListSettlements(ctx context.Context, groupID int) ([]Settlement, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT "+settlementColumns+" FROM settlements WHERE group_id = ? ORDER BY date DESC, id DESC", groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var settlements []Settlement
+	for rows.Next() {
+		st, err := scanSettlement(rows)
+		if err != nil {
+			return nil, err
+		}
+		settlements = append(settlements, st)
+	}
+	return settlements, rows.Err()
+}
+
+func (m *MySQL) DeleteSettlement(ctx context.Context, id int) error {
+	return requireRow(m.db.ExecContext(ctx, "DELETE FROM settlements WHERE id = ?", id))
+}
+
+// Notifications
+
+func (m *MySQL) Notify(ctx context.Context, n Notification) error {
+	_, err := m.db.ExecContext(ctx,
+		"INSERT INTO notifications (user_id, group_id, kind, message) VALUES (?, ?, ?, ?)",
+		n.UserID, nullIfZero(n.GroupID), n.Kind, n.Message,
+	)
+	return err
+}
+
+func (m *MySQL) ListNotifications(ctx context.Context, userID int, unreadOnly bool) ([]Notification, error) {
+	query := "SELECT id, user_id, COALESCE(group_id, 0), kind, message, UNIX_TIMESTAMP(created_at), read_at IS NOT NULL FROM notifications WHERE user_id = ?"
+	if unreadOnly {
+		query += " AND read_at IS NULL"
+	}
+	query += " ORDER BY id DESC"
+	rows, err := m.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var notifications []Notification
+	for rows.Next() {
+		var n Notification
+		var created int64
+		if err := rows.Scan(&n.ID, &n.UserID, &n.GroupID, &n.Kind, &n.Message, &created, &n.Read); err != nil {
+			return nil, err
+		}
+		n.CreatedAt = time.Unix(created, 0).UTC()
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+func (m *MySQL) MarkRead(ctx context.Context, userID int, ids []int, now time.Time) error {
+	query := "UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL"
+	args := []interface{}{now, userID}
+	if len(ids) > 0 {
+		query += " AND id IN (?" + strings.Repeat(", ?", len(ids)-1) + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	_, err := m.db.ExecContext(ctx, query, args...)
+	return err
+}
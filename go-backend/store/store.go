@@ -0,0 +1,606 @@
+// Package store holds the persistence layer of the Letshangout backend.
+// Handlers talk to the interfaces below; NewMySQL provides the production
+// implementation and NewMemory an in-process one for tests and local dev.
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go-backend/money"
+)
+
+var (
+	// ErrNotFound is returned when the requested row doesn't exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write would violate a unique constraint
+	ErrConflict = errors.New("already exists")
+)
+
+// User and Group data structures
+type User struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Password string `json:"-"` // bcrypt hash, never sent to clients
+}
+
+type Group struct {
+	ID           int    `json:"id"`
+	Name         string `json:"name"`
+	Code         string `json:"code"`
+	Members      []int  `json:"members"` // User IDs
+	AdminID      int    `json:"admin_id"`
+	BaseCurrency string `json:"base_currency"` // ISO 4217 code balances are kept in
+}
+
+// ProposedDate is a row of event_dates
+type ProposedDate struct {
+	ID         int    `json:"id"`
+	GroupID    int    `json:"group_id"`
+	Date       string `json:"date"`
+	EndDate    string `json:"end_date"`
+	Time       string `json:"time"`
+	ProposedBy int    `json:"proposed_by"`
+	Closed     bool   `json:"closed"`             // voting has ended
+	EventID    int    `json:"event_id,omitempty"` // event scheduled from this date, if it won
+
+	Deadline *time.Time `json:"deadline,omitempty"` // votes are refused from this moment on
+	Quorum   int        `json:"quorum"`             // available votes needed to schedule it automatically
+	Tally    *Tally     `json:"tally,omitempty"`    // vote counts when voting closed
+}
+
+// Tally is the final vote count of a closed date
+type Tally struct {
+	Available    int `json:"available"`
+	Maybe        int `json:"maybe"`
+	NotAvailable int `json:"not_available"`
+}
+
+// Responses a member can give for a proposed date
+const (
+	VoteYes   = "yes"
+	VoteMaybe = "maybe"
+	VoteNo    = "no"
+)
+
+// Ballot is one member's answer for a proposed date
+type Ballot struct {
+	Response  string `json:"response"`  // VoteYes, VoteMaybe or VoteNo
+	Preferred bool   `json:"preferred"` // the member's favourite among the dates
+}
+
+// DateVote is a ballot together with who cast it and for which date
+type DateVote struct {
+	EventDateID int `json:"event_date_id"`
+	UserID      int `json:"user_id"`
+	Ballot
+}
+
+// DateSummary is a proposed date with its vote counts
+type DateSummary struct {
+	ID                 int    `json:"id"`
+	Date               string `json:"date"`
+	EndDate            string `json:"end_date"`
+	Time               string `json:"time"`
+	ProposedBy         int    `json:"proposed_by"`
+	ProposedByUsername string `json:"proposed_by_username"`
+	AvailableVotes     int    `json:"available_votes"` // "yes" votes
+	MaybeVotes         int    `json:"maybe_votes"`
+	NotAvailableVotes  int    `json:"not_available_votes"` // "no" votes
+	PreferredVotes     int    `json:"preferred_votes"`
+	Closed             bool   `json:"closed"`
+	EventID            int    `json:"event_id,omitempty"`
+
+	Deadline *time.Time `json:"deadline,omitempty"`
+	Quorum   int        `json:"quorum"`
+	Tally    *Tally     `json:"tally,omitempty"`
+}
+
+// Event data structure
+type Event struct {
+	ID          int    `json:"id"`
+	GroupID     int    `json:"group_id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Date        string `json:"date"`       // ISO format (YYYY-MM-DD)
+	EndDate     string `json:"end_date"`   // optional, for multi-day events
+	Time        string `json:"time"`       // optional, HH:MM:SS
+	CreatedBy   int    `json:"created_by"` // User ID
+}
+
+// EventFilter narrows ListEvents to a group and an optional date range
+type EventFilter struct {
+	GroupID int
+	From    string // inclusive, YYYY-MM-DD; empty for no lower bound
+	To      string // inclusive, YYYY-MM-DD; empty for no upper bound
+}
+
+// Task struct
+type Task struct {
+	ID          int    `json:"id"`
+	GroupID     int    `json:"group_id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	DueDate     string `json:"due_date"`
+	// AssigneeID is the first of Assignees (0 for none), for clients that
+	// only show one
+	AssigneeID int    `json:"assignee_id"`
+	Assignees  []int  `json:"assignees"` // User IDs, in the order assigned
+	Status     string `json:"status"`
+	// Who last changed Status and when; unset until the first change
+	StatusChangedBy int             `json:"status_changed_by,omitempty"`
+	StatusChangedAt *time.Time      `json:"status_changed_at,omitempty"`
+	Checklist       []ChecklistItem `json:"checklist"`
+}
+
+// ChecklistItem is a sub-item of a task, ticked off on its own
+type ChecklistItem struct {
+	ID     int        `json:"id"`
+	TaskID int        `json:"task_id"`
+	Title  string     `json:"title"`
+	Done   bool       `json:"done"`
+	DoneBy int        `json:"done_by,omitempty"`
+	DoneAt *time.Time `json:"done_at,omitempty"`
+}
+
+// Task statuses. A task starts as todo; the allowed moves between them are
+// decided by the server.
+const (
+	TaskTodo       = "todo"
+	TaskInProgress = "in_progress"
+	TaskDone       = "done"
+	TaskBlocked    = "blocked"
+	TaskCancelled  = "cancelled"
+)
+
+// TaskUpdate lists the task fields to change; zero values are left
+// untouched. Status changes go through TaskStore.SetTaskStatus.
+type TaskUpdate struct {
+	Title       string
+	Description string
+	DueDate     string
+	AssigneeID  int // makes this user the only assignee
+}
+
+// TaskFilter selects a group's tasks; empty fields match every task
+type TaskFilter struct {
+	GroupID    int
+	Status     string
+	AssigneeID int // matches tasks this user is one of the assignees of
+}
+
+// TaskStatusChange is a row of task_status_history
+type TaskStatusChange struct {
+	ID        int       `json:"id"`
+	TaskID    int       `json:"task_id"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	ChangedBy int       `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// Expense struct
+type Expense struct {
+	ID          int          `json:"id"`
+	GroupID     int          `json:"group_id"`
+	Description string       `json:"description"`
+	Amount      money.Amount `json:"amount"`
+	Currency    string       `json:"currency"`      // ISO 4217 code of Amount
+	Rate        float64      `json:"exchange_rate"` // base currency units per unit of Currency
+	BaseAmount  money.Amount `json:"base_amount"`   // Amount converted to the group's base currency
+	PaidBy      int          `json:"paid_by"`
+	Date        string       `json:"date"`
+	Category    string       `json:"category"`
+	SplitMode   string       `json:"split_mode"`
+	RecurringID int          `json:"recurring_id,omitempty"` // template it was posted from
+}
+
+// Ways an expense can be divided between its participants
+const (
+	SplitEqual      = "equal"
+	SplitExact      = "exact"
+	SplitPercentage = "percentage"
+	SplitShares     = "shares"
+)
+
+// Split is one participant's share of an expense
+type Split struct {
+	UserID     int          `json:"user_id"`
+	Amount     money.Amount `json:"amount"`
+	BaseAmount money.Amount `json:"base_amount,omitempty"` // Amount in the group's base currency
+	// Percentage or share count the amount was derived from; zero for
+	// equal and exact splits
+	Weight float64 `json:"weight,omitempty"`
+}
+
+// Receipt is a file attached to an expense. The content is kept in blob
+// storage under BlobKey.
+type Receipt struct {
+	ID          int       `json:"id"`
+	ExpenseID   int       `json:"expense_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	BlobKey     string    `json:"-"`
+	UploadedBy  int       `json:"uploaded_by"`
+	UploadedAt  time.Time `json:"uploaded_at"`
+}
+
+// Category is an entry of a group's expense category catalogue
+type Category struct {
+	ID      int    `json:"id"`
+	GroupID int    `json:"group_id"`
+	Name    string `json:"name"` // lower case
+}
+
+// ReportFilter narrows an expense report to a group and an optional date range
+type ReportFilter struct {
+	GroupID int
+	From    string // inclusive, YYYY-MM-DD; empty for no lower bound
+	To      string // inclusive, YYYY-MM-DD; empty for no upper bound
+}
+
+// ExpenseReport sums a group's expenses in its base currency
+type ExpenseReport struct {
+	Total      money.Amount    `json:"total"`
+	Count      int             `json:"count"`
+	ByCategory []CategoryTotal `json:"by_category"` // largest total first
+	ByMember   []MemberTotal   `json:"by_member"`   // by user ID
+	ByMonth    []MonthTotal    `json:"by_month"`    // oldest first
+}
+
+// CategoryTotal is the spending on one category; "" is uncategorized
+type CategoryTotal struct {
+	Category string       `json:"category"`
+	Total    money.Amount `json:"total"`
+	Count    int          `json:"count"`
+}
+
+// MemberTotal is what one member paid for the group and what their share of
+// the expenses came to
+type MemberTotal struct {
+	UserID   int          `json:"user_id"`
+	Username string       `json:"username"`
+	Paid     money.Amount `json:"paid"`
+	Share    money.Amount `json:"share"`
+}
+
+// MonthTotal is the spending in one month (YYYY-MM)
+type MonthTotal struct {
+	Month string       `json:"month"`
+	Total money.Amount `json:"total"`
+	Count int          `json:"count"`
+}
+
+// RecurringExpense is a row of recurring_expenses: an expense template that
+// is posted as a new expense on a schedule
+type RecurringExpense struct {
+	ID          int          `json:"id"`
+	GroupID     int          `json:"group_id"`
+	Description string       `json:"description"`
+	Amount      money.Amount `json:"amount"`
+	Currency    string       `json:"currency"`
+	Rate        float64      `json:"exchange_rate"` // zero to look the rate up when posting
+	PaidBy      int          `json:"paid_by"`
+	Category    string       `json:"category"`
+	SplitMode   string       `json:"split_mode"`
+	Splits      []Split      `json:"splits"`
+
+	Frequency  string `json:"frequency"`  // RecurWeekly, RecurMonthly or RecurCustom
+	Interval   int    `json:"interval"`   // weeks, months or (custom) days between occurrences
+	StartDate  string `json:"start_date"` // first occurrence; the rest are counted from it
+	EndDate    string `json:"end_date"`   // no occurrence after this date; empty for none
+	NextDate   string `json:"next_date"`  // date of the next occurrence to post
+	Occurrence int    `json:"occurrence"` // index of NextDate, counting StartDate as 0
+	Status     string `json:"status"`     // RecurActive, RecurPaused, RecurCancelled or RecurEnded
+	CreatedBy  int    `json:"created_by"`
+}
+
+// Schedules of recurring expenses
+const (
+	RecurWeekly  = "weekly"
+	RecurMonthly = "monthly"
+	RecurCustom  = "custom"
+)
+
+// States of recurring expenses; only active ones are posted
+const (
+	RecurActive    = "active"
+	RecurPaused    = "paused"
+	RecurCancelled = "cancelled"
+	RecurEnded     = "ended" // went past its end date
+)
+
+// ExpenseChange is one field changed by an expense edit, with its old and
+// new values as shown to people
+type ExpenseChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// ExpenseRevision is a row of expense_history: who edited an expense, when,
+// and what changed
+type ExpenseRevision struct {
+	ID        int             `json:"id"`
+	ExpenseID int             `json:"expense_id"`
+	ChangedBy int             `json:"changed_by"`
+	ChangedAt time.Time       `json:"changed_at"`
+	Changes   []ExpenseChange `json:"changes"`
+}
+
+// Budget caps a group's spending in its base currency, on one category or,
+// with an empty Category, on all of the group's expenses
+type Budget struct {
+	ID        int          `json:"id"`
+	GroupID   int          `json:"group_id"`
+	Category  string       `json:"category"`
+	Limit     money.Amount `json:"limit"`
+	CreatedBy int          `json:"created_by"`
+}
+
+// BudgetAlert records an expense pushing spending over a budget
+type BudgetAlert struct {
+	ID        int          `json:"id"`
+	BudgetID  int          `json:"budget_id"`
+	GroupID   int          `json:"group_id"`
+	Category  string       `json:"category"`
+	Limit     money.Amount `json:"limit"`
+	Spent     money.Amount `json:"spent"`
+	ExpenseID int          `json:"expense_id,omitempty"` // 0 once the expense is deleted
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// Settlement is a payment from one member to another that pays back debt
+type Settlement struct {
+	ID         int          `json:"id"`
+	GroupID    int          `json:"group_id"`
+	FromUserID int          `json:"from_user_id"`
+	ToUserID   int          `json:"to_user_id"`
+	Amount     money.Amount `json:"amount"`
+	Currency   string       `json:"currency"`
+	Rate       float64      `json:"exchange_rate"`
+	BaseAmount money.Amount `json:"base_amount"`
+	Date       string       `json:"date"`
+	Note       string       `json:"note"`
+	CreatedBy  int          `json:"created_by"`
+}
+
+// Notification is a message shown to a single user
+type Notification struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"user_id"`
+	GroupID   int       `json:"group_id"`
+	Kind      string    `json:"kind"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+	Read      bool      `json:"read"`
+}
+
+type UserStore interface {
+	CreateUser(ctx context.Context, username, password string) (int, error)
+	GetUser(ctx context.Context, id int) (User, error)
+	GetUserByUsername(ctx context.Context, username string) (User, error)
+	UpdatePassword(ctx context.Context, id int, password string) error
+}
+
+type SessionStore interface {
+	CreateSession(ctx context.Context, tokenHash string, userID int, expiresAt time.Time) error
+	// SessionUser returns the user owning an unexpired session
+	SessionUser(ctx context.Context, tokenHash string, now time.Time) (User, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+}
+
+type GroupStore interface {
+	// CreateGroup inserts the group and adds the admin as its first member
+	CreateGroup(ctx context.Context, g Group) (int, error)
+	GetGroup(ctx context.Context, id int) (Group, error)
+	GetGroupByCode(ctx context.Context, code string) (Group, error)
+	ListGroupsForUser(ctx context.Context, userID int) ([]Group, error)
+	UpdateCode(ctx context.Context, id int, code string) error
+	// SetBaseCurrency returns ErrConflict while the group has amounts in its
+	// current base currency: expenses, settlements, budgets, or active or
+	// paused recurring expenses
+	SetBaseCurrency(ctx context.Context, id int, currency string) error
+	// DeleteGroup removes the group with its members, dates, events, tasks,
+	// expenses and settlements
+	DeleteGroup(ctx context.Context, id int) error
+
+	IsMember(ctx context.Context, groupID, userID int) (bool, error)
+	AddMember(ctx context.Context, groupID, userID int) error
+	RemoveMember(ctx context.Context, groupID, userID int) error
+	ListMembers(ctx context.Context, groupID int) ([]User, error)
+}
+
+type DateStore interface {
+	ProposeDate(ctx context.Context, d ProposedDate) (int, error)
+	GetDate(ctx context.Context, id int) (ProposedDate, error)
+	ListDates(ctx context.Context, groupID int) ([]DateSummary, error)
+	// Vote records (or replaces) a user's ballot for a date
+	Vote(ctx context.Context, eventDateID, userID int, b Ballot) error
+	// ListVotes returns every ballot cast on the group's dates
+	ListVotes(ctx context.Context, groupID int) ([]DateVote, error)
+	DeleteDate(ctx context.Context, id int) error
+	// SetDeadline changes the voting window of a date; nil removes the deadline
+	SetDeadline(ctx context.Context, id int, deadline *time.Time, quorum int) error
+	// ExpiredDates lists open dates whose deadline is at or before now
+	ExpiredDates(ctx context.Context, now time.Time) ([]ProposedDate, error)
+	// CloseDate ends voting on a date and records its tally. It returns
+	// ErrConflict if the date is already closed.
+	CloseDate(ctx context.Context, id int) error
+	// FinalizeDate creates the event, closes every open date of the event's
+	// group (recording their tallies) and links the winning date to the new
+	// event. It returns ErrConflict if the winning date is already closed.
+	FinalizeDate(ctx context.Context, eventDateID int, e Event) (int, error)
+}
+
+type EventStore interface {
+	CreateEvent(ctx context.Context, e Event) (int, error)
+	GetEvent(ctx context.Context, id int) (Event, error)
+	ListEvents(ctx context.Context, f EventFilter) ([]Event, error)
+	// UpdateEvent replaces the title, description, dates and time of an event
+	UpdateEvent(ctx context.Context, e Event) error
+	DeleteEvent(ctx context.Context, id int) error
+}
+
+// Tasks are returned with their assignees and checklist items filled in
+type TaskStore interface {
+	// CreateTask adds a task, assigned to t.AssigneeID if it is set
+	CreateTask(ctx context.Context, t Task) (int, error)
+	GetTask(ctx context.Context, id int) (Task, error)
+	// ListTasks returns the tasks matching the filter, by ID
+	ListTasks(ctx context.Context, f TaskFilter) ([]Task, error)
+	// AssignTask makes assigneeID the task's only assignee; 0 unassigns all
+	AssignTask(ctx context.Context, id, assigneeID int) error
+	// AddTaskAssignee adds an assignee; ErrConflict if already assigned
+	AddTaskAssignee(ctx context.Context, taskID, userID int) error
+	RemoveTaskAssignee(ctx context.Context, taskID, userID int) error
+	UpdateTask(ctx context.Context, id int, u TaskUpdate) error
+	// SetTaskStatus moves a task from status from to status to and records
+	// the change. It returns ErrConflict if the task's status is no longer
+	// from, i.e. someone else changed it in the meantime.
+	SetTaskStatus(ctx context.Context, id int, from, to string, changedBy int) error
+	// ListTaskStatusHistory returns a task's status changes, newest first
+	ListTaskStatusHistory(ctx context.Context, taskID int) ([]TaskStatusChange, error)
+	DeleteTask(ctx context.Context, id int) error
+
+	// AddChecklistItem appends an item to a task's checklist; ErrNotFound
+	// if the task is gone
+	AddChecklistItem(ctx context.Context, taskID int, title string) (int, error)
+	GetChecklistItem(ctx context.Context, id int) (ChecklistItem, error)
+	RenameChecklistItem(ctx context.Context, id int, title string) error
+	// SetChecklistItemDone ticks an item off as done by doneBy, or unticks it
+	SetChecklistItemDone(ctx context.Context, id int, done bool, doneBy int) error
+	DeleteChecklistItem(ctx context.Context, id int) error
+}
+
+type ExpenseStore interface {
+	CreateExpense(ctx context.Context, e Expense, splits []Split) (int, error)
+	GetExpense(ctx context.Context, id int) (Expense, error)
+	ListExpenses(ctx context.Context, groupID int) ([]Expense, error)
+	ListSplits(ctx context.Context, expenseID int) ([]Split, error)
+	// GroupSplits returns the splits of all of a group's expenses, by
+	// expense ID, in one query
+	GroupSplits(ctx context.Context, groupID int) (map[int][]Split, error)
+	// GroupBalances returns each user's net balance in the base currency:
+	// what they paid minus their splits, plus settlements they paid minus
+	// settlements they received. Users with no activity are left out.
+	GroupBalances(ctx context.Context, groupID int) (map[int]money.Amount, error)
+	// Report totals the expenses matching the filter
+	Report(ctx context.Context, f ReportFilter) (ExpenseReport, error)
+	// UpdateExpense replaces an expense's fields and splits and records the
+	// revision, all or nothing. It returns ErrNotFound if the expense is gone.
+	UpdateExpense(ctx context.Context, e Expense, splits []Split, rev ExpenseRevision) error
+	// ListExpenseHistory returns an expense's revisions, newest first
+	ListExpenseHistory(ctx context.Context, expenseID int) ([]ExpenseRevision, error)
+	DeleteExpense(ctx context.Context, id int) error
+}
+
+type ReceiptStore interface {
+	// AddReceipt returns ErrNotFound if the expense is gone
+	AddReceipt(ctx context.Context, rc Receipt) (int, error)
+	GetReceipt(ctx context.Context, id int) (Receipt, error)
+	// ListReceipts returns an expense's receipts, oldest first
+	ListReceipts(ctx context.Context, expenseID int) ([]Receipt, error)
+	// ListGroupReceipts returns the receipts of all of a group's expenses
+	ListGroupReceipts(ctx context.Context, groupID int) ([]Receipt, error)
+	DeleteReceipt(ctx context.Context, id int) error
+}
+
+type CategoryStore interface {
+	// ListCategories returns a group's categories by name
+	ListCategories(ctx context.Context, groupID int) ([]Category, error)
+	GetCategory(ctx context.Context, id int) (Category, error)
+	// AddCategory returns ErrConflict if the group already has the name
+	AddCategory(ctx context.Context, groupID int, name string) (int, error)
+	// DeleteCategory returns ErrConflict while expenses, recurring
+	// expenses or budgets use the category
+	DeleteCategory(ctx context.Context, id int) error
+}
+
+type RecurringStore interface {
+	// CreateRecurring inserts a template with its splits
+	CreateRecurring(ctx context.Context, r RecurringExpense) (int, error)
+	GetRecurring(ctx context.Context, id int) (RecurringExpense, error)
+	ListRecurring(ctx context.Context, groupID int) ([]RecurringExpense, error)
+	// UpdateRecurring replaces a template's fields and splits
+	UpdateRecurring(ctx context.Context, r RecurringExpense) error
+	// DueRecurring returns the active templates whose next date is on or
+	// before today (YYYY-MM-DD)
+	DueRecurring(ctx context.Context, today string) ([]RecurringExpense, error)
+	// AdvanceRecurring stores a template's next date, occurrence and status
+	// after posting the occurrence of fromDate. It returns ErrConflict if the
+	// template is no longer active at fromDate, i.e. it was posted, paused or
+	// edited in the meantime.
+	AdvanceRecurring(ctx context.Context, r RecurringExpense, fromDate string) error
+}
+
+type BudgetStore interface {
+	// SetBudget creates the group's budget for the category, or replaces
+	// its limit, and returns its ID
+	SetBudget(ctx context.Context, b Budget) (int, error)
+	GetBudget(ctx context.Context, id int) (Budget, error)
+	// ListBudgets returns a group's budgets, the whole-group one first and
+	// then by category
+	ListBudgets(ctx context.Context, groupID int) ([]Budget, error)
+	DeleteBudget(ctx context.Context, id int) error
+	AddBudgetAlert(ctx context.Context, a BudgetAlert) (int, error)
+	// ListBudgetAlerts returns a group's alerts, newest first
+	ListBudgetAlerts(ctx context.Context, groupID int) ([]BudgetAlert, error)
+}
+
+type SettlementStore interface {
+	CreateSettlement(ctx context.Context, st Settlement) (int, error)
+	GetSettlement(ctx context.Context, id int) (Settlement, error)
+	// ListSettlements returns a group's settlements, newest first
+	ListSettlements(ctx context.Context, groupID int) ([]Settlement, error)
+	DeleteSettlement(ctx context.Context, id int) error
+}
+
+type NotificationStore interface {
+	Notify(ctx context.Context, n Notification) error
+	ListNotifications(ctx context.Context, userID int, unreadOnly bool) ([]Notification, error)
+	// MarkRead marks the given notifications of a user as read; no IDs marks all
+	MarkRead(ctx context.Context, userID int, ids []int, now time.Time) error
+}
+
+// Store bundles the stores used by the HTTP handlers
+type Store struct {
+	Users    UserStore
+	Sessions SessionStore
+	Groups   GroupStore
+	Dates    DateStore
+	Events   EventStore
+	Tasks    TaskStore
+	Expenses ExpenseStore
+
+	Receipts      ReceiptStore
+	Categories    CategoryStore
+	Recurring     RecurringStore
+	Budgets       BudgetStore
+	Settlements   SettlementStore
+	Notifications NotificationStore
+
+	withTx func(ctx context.Context, fn func(tx *Store) error) error
+}
+
+// WithTx runs fn against a Store whose writes all take effect if fn returns
+// nil and are all rolled back if it returns an error. Calling WithTx on the
+// Store passed to fn joins the same transaction.
+func (s *Store) WithTx(ctx context.Context, fn func(tx *Store) error) error {
+	return s.withTx(ctx, fn)
+}
+
+// Intercept returns a Store whose stores, and those passed to fn by WithTx,
+// are those of s as changed by wrap. Tests use it to make a step fail.
+func (s *Store) Intercept(wrap func(st *Store)) *Store {
+	c := *s
+	wrap(&c)
+	c.withTx = func(ctx context.Context, fn func(tx *Store) error) error {
+		return s.withTx(ctx, func(tx *Store) error {
+			return fn(tx.Intercept(wrap))
+		})
+	}
+	return &c
+}
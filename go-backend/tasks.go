@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"go-backend/store"
+)
+
+// taskTransitions lists the statuses each status may move to. The main path
+// is todo -> in_progress -> done; small tasks can be done straight from todo,
+// blocked tasks go back to todo or in_progress, and done or cancelled tasks
+// are reopened rather than jumping anywhere else.
+var taskTransitions = map[string][]string{
+	store.TaskTodo:       {store.TaskInProgress, store.TaskDone, store.TaskBlocked, store.TaskCancelled},
+	store.TaskInProgress: {store.TaskTodo, store.TaskDone, store.TaskBlocked, store.TaskCancelled},
+	store.TaskBlocked:    {store.TaskTodo, store.TaskInProgress, store.TaskCancelled},
+	store.TaskDone:       {store.TaskInProgress},
+	store.TaskCancelled:  {store.TaskTodo},
+}
+
+func isTaskStatus(status string) bool {
+	_, ok := taskTransitions[status]
+	return ok
+}
+
+const invalidTaskStatus = "Invalid status (want todo, in_progress, done, blocked or cancelled)"
+
+// checkTaskTransition validates moving task to status. Staying in the same
+// status is allowed and changes nothing. On failure it writes the error
+// response and returns false.
+func checkTaskTransition(w http.ResponseWriter, task store.Task, status string) bool {
+	if !isTaskStatus(status) {
+		http.Error(w, invalidTaskStatus, http.StatusBadRequest)
+		return false
+	}
+	if status == task.Status {
+		return true
+	}
+	for _, next := range taskTransitions[task.Status] {
+		if next == status {
+			return true
+		}
+	}
+	allowed := strings.Join(taskTransitions[task.Status], ", ")
+	if allowed == "" {
+		allowed = "none"
+	}
+	http.Error(w, fmt.Sprintf("Can't move a task from %s to %s (allowed: %s)", task.Status, status, allowed), http.StatusConflict)
+	return false
+}
+
+// setTaskStatus records a validated status change of task by the caller,
+// through st so it can join a transaction
+func setTaskStatus(r *http.Request, st *store.Store, task store.Task, status string) error {
+	if status == task.Status {
+		return nil
+	}
+	user, _ := currentUser(r)
+	return st.Tasks.SetTaskStatus(r.Context(), task.ID, task.Status, status, user.ID)
+}
+
+// statusChangeFailed writes the response for a failed setTaskStatus
+func statusChangeFailed(w http.ResponseWriter, err error) {
+	if err == store.ErrConflict {
+		http.Error(w, "The task's status was changed meanwhile; reload and try again", http.StatusConflict)
+		return
+	}
+	http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+}
+
+// taskFilter reads the optional status and assignee_id filters of a task
+// listing. On failure it writes the error response and returns false.
+func taskFilter(w http.ResponseWriter, r *http.Request, groupID int) (store.TaskFilter, bool) {
+	f := store.TaskFilter{GroupID: groupID, Status: r.URL.Query().Get("status")}
+	if f.Status != "" && !isTaskStatus(f.Status) {
+		http.Error(w, invalidTaskStatus, http.StatusBadRequest)
+		return f, false
+	}
+	if raw := r.URL.Query().Get("assignee_id"); raw != "" {
+		id, err := strconv.Atoi(raw)
+		if err != nil {
+			http.Error(w, "Invalid assignee_id", http.StatusBadRequest)
+			return f, false
+		}
+		f.AssigneeID = id
+	}
+	return f, true
+}
+
+// Status changes of a task, newest first
+func (s *server) taskStatusHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	taskID, ok := idParam(w, r, "task_id")
+	if !ok {
+		return
+	}
+	if _, ok := s.authorizeTask(w, r, taskID, roleMember); !ok {
+		return
+	}
+	history, err := s.store.Tasks.ListTaskStatusHistory(r.Context(), taskID)
+	if err != nil {
+		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if history == nil {
+		history = []store.TaskStatusChange{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+const maxChecklistTitleLen = 255
+
+// checkAssignee checks that a user can be assigned tasks of a group, i.e.
+// belongs to it. On failure it writes the error response and returns false.
+func (s *server) checkAssignee(w http.ResponseWriter, r *http.Request, groupID, userID int) bool {
+	role, err := s.groupRoleOf(r.Context(), groupID, userID)
+	if err != nil {
+		lookupFailed(w, err, "Group not found")
+		return false
+	}
+	if role < roleMember {
+		http.Error(w, "Assignee is not a member of this group", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// Add a member of the task's group to its assignees
+func (s *server) addTaskAssigneeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		TaskID int `json:"task_id"`
+		UserID int `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	task, ok := s.authorizeTask(w, r, req.TaskID, roleMember)
+	if !ok || !s.checkAssignee(w, r, task.GroupID, req.UserID) {
+		return
+	}
+	err := s.store.Tasks.AddTaskAssignee(r.Context(), task.ID, req.UserID)
+	if err == store.ErrConflict {
+		http.Error(w, "User is already assigned to this task", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		lookupFailed(w, err, "Task not found")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
+}
+
+// Remove a user from a task's assignees
+func (s *server) removeTaskAssigneeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		TaskID int `json:"task_id"`
+		UserID int `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if _, ok := s.authorizeTask(w, r, req.TaskID, roleMember); !ok {
+		return
+	}
+	if err := s.store.Tasks.RemoveTaskAssignee(r.Context(), req.TaskID, req.UserID); err != nil {
+		lookupFailed(w, err, "User is not assigned to this task")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
+}
+
+// checklistTitle trims a checklist item's title and checks its length. On
+// failure it writes the error response and returns false.
+func checklistTitle(w http.ResponseWriter, title string) (string, bool) {
+	title = strings.TrimSpace(title)
+	if title == "" || len(title) > maxChecklistTitleLen {
+		http.Error(w, fmt.Sprintf("Checklist item title must be 1-%d characters", maxChecklistTitleLen), http.StatusBadRequest)
+		return "", false
+	}
+	return title, true
+}
+
+// authorizeChecklistItem loads a checklist item and checks that the caller
+// belongs to the group of its task
+func (s *server) authorizeChecklistItem(w http.ResponseWriter, r *http.Request, itemID int) (store.ChecklistItem, bool) {
+	item, err := s.store.Tasks.GetChecklistItem(r.Context(), itemID)
+	if err != nil {
+		lookupFailed(w, err, "Checklist item not found")
+		return item, false
+	}
+	_, ok := s.authorizeTask(w, r, item.TaskID, roleMember)
+	return item, ok
+}
+
+// Append an item to a task's checklist
+func (s *server) addChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		TaskID int    `json:"task_id"`
+		Title  string `json:"title"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	title, ok := checklistTitle(w, req.Title)
+	if !ok {
+		return
+	}
+	if _, ok := s.authorizeTask(w, r, req.TaskID, roleMember); !ok {
+		return
+	}
+	id, err := s.store.Tasks.AddChecklistItem(r.Context(), req.TaskID, title)
+	if err != nil {
+		lookupFailed(w, err, "Task not found")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(store.ChecklistItem{ID: id, TaskID: req.TaskID, Title: title})
+}
+
+// Rename a checklist item and/or tick it off (done: true) or untick it
+func (s *server) updateChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ItemID int    `json:"item_id"`
+		Title  string `json:"title"`
+		Done   *bool  `json:"done"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if req.Title == "" && req.Done == nil {
+		http.Error(w, "No fields to update", http.StatusBadRequest)
+		return
+	}
+	var title string
+	if req.Title != "" {
+		var ok bool
+		if title, ok = checklistTitle(w, req.Title); !ok {
+			return
+		}
+	}
+	item, ok := s.authorizeChecklistItem(w, r, req.ItemID)
+	if !ok {
+		return
+	}
+	user, _ := currentUser(r)
+	err := s.store.WithTx(r.Context(), func(tx *store.Store) error {
+		if title != "" && title != item.Title {
+			if err := tx.Tasks.RenameChecklistItem(r.Context(), item.ID, title); err != nil {
+				return err
+			}
+		}
+		// Ticking off an item that is already done keeps who did it first
+		if req.Done != nil && *req.Done != item.Done {
+			return tx.Tasks.SetChecklistItemDone(r.Context(), item.ID, *req.Done, user.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		lookupFailed(w, err, "Checklist item not found")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
+}
+
+// Remove an item from a task's checklist
+func (s *server) deleteChecklistItemHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req struct {
+		ItemID int `json:"item_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	item, ok := s.authorizeChecklistItem(w, r, req.ItemID)
+	if !ok {
+		return
+	}
+	if err := s.store.Tasks.DeleteChecklistItem(r.Context(), item.ID); err != nil {
+		lookupFailed(w, err, "Checklist item not found")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("{\"success\":true}"))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go-backend/store"
+)
+
+func TestCheckTaskTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		wantCode int // http.StatusOK when the move is allowed
+		wantBody string
+	}{
+		{from: store.TaskTodo, to: store.TaskTodo, wantCode: http.StatusOK},
+		{from: store.TaskTodo, to: store.TaskInProgress, wantCode: http.StatusOK},
+		{from: store.TaskTodo, to: store.TaskDone, wantCode: http.StatusOK},
+		{from: store.TaskTodo, to: store.TaskBlocked, wantCode: http.StatusOK},
+		{from: store.TaskTodo, to: store.TaskCancelled, wantCode: http.StatusOK},
+		{from: store.TaskInProgress, to: store.TaskTodo, wantCode: http.StatusOK},
+		{from: store.TaskInProgress, to: store.TaskDone, wantCode: http.StatusOK},
+		{from: store.TaskBlocked, to: store.TaskInProgress, wantCode: http.StatusOK},
+		{from: store.TaskBlocked, to: store.TaskDone, wantCode: http.StatusConflict,
+			wantBody: "Can't move a task from blocked to done (allowed: todo, in_progress, cancelled)"},
+		{from: store.TaskDone, to: store.TaskDone, wantCode: http.StatusOK},
+		{from: store.TaskDone, to: store.TaskInProgress, wantCode: http.StatusOK},
+		{from: store.TaskDone, to: store.TaskTodo, wantCode: http.StatusConflict,
+			wantBody: "Can't move a task from done to todo (allowed: in_progress)"},
+		{from: store.TaskDone, to: store.TaskCancelled, wantCode: http.StatusConflict,
+			wantBody: "Can't move a task from done to cancelled (allowed: in_progress)"},
+		{from: store.TaskCancelled, to: store.TaskTodo, wantCode: http.StatusOK},
+		{from: store.TaskCancelled, to: store.TaskInProgress, wantCode: http.StatusConflict,
+			wantBody: "Can't move a task from cancelled to in_progress (allowed: todo)"},
+		{from: store.TaskTodo, to: "pending", wantCode: http.StatusBadRequest, wantBody: invalidTaskStatus},
+		{from: store.TaskTodo, to: "", wantCode: http.StatusBadRequest, wantBody: invalidTaskStatus},
+		{from: "pending", to: store.TaskDone, wantCode: http.StatusConflict,
+			wantBody: "Can't move a task from pending to done (allowed: none)"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		ok := checkTaskTransition(w, store.Task{ID: 1, Status: tt.from}, tt.to)
+		if ok != (tt.wantCode == http.StatusOK) || w.Code != tt.wantCode {
+			t.Errorf("%s -> %s: ok = %v, code %d; want code %d", tt.from, tt.to, ok, w.Code, tt.wantCode)
+			continue
+		}
+		if body := strings.TrimSpace(w.Body.String()); body != tt.wantBody {
+			t.Errorf("%s -> %s: body %q, want %q", tt.from, tt.to, body, tt.wantBody)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"go-backend/store"
+)
+
+// failingCategories refuses to add categories
+type failingCategories struct {
+	store.CategoryStore
+}
+
+func (failingCategories) AddCategory(ctx context.Context, groupID int, name string) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+// failingExpenses refuses to create more than allowed expenses
+type failingExpenses struct {
+	store.ExpenseStore
+	allowed *int
+}
+
+func (f failingExpenses) CreateExpense(ctx context.Context, e store.Expense, splits []store.Split) (int, error) {
+	if *f.allowed == 0 {
+		return 0, errors.New("disk full")
+	}
+	*f.allowed--
+	return f.ExpenseStore.CreateExpense(ctx, e, splits)
+}
+
+func TestCreateGroupRollsBack(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+	mem := s.store
+	s.store = mem.Intercept(func(st *store.Store) {
+		st.Categories = failingCategories{st.Categories}
+	})
+
+	// A guest's user, session and group are created before the categories
+	// are seeded, and must all be rolled back when that fails
+	w := serve(s, s.createGroupHandler, http.MethodPost, "/groups", "", `{"name":"Trip","username":"guest"}`)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("code %d, want 500 (%s)", w.Code, w.Body)
+	}
+	if _, err := s.store.Users.GetUserByUsername(ctx, "guest"); err != store.ErrNotFound {
+		t.Errorf("guest user after rollback: err = %v, want ErrNotFound", err)
+	}
+	if _, err := s.store.Groups.GetGroup(ctx, 1); err != store.ErrNotFound {
+		t.Errorf("group after rollback: err = %v, want ErrNotFound", err)
+	}
+
+	// The username is free again
+	s.store = mem
+	w = serve(s, s.createGroupHandler, http.MethodPost, "/groups", "", `{"name":"Trip","username":"guest"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("retry: code %d (%s)", w.Code, w.Body)
+	}
+}
+
+func TestImportExpensesRollsBack(t *testing.T) {
+	s := newTestServer()
+	ctx := context.Background()
+	alice, token := addTestUser(t, s, "alice")
+	groupID, err := s.store.Groups.CreateGroup(ctx, store.Group{Name: "Trip", Code: "TRIP01", AdminID: alice, BaseCurrency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	allowed := 1
+	s.store = s.store.Intercept(func(st *store.Store) {
+		st.Expenses = failingExpenses{st.Expenses, &allowed}
+	})
+
+	ledger := "id,date,description,category,amount,currency,exchange_rate,base_amount,paid_by,split_mode,split:alice\n" +
+		",2026-10-01,Dinner,,10,USD,,,alice,equal,10\n" +
+		",2026-10-02,Taxi,,4,USD,,,alice,equal,4\n"
+	w := serve(s, requireAuth(s.importExpensesHandler), http.MethodPost, "/import-expenses?group_id="+strconv.Itoa(groupID), token, ledger)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("code %d, want 500 (%s)", w.Code, w.Body)
+	}
+	if expenses, _ := s.store.Expenses.ListExpenses(ctx, groupID); len(expenses) != 0 {
+		t.Errorf("expenses after the failed import = %+v, want none", expenses)
+	}
+}
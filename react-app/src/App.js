@@ -5,6 +5,39 @@ import { BrowserRouter as Router, Routes, Route, useParams, useNavigate } from '
 import { DragDropContext, Droppable, Draggable } from 'react-beautiful-dnd';
 import Modal from 'react-modal';
 
+const API_URL = 'http://127.0.0.1:8085';
+const TOKEN_KEY = 'sessionToken';
+
+// Keep the session token returned by /login, /register or a guest's /groups
+function saveSession(token) {
+  if (token) {
+    localStorage.setItem(TOKEN_KEY, token);
+  } else {
+    localStorage.removeItem(TOKEN_KEY);
+  }
+}
+
+// fetch against the backend, authenticated with the stored session token
+function apiFetch(path, options = {}) {
+  const headers = { ...options.headers };
+  const token = localStorage.getItem(TOKEN_KEY);
+  if (token) {
+    headers.Authorization = `Bearer ${token}`;
+  }
+  return fetch(API_URL + path, { ...options, headers });
+}
+
+// The user as kept in state: the session token lives in localStorage only
+function sessionUser({ token, expires_at, ...user }) {
+  saveSession(token);
+  return user;
+}
+
+// Intl knows each currency's minor unit (no decimals for JPY, three for BHD)
+function formatMoney(amount, currency) {
+  return new Intl.NumberFormat(undefined, { style: 'currency', currency: currency || 'USD' }).format(amount);
+}
+
 function Login({ onLogin, onSwitchToRegister, onGuest }) {
   const [username, setUsername] = useState('');
   const [password, setPassword] = useState('');
@@ -157,7 +190,7 @@ function DateVoting({ group, user }) {
   useEffect(() => {
     if (group && group.id) {
       setLoading(true);
-      fetch(`http://127.0.0.1:8085/group-dates?group_id=${group.id}`)
+      apiFetch(`/group-dates?group_id=${group.id}`)
         .then(res => res.json())
         .then(data => {
           setDates(data);
@@ -180,7 +213,7 @@ function DateVoting({ group, user }) {
   const handlePropose = () => {
     if (rangeMode) {
       if (!rangeStart || !rangeEnd || (rangeEnd < rangeStart)) return;
-      fetch('http://127.0.0.1:8085/propose-date', {
+      apiFetch('/propose-date', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ group_id: group.id, date: rangeStart, end_date: rangeEnd, time: newTime, proposed_by: user.id })
@@ -191,13 +224,13 @@ function DateVoting({ group, user }) {
           setRangeEnd('');
           setNewTime('');
           // Refresh dates
-          return fetch(`http://127.0.0.1:8085/group-dates?group_id=${group.id}`)
+          return apiFetch(`/group-dates?group_id=${group.id}`)
             .then(res => res.json())
             .then(setDates);
         });
     } else {
       if (!newDate || !newTime) return;
-      fetch('http://127.0.0.1:8085/propose-date', {
+      apiFetch('/propose-date', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ group_id: group.id, date: newDate, time: newTime, proposed_by: user.id })
@@ -207,7 +240,7 @@ function DateVoting({ group, user }) {
           setNewDate('');
           setNewTime('');
           // Refresh dates
-          return fetch(`http://127.0.0.1:8085/group-dates?group_id=${group.id}`)
+          return apiFetch(`/group-dates?group_id=${group.id}`)
             .then(res => res.json())
             .then(setDates);
         });
@@ -217,14 +250,14 @@ function DateVoting({ group, user }) {
   // Vote for a date (only if not already voted)
   const handleVote = (event_date_id, available) => {
     if (userVotes[event_date_id] !== undefined) return; // Already voted
-    fetch('http://127.0.0.1:8085/vote-date', {
+    apiFetch('/vote-date', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
-      body: JSON.stringify({ event_date_id, user_id: user.id, available })
+      body: JSON.stringify({ event_date_id, available })
     })
       .then(() => {
         // Refresh dates
-        return fetch(`http://127.0.0.1:8085/group-dates?group_id=${group.id}`)
+        return apiFetch(`/group-dates?group_id=${group.id}`)
           .then(res => res.json())
           .then(setDates);
       });
@@ -232,15 +265,15 @@ function DateVoting({ group, user }) {
 
   // Delete a proposed date
   const handleDelete = (event_date_id) => {
-    fetch('http://127.0.0.1:8085/delete-proposed-date', {
+    apiFetch('/delete-proposed-date', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
-      body: JSON.stringify({ event_date_id, user_id: user.id })
+      body: JSON.stringify({ event_date_id })
     })
       .then(res => {
         if (!res.ok) throw new Error('Delete failed');
         // Refresh dates
-        return fetch(`http://127.0.0.1:8085/group-dates?group_id=${group.id}`)
+        return apiFetch(`/group-dates?group_id=${group.id}`)
           .then(res => res.json())
           .then(setDates);
       })
@@ -398,24 +431,24 @@ function TaskBoard({ group, user }) {
 
   useEffect(() => {
     if (group && group.id) {
-      fetch(`http://127.0.0.1:8085/group-tasks?group_id=${group.id}`)
+      apiFetch(`/group-tasks?group_id=${group.id}`)
         .then(res => res.json())
         .then(data => setTasks(Array.isArray(data) ? data : []));
-      fetch(`http://127.0.0.1:8085/group-members?group_id=${group.id}`)
+      apiFetch(`/group-members?group_id=${group.id}`)
         .then(res => res.json())
         .then(data => setMembers(Array.isArray(data) ? data : []));
     }
   }, [group]);
 
   const fetchTasks = () => {
-    fetch(`http://127.0.0.1:8085/group-tasks?group_id=${group.id}`)
+    apiFetch(`/group-tasks?group_id=${group.id}`)
       .then(res => res.json())
       .then(data => setTasks(Array.isArray(data) ? data : []));
   };
 
   const handleAddTask = () => {
     if (!title || !assignee) return;
-    fetch('http://127.0.0.1:8085/add-task', {
+    apiFetch('/add-task', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({
@@ -437,7 +470,7 @@ function TaskBoard({ group, user }) {
   };
 
   const handleMarkDone = (taskId) => {
-    fetch('http://127.0.0.1:8085/complete-task', {
+    apiFetch('/complete-task', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ task_id: taskId })
@@ -449,7 +482,7 @@ function TaskBoard({ group, user }) {
   };
 
   const handleEditSave = () => {
-    fetch('http://127.0.0.1:8085/update-task', {
+    apiFetch('/update-task', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({
@@ -467,7 +500,7 @@ function TaskBoard({ group, user }) {
   };
 
   const handleDeleteTask = (taskId) => {
-    fetch('http://127.0.0.1:8085/delete-task', {
+    apiFetch('/delete-task', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ task_id: taskId })
@@ -479,18 +512,20 @@ function TaskBoard({ group, user }) {
 
   const statusColor = s =>
     s === 'done' ? '#d4edda' :
-    s === 'in-progress' ? '#fff3cd' :
+    s === 'in_progress' ? '#fff3cd' :
     '#f8d7da';
 
   const statusTextColor = s =>
     s === 'done' ? '#155724' :
-    s === 'in-progress' ? '#856404' :
+    s === 'in_progress' ? '#856404' :
     '#721c24';
 
   const statusGroups = [
     { key: 'todo', label: 'To Do', color: '#2196f3' },
-    { key: 'in-progress', label: 'In Progress', color: '#ff9800' },
-    { key: 'done', label: 'Done', color: '#4caf50' }
+    { key: 'in_progress', label: 'In Progress', color: '#ff9800' },
+    { key: 'done', label: 'Done', color: '#4caf50' },
+    { key: 'blocked', label: 'Blocked', color: '#f44336' },
+    { key: 'cancelled', label: 'Cancelled', color: '#9e9e9e' }
   ];
 
   // Drag and drop handlers
@@ -502,7 +537,7 @@ function TaskBoard({ group, user }) {
     const sourceStatus = source.droppableId;
     const destStatus = destination.droppableId;
     if (sourceStatus !== destStatus) {
-      fetch('http://127.0.0.1:8085/update-task', {
+      apiFetch('/update-task', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({
@@ -625,7 +660,7 @@ function TaskBoard({ group, user }) {
             <input value={editTask.title} onChange={e => setEditTask({...editTask, title: e.target.value})} />
             <input value={editTask.description} onChange={e => setEditTask({...editTask, description: e.target.value})} />
             <input type="date" value={editTask.due_date} onChange={e => setEditTask({...editTask, due_date: e.target.value})} />
-            <select value={editTask.assignee_id} onChange={e => setEditTask({...editTask, assignee_id: e.target.value})}>
+            <select value={editTask.assignee_id} onChange={e => setEditTask({...editTask, assignee_id: Number(e.target.value)})}>
               <option value="">Assignee</option>
               {members.map(m => <option key={m.id} value={m.id}>{m.username}</option>)}
             </select>
@@ -677,7 +712,7 @@ function BudgetPanel({ group, user }) {
   // Fetch group members
   useEffect(() => {
     if (group && group.id) {
-      fetch(`http://127.0.0.1:8085/group-members?group_id=${group.id}`)
+      apiFetch(`/group-members?group_id=${group.id}`)
         .then(res => res.json())
         .then(data => setMembers(Array.isArray(data) ? data : []))
         .catch(() => setMembers([]));
@@ -687,7 +722,7 @@ function BudgetPanel({ group, user }) {
   // Fetch expenses and balances
   const refresh = () => {
     if (group && group.id) {
-      fetch(`http://127.0.0.1:8085/group-expenses?group_id=${group.id}`)
+      apiFetch(`/group-expenses?group_id=${group.id}`)
         .then(res => res.json())
         .then(data => {
           console.log('Fetched expenses:', data);
@@ -695,7 +730,7 @@ function BudgetPanel({ group, user }) {
           setTimeout(() => console.log('Current expenses state:', expenses), 0);
         })
         .catch(() => setExpenses([]));
-      fetch(`http://127.0.0.1:8085/group-balances?group_id=${group.id}`)
+      apiFetch(`/group-balances?group_id=${group.id}`)
         .then(res => res.json())
         .then(data => setBalances(Array.isArray(data) ? data : []))
         .catch(() => setBalances([]));
@@ -705,7 +740,7 @@ function BudgetPanel({ group, user }) {
 
   const handleAddExpense = () => {
     if (!desc || !amount || !paidBy || splitWith.length === 0) return;
-    fetch('http://127.0.0.1:8085/add-expense', {
+    apiFetch('/add-expense', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({
@@ -727,12 +762,12 @@ function BudgetPanel({ group, user }) {
   // Calculate category totals
   const categoryTotals = CATEGORIES.map(cat => {
     const catExpenses = expenses.filter(e => e.category === cat.key);
-    const total = catExpenses.reduce((sum, e) => sum + (e.amount || 0), 0);
+    const total = catExpenses.reduce((sum, e) => sum + (e.base_amount || e.amount || 0), 0);
     return { ...cat, total, expenses: catExpenses };
   });
 
   const handleDeleteExpense = (expenseId) => {
-    fetch('http://127.0.0.1:8085/delete-expense', {
+    apiFetch('/delete-expense', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ expense_id: expenseId })
@@ -800,7 +835,7 @@ function BudgetPanel({ group, user }) {
   const handleAddExternalMember = async () => {
     if (!newExternalName.trim()) return;
     const name = newExternalName.trim();
-    const res = await fetch('http://127.0.0.1:8085/add-external-member', {
+    const res = await apiFetch('/add-external-member', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ group_id: group.id, name })
@@ -895,7 +930,7 @@ function BudgetPanel({ group, user }) {
             }}
           >
             <div style={{fontWeight: 700, fontSize: '1.1rem', marginBottom: 2, color: cat.color}}>{cat.label}</div>
-            <div style={{color: '#555', fontSize: '1.05rem'}}>Total: <span style={{fontWeight: 600}}>${cat.total.toFixed(2)}</span></div>
+            <div style={{color: '#555', fontSize: '1.05rem'}}>Total: <span style={{fontWeight: 600}}>{formatMoney(cat.total, group.base_currency)}</span></div>
             {openCategory === cat.key && cat.expenses.length > 0 && (
               <div style={{marginTop: 10, borderTop: `1.5px solid ${cat.color}22`, paddingTop: 8}}>
                 {cat.expenses.map(exp => (
@@ -903,7 +938,7 @@ function BudgetPanel({ group, user }) {
                     <div style={{flex: 1}}>
                       <div style={{fontWeight: 600, fontSize: '1rem'}}>{exp.description}</div>
                       <div style={{fontSize: '0.97rem', color: '#888'}}>Paid by: {(members.find(m => m.id === exp.paid_by) || {}).username || 'Unknown'}</div>
-                      <div style={{fontSize: '0.97rem', color: '#888'}}>Amount: ${exp.amount.toFixed(2)}</div>
+                      <div style={{fontSize: '0.97rem', color: '#888'}}>Amount: {formatMoney(exp.amount, exp.currency)}</div>
                       <div style={{fontSize: '0.93rem', color: '#aaa'}}>Date: {exp.date || '—'}</div>
                     </div>
                     {exp.paid_by === user.id && (
@@ -924,7 +959,7 @@ function BudgetPanel({ group, user }) {
         <div style={{display: 'flex', flexWrap: 'wrap', gap: '1rem'}}>
           {(balances || []).map(bal => (
             <div key={bal.user_id} style={{background: bal.balance < 0 ? '#f44336' : '#4caf50', color: '#fff', borderRadius: 8, padding: '0.7rem 1.2rem', fontWeight: 600, minWidth: 180}}>
-              {bal.username}: {bal.balance < 0 ? 'owes' : 'is owed'} ${Math.abs(bal.balance).toFixed(2)}
+              {bal.username}: {bal.balance < 0 ? 'owes' : 'is owed'} {formatMoney(Math.abs(bal.balance), bal.currency)}
             </div>
           ))}
         </div>
@@ -943,7 +978,7 @@ function BudgetPanel({ group, user }) {
                 ) : (
                   <ul style={{paddingLeft: 20}}>
                     {settlements.map((s, idx) => (
-                      <li key={idx}>{s.from} pays {s.to} <b>${s.amount.toFixed(2)}</b></li>
+                      <li key={idx}>{s.from} pays {s.to} <b>{formatMoney(s.amount, group.base_currency)}</b></li>
                     ))}
                   </ul>
                 );
@@ -966,7 +1001,7 @@ function Dashboard({ user, group, onLogout }) {
   const navigate = useNavigate();
   useEffect(() => {
     if (group && group.id) {
-      fetch(`http://127.0.0.1:8085/group-members?group_id=${group.id}`)
+      apiFetch(`/group-members?group_id=${group.id}`)
         .then(res => res.json())
         .then(data => setMembers(Array.isArray(data) ? data : []))
         .catch(() => setMembers([]));
@@ -1077,19 +1112,19 @@ function InviteJoin({ onLogin, onRegister, onGuest, user, setUser, setUserGroups
 
   useEffect(() => {
     if (user && user.id && groupCode) {
-      const joinWithUserId = (realUserId) => {
+      const joinGroup = () => {
         setJoining(true);
-        fetch('http://127.0.0.1:8085/join-group', {
+        apiFetch('/join-group', {
           method: 'POST',
           headers: { 'Content-Type': 'application/json' },
-          body: JSON.stringify({ code: groupCode, user_id: realUserId })
+          body: JSON.stringify({ code: groupCode })
         })
           .then(res => {
             if (!res.ok) return res.text().then(t => { throw new Error(t); });
             return res.json();
           })
           .then(() => {
-            fetch(`http://127.0.0.1:8085/my-groups?user_id=${realUserId}`)
+            apiFetch('/my-groups')
               .then(res => res.json())
               .then(groups => {
                 setUserGroups(groups);
@@ -1103,7 +1138,7 @@ function InviteJoin({ onLogin, onRegister, onGuest, user, setUser, setUserGroups
       };
       if (typeof user.id === 'string' && user.isGuest) {
         // Register guest in backend
-        fetch('http://127.0.0.1:8085/register', {
+        apiFetch('/register', {
           method: 'POST',
           headers: { 'Content-Type': 'application/json' },
           body: JSON.stringify({ username: user.username, password: '' })
@@ -1113,12 +1148,13 @@ function InviteJoin({ onLogin, onRegister, onGuest, user, setUser, setUserGroups
             return res.json();
           })
           .then(newUser => {
-            setUser({ ...user, id: newUser.id });
-            joinWithUserId(newUser.id);
+            if (!newUser.token) throw new Error('Username already exists');
+            setUser({ ...user, ...sessionUser(newUser) });
+            joinGroup();
           })
           .catch(e => setError(e.message));
       } else {
-        joinWithUserId(user.id);
+        joinGroup();
       }
     }
   }, [user, groupCode, setUserGroups, setSelectedGroup, navigate]);
@@ -1126,14 +1162,14 @@ function InviteJoin({ onLogin, onRegister, onGuest, user, setUser, setUserGroups
   const handleGuest = () => setShowGuestPrompt(true);
   const confirmGuest = () => {
     if (!guestName) return;
-    fetch('http://127.0.0.1:8085/guest-login', {
+    apiFetch('/guest-login', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ username: guestName })
     })
       .then(res => res.json())
       .then(user => {
-        setUser({ ...user, guest: true });
+        setUser({ ...sessionUser(user), guest: true });
         setShowGuestPrompt(false);
         setGuestName('');
       });
@@ -1193,10 +1229,10 @@ function ProfileSettings({ user, setUser }) {
     }
     const updated = { ...user, username, phone, picture: pictureUrl };
     // Call backend to update user profile
-    await fetch('http://127.0.0.1:8085/update-profile', {
+    await apiFetch('/update-profile', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
-      body: JSON.stringify(updated)
+      body: JSON.stringify({ username, phone, picture: pictureUrl })
     });
     setUser(updated);
     setSaving(false);
@@ -1206,14 +1242,14 @@ function ProfileSettings({ user, setUser }) {
   const handleUpgrade = async () => {
     setSaving(true);
     setUpgradeError('');
-    const res = await fetch('http://127.0.0.1:8085/upgrade-guest', {
+    const res = await apiFetch('/upgrade-guest', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
-      body: JSON.stringify({ user_id: user.id, username, password: upgradePassword })
+      body: JSON.stringify({ username, password: upgradePassword })
     });
     if (res.ok) {
       const upgraded = await res.json();
-      setUser({ ...upgraded, guest: false });
+      setUser({ ...sessionUser(upgraded), guest: false });
       setShowUpgrade(false);
       setUpgradePassword('');
       navigate(-1);
@@ -1316,9 +1352,8 @@ function App() {
   };
 
   useEffect(() => {
-    const backendUrl = 'http://127.0.0.1:8085/api';
     setLoading(true);
-    fetch(backendUrl)
+    apiFetch('/api')
       .then(response => {
         if (!response.ok) {
           throw new Error(`HTTP error! Status: ${response.status}`);
@@ -1338,7 +1373,7 @@ function App() {
 
   useEffect(() => {
     if (user && user.id) {
-      fetch(`http://127.0.0.1:8085/my-groups?user_id=${user.id}`)
+      apiFetch('/my-groups')
         .then(res => res.json())
         .then(groups => setUserGroups(groups))
         .catch(() => setUserGroups([]));
@@ -1346,7 +1381,7 @@ function App() {
   }, [user]);
 
   const handleLogin = (username, password) => {
-    fetch('http://127.0.0.1:8085/login', {
+    apiFetch('/login', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ username, password })
@@ -1358,14 +1393,14 @@ function App() {
         return response.json();
       })
       .then(user => {
-        setUser(user);
+        setUser(sessionUser(user));
       })
       .catch(error => {
         alert('Login failed: ' + error.message);
       });
   };
   const handleRegister = (username, password) => {
-    fetch('http://127.0.0.1:8085/register', {
+    apiFetch('/register', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ username, password })
@@ -1377,24 +1412,31 @@ function App() {
         return response.json();
       })
       .then(user => {
-        setUser(user);
+        // An existing username comes back without a session
+        if (!user.token) throw new Error('Username already exists');
+        setUser(sessionUser(user));
       })
       .catch(error => {
         alert('Registration failed: ' + error.message);
       });
   };
 
+  const handleLogout = () => {
+    apiFetch('/logout', { method: 'POST' }).finally(() => saveSession(null));
+    setUser(null);
+    setSelectedGroup(null);
+  };
+
   const handleCreateGroup = (groupName) => {
     if (!user || !user.id) {
       alert('You must be logged in to create a group.');
       return;
     }
-    fetch('http://127.0.0.1:8085/groups', {
+    apiFetch('/groups', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({
         name: groupName,
-        user_id: user.id,
         username: user.guest ? user.username : undefined,
         guest: user.guest ? true : undefined
       })
@@ -1411,9 +1453,14 @@ function App() {
         }
       })
       .then(group => {
+        // A guest's first group comes with their new session
+        if (group.token) {
+          saveSession(group.token);
+          setUser({ ...user, id: group.admin_id });
+        }
         setGroup(group);
         // Fetch updated group list
-        fetch(`http://127.0.0.1:8085/my-groups?user_id=${user.id}`)
+        apiFetch('/my-groups')
           .then(res => res.json())
           .then(groups => setUserGroups(groups));
         alert('Group created! Your group code is: ' + group.code);
@@ -1428,10 +1475,10 @@ function App() {
       alert('You must be logged in to join a group.');
       return;
     }
-    fetch('http://127.0.0.1:8085/join-group', {
+    apiFetch('/join-group', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
-      body: JSON.stringify({ code: groupCode, user_id: user.id })
+      body: JSON.stringify({ code: groupCode })
     })
       .then(response => {
         if (!response.ok) {
@@ -1466,14 +1513,14 @@ function App() {
   };
   const confirmGuest = () => {
     if (!guestName) return;
-    fetch('http://127.0.0.1:8085/guest-login', {
+    apiFetch('/guest-login', {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ username: guestName })
     })
       .then(res => res.json())
       .then(user => {
-        setUser({ ...user, guest: true });
+        setUser({ ...sessionUser(user), guest: true });
         setShowGuestPrompt(false);
         setGuestName('');
       });
@@ -1551,7 +1598,7 @@ function App() {
                   )}
                 </div>
               ) : (
-                <Dashboard user={user} group={selectedGroup} onLogout={() => { setUser(null); setSelectedGroup(null); }} />
+                <Dashboard user={user} group={selectedGroup} onLogout={handleLogout} />
               )}
             </header>
           </div>